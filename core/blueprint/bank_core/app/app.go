@@ -1,4 +1,7 @@
-package main
+// Package app runs a bank service. Every bank runs the same core and differs
+// only in its configuration: name, BIN range, keys and PCC membership, all
+// read from the environment.
+package app
 
 import (
 	"context"
@@ -8,9 +11,9 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
-	"unicreditbank_microservice/internal/database"
 
-	"unicreditbank_microservice/internal/server"
+	"bank_core/database"
+	"bank_core/server"
 )
 
 func gracefulShutdown(apiServer *http.Server, done chan bool) {
@@ -37,10 +40,28 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	done <- true
 }
 
-func main() {
+// Run starts the bank service and blocks until it is shut down
+func Run() {
+	if err := database.LoadKeyring(); err != nil {
+		log.Fatalf("failed to load encryption keys: %v", err)
+	}
 	database.Connect()
 	server := server.NewServer()
 
+	// Move PANs still sealed with older keys to the active key
+	if progress, err := database.New().StartReencryption(); err != nil {
+		log.Printf("failed to start card re-encryption: %v", err)
+	} else if progress.Total > 0 {
+		log.Printf("re-encrypting %d cards to key version %d", progress.Total, progress.ActiveVersion)
+	}
+
+	if report, err := database.New().CheckLedger(); err != nil {
+		log.Printf("failed to check the ledger: %v", err)
+	} else if !report.Consistent {
+		log.Printf("ledger drift: %d bank accounts differ from the ledger, %d journals do not balance",
+			len(report.Drift), len(report.UnbalancedJournals))
+	}
+
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
 
@@ -0,0 +1,7 @@
+package main
+
+import "bank_core/app"
+
+func main() {
+	app.Run()
+}
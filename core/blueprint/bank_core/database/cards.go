@@ -0,0 +1,419 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	panLength        = 16
+	cardValidYears   = 4
+	maxPANAttempts   = 10
+	maskedDigitsHead = 6
+	maskedDigitsTail = 4
+)
+
+var (
+	ErrAccountNotFound = errors.New("bank account not found")
+	ErrCardNotFound    = errors.New("card not found")
+	ErrCardReplaced    = errors.New("card has been replaced")
+)
+
+// IssuedCard is a newly issued card with its PAN and CVV2, which are
+// returned only once
+type IssuedCard struct {
+	Card
+	PAN  string `json:"pan"`
+	CVV2 string `json:"cvv2"`
+}
+
+// binRange is the bank's configured BIN range, e.g. "411111-411199"
+var binRange = os.Getenv("BANK_BIN_RANGE")
+
+// parseBINRange returns the lowest and highest BIN of a "low-high" range.
+// Both ends must have the same number of digits.
+func parseBINRange(config string) (string, string, error) {
+	low, high, found := strings.Cut(strings.TrimSpace(config), "-")
+	if !found {
+		low, high = config, config
+	}
+	low, high = strings.TrimSpace(low), strings.TrimSpace(high)
+
+	if low == "" || len(low) != len(high) || len(low) < 6 || len(low) > 8 {
+		return "", "", fmt.Errorf("invalid BANK_BIN_RANGE %q, expected 6 to 8 digit BINs as low-high", config)
+	}
+	lowValue, errLow := strconv.ParseUint(low, 10, 64)
+	highValue, errHigh := strconv.ParseUint(high, 10, 64)
+	if errLow != nil || errHigh != nil || lowValue > highValue {
+		return "", "", fmt.Errorf("invalid BANK_BIN_RANGE %q", config)
+	}
+
+	return low, high, nil
+}
+
+// generatePAN returns a random Luhn-valid PAN whose BIN lies in the range
+func generatePAN(low, high string) (string, error) {
+	lowValue, _ := strconv.ParseUint(low, 10, 64)
+	highValue, _ := strconv.ParseUint(high, 10, 64)
+
+	offset, err := rand.Int(rand.Reader, new(big.Int).SetUint64(highValue-lowValue+1))
+	if err != nil {
+		return "", err
+	}
+	bin := fmt.Sprintf("%0*d", len(low), lowValue+offset.Uint64())
+
+	var pan strings.Builder
+	pan.WriteString(bin)
+	for pan.Len() < panLength-1 {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		pan.WriteString(digit.String())
+	}
+
+	return pan.String() + luhnCheckDigit(pan.String()), nil
+}
+
+// luhnCheckDigit returns the digit that makes the number Luhn-valid
+func luhnCheckDigit(number string) string {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		// Double every second digit counting left from the check digit
+		if (len(number)-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// maskPAN keeps the BIN and the last four digits of a PAN
+func maskPAN(pan string) string {
+	return pan[:maskedDigitsHead] + strings.Repeat("X", len(pan)-maskedDigitsHead-maskedDigitsTail) + pan[len(pan)-maskedDigitsTail:]
+}
+
+// cardExpiry returns the last day of the month cardValidYears from now
+func cardExpiry(now time.Time) time.Time {
+	firstOfMonth := time.Date(now.Year()+cardValidYears, now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return firstOfMonth.AddDate(0, 1, -1)
+}
+
+// cardExpired reports whether a card is past the end of its expiry month
+func cardExpired(expiry time.Time, now time.Time) bool {
+	return !now.Before(time.Date(expiry.Year(), expiry.Month()+1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// newCard generates an unused PAN in the bank's BIN range and returns the card
+// to store, encrypted and masked, with the PAN
+func (s *service) newCard(bankAccountId uint, cardType CardType) (*IssuedCard, error) {
+	low, high, err := parseBINRange(binRange)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxPANAttempts; attempt++ {
+		pan, err := generatePAN(low, high)
+		if err != nil {
+			return nil, err
+		}
+
+		panIndex, err := BlindIndex(pan)
+		if err != nil {
+			return nil, err
+		}
+
+		var exists bool
+		err = s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM cards WHERE pan_index = $1)`, panIndex).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check PAN: %w", err)
+		}
+		if exists {
+			continue
+		}
+
+		encryptedPAN, err := Encrypt(pan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt PAN: %w", err)
+		}
+
+		expiry := cardExpiry(time.Now())
+		cvv, err := CVV2(pan, expiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive CVV2: %w", err)
+		}
+
+		return &IssuedCard{
+			Card: Card{
+				BankAccountID: bankAccountId,
+				EncryptedPAN:  encryptedPAN,
+				PANIndex:      panIndex,
+				KeyVersion:    ActiveKeyVersion(),
+				MaskedPAN:     maskPAN(pan),
+				ExpiryDate:    expiry,
+				CardType:      cardType,
+				Status:        CardActive,
+
+				OnlinePaymentsEnabled: true,
+			},
+			PAN:  pan,
+			CVV2: cvv,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no unused PAN found in BIN range %s-%s", low, high)
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func insertCard(db rowQuerier, card *Card) error {
+	query := `INSERT INTO cards (bank_account_id, encrypted_pan, pan_index, key_version, masked_pan, expiry_date, card_type, is_tokenized, status,
+	                             frozen, online_payments_enabled, transaction_limit,
+	                             daily_amount_limit, daily_count_limit, single_transaction_limit, credit_line)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	          RETURNING id`
+
+	return db.QueryRow(query, card.BankAccountID, card.EncryptedPAN, card.PANIndex, card.KeyVersion,
+		card.MaskedPAN, card.ExpiryDate, card.CardType, card.IsTokenized, card.Status,
+		card.Frozen, card.OnlinePaymentsEnabled, card.TransactionLimit,
+		card.DailyAmountLimit, card.DailyCountLimit, card.SingleTransactionLimit, card.CreditLine).Scan(&card.ID)
+}
+
+// IssueCard issues a new card of the given type to an existing bank account
+func (s *service) IssueCard(bankAccountId uint, cardType CardType) (*IssuedCard, error) {
+	err := s.db.QueryRow(`SELECT 1 FROM bank_accounts WHERE id = $1`, bankAccountId).Scan(new(int))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bank account: %w", err)
+	}
+
+	issued, err := s.newCard(bankAccountId, cardType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertCard(s.db, &issued.Card); err != nil {
+		return nil, fmt.Errorf("failed to store card: %w", err)
+	}
+
+	return issued, nil
+}
+
+// cardColumns is the column list scanned by scanCard; it leaves out the
+// encrypted PAN and its index
+const cardColumns = `c.id, c.bank_account_id, c.masked_pan, c.expiry_date, c.card_type, c.is_tokenized, c.status,
+	c.frozen, c.online_payments_enabled, c.transaction_limit,
+	c.daily_amount_limit, c.daily_count_limit, c.single_transaction_limit, c.credit_line`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCard(row rowScanner) (*Card, error) {
+	var card Card
+	err := row.Scan(
+		&card.ID,
+		&card.BankAccountID,
+		&card.MaskedPAN,
+		&card.ExpiryDate,
+		&card.CardType,
+		&card.IsTokenized,
+		&card.Status,
+		&card.Frozen,
+		&card.OnlinePaymentsEnabled,
+		&card.TransactionLimit,
+		&card.DailyAmountLimit,
+		&card.DailyCountLimit,
+		&card.SingleTransactionLimit,
+		&card.CreditLine,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch card: %w", err)
+	}
+	return &card, nil
+}
+
+// GetCard returns a card by its ID
+func (s *service) GetCard(cardId uint) (*Card, error) {
+	return scanCard(s.db.QueryRow(`SELECT `+cardColumns+` FROM cards c WHERE c.id = $1`, cardId))
+}
+
+// checkCardControls applies the controls the account holder set on a card
+// to an online payment
+func checkCardControls(card Card, amount float32) error {
+	if card.Frozen {
+		return ErrCardFrozen
+	}
+	if !card.OnlinePaymentsEnabled {
+		return ErrOnlineDisabled
+	}
+	if card.TransactionLimit > 0 && amount > card.TransactionLimit {
+		return ErrExceedsCardLimit
+	}
+	return nil
+}
+
+// GetClientCards returns the cards on all bank accounts of a client
+func (s *service) GetClientCards(clientId uint) ([]Card, error) {
+	query := `SELECT ` + cardColumns + `
+	          FROM cards c JOIN bank_accounts a ON a.id = c.bank_account_id
+	          WHERE a.user_id = $1
+	          ORDER BY c.id`
+
+	rows, err := s.db.Query(query, clientId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cards: %w", err)
+	}
+	defer rows.Close()
+
+	cards := []Card{}
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, *card)
+	}
+
+	return cards, rows.Err()
+}
+
+// GetClientCard returns a card on one of the client's bank accounts. Cards
+// of other clients are reported as not found.
+func (s *service) GetClientCard(clientId uint, cardId uint) (*Card, error) {
+	query := `SELECT ` + cardColumns + `
+	          FROM cards c JOIN bank_accounts a ON a.id = c.bank_account_id
+	          WHERE c.id = $1 AND a.user_id = $2`
+
+	return scanCard(s.db.QueryRow(query, cardId, clientId))
+}
+
+// SetCardFrozen freezes or unfreezes one of the client's cards
+func (s *service) SetCardFrozen(clientId uint, cardId uint, frozen bool) (*Card, error) {
+	card, err := s.GetClientCard(clientId, cardId)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == CardReplaced {
+		return nil, ErrCardReplaced
+	}
+
+	if _, err := s.db.Exec(`UPDATE cards SET frozen = $1 WHERE id = $2`, frozen, cardId); err != nil {
+		return nil, fmt.Errorf("failed to update card: %w", err)
+	}
+
+	card.Frozen = frozen
+	return card, nil
+}
+
+// SetCardControls changes the payment controls of one of the client's cards
+func (s *service) SetCardControls(clientId uint, cardId uint, controls CardControlsRequest) (*Card, error) {
+	card, err := s.GetClientCard(clientId, cardId)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == CardReplaced {
+		return nil, ErrCardReplaced
+	}
+
+	if controls.OnlinePaymentsEnabled != nil {
+		card.OnlinePaymentsEnabled = *controls.OnlinePaymentsEnabled
+	}
+	if controls.TransactionLimit != nil {
+		card.TransactionLimit = *controls.TransactionLimit
+	}
+
+	_, err = s.db.Exec(`UPDATE cards SET online_payments_enabled = $1, transaction_limit = $2 WHERE id = $3`,
+		card.OnlinePaymentsEnabled, card.TransactionLimit, cardId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update card: %w", err)
+	}
+
+	return card, nil
+}
+
+// SetCardStatus blocks or unblocks a card. Replaced cards stay replaced.
+func (s *service) SetCardStatus(cardId uint, status CardStatus) (*Card, error) {
+	card, err := s.GetCard(cardId)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == CardReplaced {
+		return nil, ErrCardReplaced
+	}
+
+	_, err = s.db.Exec(`UPDATE cards SET status = $1 WHERE id = $2 AND status <> $3`, status, cardId, CardReplaced)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update card: %w", err)
+	}
+
+	card.Status = status
+	return card, nil
+}
+
+// ReplaceCard issues a new card of the same type on the same account and
+// permanently retires the old one
+func (s *service) ReplaceCard(cardId uint) (*IssuedCard, error) {
+	card, err := s.GetCard(cardId)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == CardReplaced {
+		return nil, ErrCardReplaced
+	}
+
+	issued, err := s.newCard(card.BankAccountID, card.CardType)
+	if err != nil {
+		return nil, err
+	}
+	// The holder's controls and the bank's limits of the card carry over to
+	// the replacement
+	issued.OnlinePaymentsEnabled = card.OnlinePaymentsEnabled
+	issued.TransactionLimit = card.TransactionLimit
+	issued.DailyAmountLimit = card.DailyAmountLimit
+	issued.DailyCountLimit = card.DailyCountLimit
+	issued.SingleTransactionLimit = card.SingleTransactionLimit
+	issued.CreditLine = card.CreditLine
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE cards SET status = $1 WHERE id = $2 AND status <> $1`, CardReplaced, cardId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire card: %w", err)
+	}
+	if replaced, _ := result.RowsAffected(); replaced == 0 {
+		return nil, ErrCardReplaced
+	}
+
+	if err := insertCard(tx, &issued.Card); err != nil {
+		return nil, fmt.Errorf("failed to store card: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit card replacement: %w", err)
+	}
+
+	return issued, nil
+}
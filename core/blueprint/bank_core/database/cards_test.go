@@ -0,0 +1,60 @@
+package database
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestReplaceCardKeepsLimits(t *testing.T) {
+	t.Setenv("BANK_ENCRYPTION_KEYS", "1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("BANK_PAN_INDEX_KEY", "test-pan-index-key-of-32-bytes!!")
+	if err := LoadKeyring(); err != nil {
+		t.Fatal(err)
+	}
+	binRange, cardVerificationKey = "411111-411199", "0123456789abcdef0123456789abcdef"
+	defer func() { binRange, cardVerificationKey = "", "" }()
+
+	Connect()
+	srv := New().(*service)
+
+	var clientId, accountId uint
+	if err := srv.db.QueryRow(`INSERT INTO bank_clients (name, surname) VALUES ('Ana', 'Anić') RETURNING id`).Scan(&clientId); err != nil {
+		t.Fatal(err)
+	}
+	err := srv.db.QueryRow(`INSERT INTO bank_accounts (account_number, user_id, balance, currency, date_created, status)
+	                        VALUES ('265-0000000000001-00', $1, 0, 'RSD', $2, $3) RETURNING id`, clientId, time.Now(), Active).Scan(&accountId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued, err := srv.IssueCard(accountId, Credit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dailyAmount, dailyCount, single, creditLine := float32(5000), 3, float32(1000), float32(20000)
+	_, err = srv.SetCardLimits(issued.ID, CardLimitsRequest{
+		DailyAmountLimit:       &dailyAmount,
+		DailyCountLimit:        &dailyCount,
+		SingleTransactionLimit: &single,
+		CreditLine:             &creditLine,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacement, err := srv.ReplaceCard(issued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := srv.GetCard(replacement.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.DailyAmountLimit == nil || *card.DailyAmountLimit != dailyAmount ||
+		card.DailyCountLimit == nil || *card.DailyCountLimit != dailyCount ||
+		card.SingleTransactionLimit == nil || *card.SingleTransactionLimit != single ||
+		card.CreditLine == nil || *card.CreditLine != creditLine {
+		t.Errorf("replacement lost the card's limits: %+v", card)
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrClientNotFound     = errors.New("bank client not found")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrPasswordTooShort   = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+// SetClientPassword sets the online banking password of a bank client
+func (s *service) SetClientPassword(clientId uint, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	result, err := s.db.Exec(`UPDATE bank_clients SET password_hash = $1 WHERE id = $2`, string(hash), clientId)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+// ChangeClientPassword replaces a client's online banking password after
+// checking their current one
+func (s *service) ChangeClientPassword(clientId uint, currentPassword, newPassword string) error {
+	var hash string
+	err := s.db.QueryRow(`SELECT COALESCE(password_hash, '') FROM bank_clients WHERE id = $1`, clientId).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrClientNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch client: %w", err)
+	}
+
+	if hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(currentPassword)) != nil {
+		return ErrWrongPassword
+	}
+	return s.SetClientPassword(clientId, newPassword)
+}
+
+// AuthenticateClient returns the bank client with the given email and
+// password. Unknown emails and wrong passwords fail the same way.
+func (s *service) AuthenticateClient(email, password string) (*BankClient, error) {
+	var client BankClient
+	query := `SELECT id, name, surname, email, COALESCE(password_hash, '') FROM bank_clients WHERE email = $1`
+	err := s.db.QueryRow(query, email).Scan(&client.ID, &client.Name, &client.Surname, &client.Email, &client.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch client: %w", err)
+	}
+
+	if client.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(client.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &client, nil
+}
+
+const accountColumns = `a.id, a.account_number, a.user_id, a.balance, a.currency, a.date_created, a.status`
+
+func scanAccount(row rowScanner) (*BankAccount, error) {
+	var account BankAccount
+	err := row.Scan(&account.ID, &account.AccountNumber, &account.UserID, &account.Balance, &account.Currency, &account.DateCreated, &account.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bank account: %w", err)
+	}
+	return &account, nil
+}
+
+// GetClientAccounts returns the bank accounts of a client with their balances
+func (s *service) GetClientAccounts(clientId uint) ([]BankAccount, error) {
+	rows, err := s.db.Query(`SELECT `+accountColumns+` FROM bank_accounts a WHERE a.user_id = $1 ORDER BY a.id`, clientId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []BankAccount{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// GetClientAccount returns one of the client's bank accounts; accounts of
+// other clients are not found
+func (s *service) GetClientAccount(clientId uint, accountId uint) (*BankAccount, error) {
+	query := `SELECT ` + accountColumns + ` FROM bank_accounts a WHERE a.id = $1 AND a.user_id = $2`
+	return scanAccount(s.db.QueryRow(query, accountId, clientId))
+}
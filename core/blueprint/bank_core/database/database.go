@@ -0,0 +1,560 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/joho/godotenv/autoload"
+)
+
+// Service represents a service that interacts with a database.
+type Service interface {
+	// Health returns a map of health status information.
+	// The keys and values in the map are service-specific.
+	Health() map[string]string
+
+	// Close terminates the database connection.
+	// It returns an error if the connection cannot be closed.
+	Close() error
+
+	WriteTransaction(transaction Transaction) error
+
+	Pay(acquirerOrderId uuid.UUID, currency string, amount float32, cardNumber string, expiryDate time.Time, cvv string, merchantId uint) (TransactionStatus, error)
+
+	// Payments routed between banks through PCC
+	PayWithForeignCard(issuer Issuer, acquirerOrderId uuid.UUID, currency string, amount float32, cardNumber string, expiryDate time.Time, cvv string, merchantId uint) (TransactionStatus, error)
+	AuthorizeRoutedPayment(req AuthorizationRequest) (TransactionStatus, error)
+	PostAdvice(advice AdviceMessage) error
+	ImportSettlement(file SettlementFile) (*SettlementReport, error)
+
+	// Refunds of payments between the bank's own accounts
+	RefundPayment(acquirerOrderId uuid.UUID, req RefundRequest) (*Refund, error)
+
+	// Funds on hold
+	PlaceHold(bankAccountId uint, req HoldRequest) (*Hold, error)
+	ReleaseHold(holdId uuid.UUID) (*Hold, error)
+	GetHolds(bankAccountId uint, includeReleased bool) ([]Hold, error)
+
+	// Card issuance and management
+	IssueCard(bankAccountId uint, cardType CardType) (*IssuedCard, error)
+	GetCard(cardId uint) (*Card, error)
+	SetCardStatus(cardId uint, status CardStatus) (*Card, error)
+	ReplaceCard(cardId uint) (*IssuedCard, error)
+
+	// Spending limits
+	GetCardTypeLimits(cardType CardType) (SpendingLimits, error)
+	SetCardTypeLimits(cardType CardType, limits SpendingLimits) error
+	SetCardLimits(cardId uint, req CardLimitsRequest) (*Card, error)
+	GetCardLimitsOverview(clientId uint, cardId uint) (*LimitsOverview, error)
+
+	// Online banking
+	SetClientPassword(clientId uint, password string) error
+	ChangeClientPassword(clientId uint, currentPassword, newPassword string) error
+	AuthenticateClient(email, password string) (*BankClient, error)
+	GetClientAccounts(clientId uint) ([]BankAccount, error)
+	GetClientAccount(clientId uint, accountId uint) (*BankAccount, error)
+	GetAccountTransactions(clientId uint, accountId uint, page int, pageSize int) (*TransactionPage, error)
+	GetStatement(clientId uint, accountId uint, month time.Time) (*Statement, error)
+
+	// Client self-service
+	GetClientCards(clientId uint) ([]Card, error)
+	SetCardFrozen(clientId uint, cardId uint, frozen bool) (*Card, error)
+	SetCardControls(clientId uint, cardId uint, controls CardControlsRequest) (*Card, error)
+
+	// CheckLedger reports bank accounts whose balance drifted from the ledger
+	CheckLedger() (*LedgerReport, error)
+
+	// StartReencryption moves stored PANs to the active encryption key in the background.
+	StartReencryption() (ReencryptionProgress, error)
+	GetReencryptionProgress() ReencryptionProgress
+}
+
+type service struct {
+	db *sql.DB
+
+	reencryptionMu sync.Mutex
+	reencryption   ReencryptionProgress
+}
+
+func (s *service) WriteTransaction(transaction Transaction) error {
+
+	query := `INSERT INTO transactions (transaction_id, acquirer_order_id, acquirer_timestamp, merchant_id, merchant_order_id, status, amount, currency, timestamp, partial_card_number) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	// Use the database connection to execute the query.
+	_, err := s.db.Exec(query, transaction.TransactionId, transaction.AcquirerOrderId, transaction.AcquirerTimestamp, transaction.MerchantId, transaction.MerchantOrderId, transaction.Status, transaction.Amount, transaction.Currency, transaction.Timestamp, transaction.PartialCardNumber)
+
+	// Handle any errors from the database operation.
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Pay(acquirerOrderId uuid.UUID, currency string, amount float32, cardNumber string, expiryDate time.Time, cvv string, merchantId uint) (TransactionStatus, error) {
+	merchantAccountId, status, err := s.merchantAccount(merchantId)
+	if err != nil {
+		s.setTransactionStatus(acquirerOrderId, status)
+		return status, err
+	}
+
+	return s.chargeCard(acquirerOrderId, cardNumber, expiryDate, cvv, currency, amount, customerAccount(merchantAccountId))
+}
+
+func (s *service) setTransactionStatus(acquirerOrderId uuid.UUID, status TransactionStatus) {
+	queryUpdateStatus := `UPDATE transactions SET status = $1 WHERE acquirer_order_id = $2`
+	_, err := s.db.Exec(queryUpdateStatus, status, acquirerOrderId)
+	if err != nil {
+		fmt.Printf("failed to update transaction status: %v\n", err)
+	}
+}
+
+// merchantAccount returns the bank account a merchant is paid into
+func (s *service) merchantAccount(merchantId uint) (uint, TransactionStatus, error) {
+	var merchantBankAccountID uint
+	merchantQuery := `SELECT bank_account_id FROM merchants WHERE merchant_id = $1`
+	err := s.db.QueryRow(merchantQuery, merchantId).Scan(&merchantBankAccountID)
+	if err != nil {
+		fmt.Println(err)
+		return 0, Failed, fmt.Errorf("fail, merchant does not exist: %w", err)
+	}
+
+	bankAccountQuery := `SELECT 1 FROM bank_accounts WHERE id = $1`
+	err = s.db.QueryRow(bankAccountQuery, merchantBankAccountID).Scan(new(int)) // Scan into a dummy variable
+	if err != nil {
+		fmt.Println(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, Error, fmt.Errorf("fail, bank account does not exist")
+		}
+		return 0, Error, fmt.Errorf("fail, error checking bank account existence: %w", err)
+	}
+
+	return merchantBankAccountID, Successful, nil
+}
+
+// chargeCard authorizes a payment with one of the bank's cards and, if the
+// card, account and limits allow it, moves the amount from the card's account
+// to the credited ledger account. The transaction row of the acquirer order
+// must already exist.
+func (s *service) chargeCard(acquirerOrderId uuid.UUID, cardNumber string, expiryDate time.Time, cvv string, currency string, amount float32, creditAccount string) (TransactionStatus, error) {
+	updateTransactionStatus := func(status TransactionStatus) {
+		s.setTransactionStatus(acquirerOrderId, status)
+	}
+
+	if !isValidCardNumber(cardNumber) {
+		updateTransactionStatus(Failed)
+		return Failed, fmt.Errorf("invalid card number")
+	}
+
+	panIndex, err := BlindIndex(cardNumber)
+	if err != nil {
+		updateTransactionStatus(Error)
+		return Error, fmt.Errorf("failed to index card number: %w", err)
+	}
+
+	queryCard := `SELECT id, bank_account_id, encrypted_pan, expiry_date, card_type, is_tokenized, status,
+	                     frozen, online_payments_enabled, transaction_limit,
+	                     daily_amount_limit, daily_count_limit, single_transaction_limit, credit_line
+	              FROM cards WHERE pan_index = $1`
+
+	var card Card
+	err = s.db.QueryRow(queryCard, panIndex).Scan(
+		&card.ID,
+		&card.BankAccountID,
+		&card.EncryptedPAN,
+		&card.ExpiryDate,
+		&card.CardType,
+		&card.IsTokenized,
+		&card.Status,
+		&card.Frozen,
+		&card.OnlinePaymentsEnabled,
+		&card.TransactionLimit,
+		&card.DailyAmountLimit,
+		&card.DailyCountLimit,
+		&card.SingleTransactionLimit,
+		&card.CreditLine,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		updateTransactionStatus(Failed)
+		return Failed, ErrNoSuchCard
+	}
+	if err != nil {
+		updateTransactionStatus(Error)
+		return Error, fmt.Errorf("failed to fetch card: %w", err)
+	}
+
+	if card.Status != CardActive {
+		updateTransactionStatus(Failed)
+		return Failed, ErrCardRestricted
+	}
+
+	if card.ExpiryDate.Year() != expiryDate.Year() || card.ExpiryDate.Month() != expiryDate.Month() || cardExpired(card.ExpiryDate, time.Now()) {
+		updateTransactionStatus(Failed)
+		return Failed, ErrExpiredCard
+	}
+
+	cvvValid, err := VerifyCVV2(cardNumber, card.ExpiryDate, cvv)
+	if err != nil {
+		updateTransactionStatus(Error)
+		return Error, fmt.Errorf("failed to verify CVV2: %w", err)
+	}
+	if !cvvValid {
+		updateTransactionStatus(Failed)
+		return Failed, ErrInvalidCVV
+	}
+
+	// Controls set by the account holder; every payment through the PSP is
+	// card-not-present, so it counts as an online payment
+	if err := checkCardControls(card, amount); err != nil {
+		updateTransactionStatus(Failed)
+		return Failed, err
+	}
+
+	limits, err := s.cardLimits(card)
+	if err != nil {
+		updateTransactionStatus(Error)
+		return Error, fmt.Errorf("failed to fetch card limits: %w", err)
+	}
+	if limits.SingleTransaction > 0 && amount > limits.SingleTransaction {
+		updateTransactionStatus(Failed)
+		return Failed, ErrSingleTransactionExceeded
+	}
+
+	queryBankAccount := `SELECT id, balance, currency, status FROM bank_accounts WHERE id = $1`
+
+	var bankAccount struct {
+		ID       uint
+		Balance  float64
+		Currency string
+		Status   AccountStatus
+	}
+	err = s.db.QueryRow(queryBankAccount, card.BankAccountID).Scan(&bankAccount.ID, &bankAccount.Balance, &bankAccount.Currency, &bankAccount.Status)
+	if err != nil {
+		fmt.Println(err)
+		updateTransactionStatus(Error)
+		return Error, fmt.Errorf("failed to fetch bank account: %w", err)
+	}
+
+	if bankAccount.Status != Active {
+		updateTransactionStatus(Failed)
+		return Failed, ErrAccountNotActive
+	}
+
+	if bankAccount.Currency != currency {
+		updateTransactionStatus(Failed)
+		return Failed, ErrCurrencyMismatch
+	}
+	if err := checkFunds(limits, bankAccount.Balance, amount); err != nil {
+		updateTransactionStatus(Failed)
+		return Failed, err
+	}
+	// A successful payment is recorded in the same SQL transaction as its
+	// ledger entries; declines and errors roll it back and are recorded here
+	status, err := s.capturePayment(acquirerOrderId, card, limits, bankAccount.ID, creditAccount, currency, amount)
+	if status != Successful {
+		updateTransactionStatus(status)
+	}
+	return status, err
+}
+
+// capturePayment moves a card payment from the payer's account to the
+// credited ledger account, re-checking funds and daily limits under the
+// payer's account lock
+func (s *service) capturePayment(acquirerOrderId uuid.UUID, card Card, limits SpendingLimits, payerAccountId uint, creditAccount string, currency string, amount float32) (TransactionStatus, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Error, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentBalance float64
+	err = tx.QueryRow(`SELECT balance FROM bank_accounts WHERE id = $1 FOR UPDATE`, payerAccountId).Scan(&currentBalance)
+	if err != nil {
+		return Error, fmt.Errorf("failed to fetch balance: %w", err)
+	}
+
+	if err := checkFunds(limits, currentBalance, amount); err != nil {
+		return Failed, err
+	}
+
+	// Payments with the card are serialised by the account lock above
+	usage, err := dailyUsage(tx, card.ID)
+	if err != nil {
+		return Error, fmt.Errorf("failed to fetch card usage: %w", err)
+	}
+	if err := checkDailyLimits(limits, usage, amount); err != nil {
+		return Failed, err
+	}
+
+	_, err = postJournal(tx, EntryPayment, currency, &acquirerOrderId, paymentPostings(payerAccountId, creditAccount, amount)...)
+	if err != nil {
+		return Error, fmt.Errorf("failed to post payment: %w", err)
+	}
+	if err := postMerchantFee(tx, currency, &acquirerOrderId, creditAccount, amount); err != nil {
+		return Error, fmt.Errorf("failed to post merchant fee: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE transactions SET card_id = $1, status = $2 WHERE acquirer_order_id = $3`, card.ID, Successful, acquirerOrderId)
+	if err != nil {
+		return Error, fmt.Errorf("failed to record payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Error, fmt.Errorf("failed to commit payment: %w", err)
+	}
+
+	return Successful, nil
+}
+
+func isValidCardNumber(cardNumber string) bool {
+	sum := 0
+	nDigits := len(cardNumber)
+	parity := nDigits % 2
+
+	for i := 0; i < nDigits; i++ {
+		digit := int(cardNumber[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false // invalid character
+		}
+
+		// Double every second digit from the left
+		if i%2 == parity {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+var (
+	database   = os.Getenv("DB_DATABASE")
+	password   = os.Getenv("DB_PASSWORD")
+	username   = os.Getenv("DB_USERNAME")
+	port       = os.Getenv("DB_HOST_PORT")
+	host       = os.Getenv("DB_HOST")
+	schema     = os.Getenv("DB_SCHEMA")
+	dbInstance *service
+)
+
+func New() Service {
+	// Reuse Connection
+	if dbInstance != nil {
+		return dbInstance
+	}
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schema)
+	db, err := sql.Open("pgx", connStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dbInstance = &service{
+		db: db,
+	}
+	return dbInstance
+}
+
+// Health checks the health of the database connection by pinging the database.
+// It returns a map with keys indicating various health statistics.
+func (s *service) Health() map[string]string {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	stats := make(map[string]string)
+
+	// Ping the database
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		stats["status"] = "down"
+		stats["error"] = fmt.Sprintf("db down: %v", err)
+		log.Fatalf("db down: %v", err) // Log the error and terminate the program
+		return stats
+	}
+
+	// Database is up, add more statistics
+	stats["status"] = "up"
+	stats["message"] = "It's healthy"
+
+	// Get database stats (like open connections, in use, idle, etc.)
+	dbStats := s.db.Stats()
+	stats["open_connections"] = strconv.Itoa(dbStats.OpenConnections)
+	stats["in_use"] = strconv.Itoa(dbStats.InUse)
+	stats["idle"] = strconv.Itoa(dbStats.Idle)
+	stats["wait_count"] = strconv.FormatInt(dbStats.WaitCount, 10)
+	stats["wait_duration"] = dbStats.WaitDuration.String()
+	stats["max_idle_closed"] = strconv.FormatInt(dbStats.MaxIdleClosed, 10)
+	stats["max_lifetime_closed"] = strconv.FormatInt(dbStats.MaxLifetimeClosed, 10)
+
+	// Evaluate stats to provide a health message
+	if dbStats.OpenConnections > 40 { // Assuming 50 is the max for this example
+		stats["message"] = "The database is experiencing heavy load."
+	}
+
+	if dbStats.WaitCount > 1000 {
+		stats["message"] = "The database has a high number of wait events, indicating potential bottlenecks."
+	}
+
+	if dbStats.MaxIdleClosed > int64(dbStats.OpenConnections)/2 {
+		stats["message"] = "Many idle connections are being closed, consider revising the connection pool settings."
+	}
+
+	if dbStats.MaxLifetimeClosed > int64(dbStats.OpenConnections)/2 {
+		stats["message"] = "Many connections are being closed due to max lifetime, consider increasing max lifetime or revising the connection usage pattern."
+	}
+
+	return stats
+}
+
+// Close closes the database connection.
+// It logs a message indicating the disconnection from the specific database.
+// If the connection is successfully closed, it returns nil.
+// If an error occurs while closing the connection, it returns the error.
+func (s *service) Close() error {
+	log.Printf("Disconnected from database: %s", database)
+	return s.db.Close()
+}
+
+func Connect() {
+	db, err := gorm.Open(postgres.Open(fmt.Sprintf("postgres://%s:%s@%s:%s/%s", username, password, host, port, database)), &gorm.Config{})
+	if err != nil {
+		panic(err)
+	}
+	err1 := db.AutoMigrate(&BankClient{})
+	err2 := db.AutoMigrate(&BankAccount{})
+	err3 := db.AutoMigrate(&Card{})
+	err4 := db.AutoMigrate(&Transaction{})
+	err5 := db.AutoMigrate(&Merchant{})
+	err6 := db.AutoMigrate(&CardTypeLimit{})
+	err7 := db.AutoMigrate(&LedgerEntry{})
+	err8 := db.AutoMigrate(&Settlement{})
+	err9 := db.AutoMigrate(&Hold{})
+	if err1 != nil && err2 != nil && err3 != nil && err4 != nil && err5 != nil && err6 != nil && err7 != nil && err8 != nil && err9 != nil {
+		return
+	}
+	if err := openLedger(db); err != nil {
+		log.Printf("failed to post opening balances to the ledger: %v", err)
+	}
+	if err := encryptPlaintextPANs(db); err != nil {
+		log.Printf("failed to encrypt plaintext card numbers: %v", err)
+	}
+	if err := backfillPANIndex(db); err != nil {
+		// Card lookups by PAN would be ambiguous, so refuse to start
+		panic(fmt.Errorf("failed to backfill card PAN index: %w", err))
+	}
+	//DB = db
+}
+
+// encryptPlaintextPANs encrypts, indexes and masks the card numbers stored
+// in plain text before PANs were encrypted, and erases the plaintext
+func encryptPlaintextPANs(db *gorm.DB) error {
+	var cards []Card
+	result := db.Where("card_number <> '' AND (encrypted_pan IS NULL OR encrypted_pan = '')").FindInBatches(&cards, 500, func(tx *gorm.DB, batch int) error {
+		for _, card := range cards {
+			encryptedPAN, err := Encrypt(card.CardNumber)
+			if err != nil {
+				return fmt.Errorf("card %d: %w", card.ID, err)
+			}
+			panIndex, err := BlindIndex(card.CardNumber)
+			if err != nil {
+				return fmt.Errorf("card %d: %w", card.ID, err)
+			}
+
+			err = tx.Model(&Card{}).Where("id = ?", card.ID).Updates(map[string]any{
+				"encrypted_pan": encryptedPAN,
+				"key_version":   ActiveKeyVersion(),
+				"pan_index":     panIndex,
+				"masked_pan":    maskPAN(card.CardNumber),
+				"card_number":   "",
+			}).Error
+			if err != nil {
+				return fmt.Errorf("card %d: %w", card.ID, err)
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Encrypted the plaintext PAN of %d cards", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillPANIndex sets the blind index and masked PAN of cards stored
+// before either existed, decrypting each of their PANs once. It fails if two
+// cards share a PAN, which the unique index on pan_index rules out.
+func backfillPANIndex(db *gorm.DB) error {
+	var cards []Card
+	result := db.Where("pan_index IS NULL OR pan_index = '' OR masked_pan IS NULL OR masked_pan = ''").FindInBatches(&cards, 500, func(tx *gorm.DB, batch int) error {
+		for _, card := range cards {
+			pan, err := Decrypt(card.EncryptedPAN)
+			if err != nil {
+				return fmt.Errorf("card %d: %w", card.ID, err)
+			}
+
+			panIndex, err := BlindIndex(pan)
+			if err != nil {
+				return err
+			}
+
+			err = tx.Model(&Card{}).Where("id = ?", card.ID).Updates(map[string]any{"pan_index": panIndex, "masked_pan": maskPAN(pan)}).Error
+			if err != nil {
+				return fmt.Errorf("card %d: %w", card.ID, err)
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled PAN index of %d cards", result.RowsAffected)
+	}
+
+	var duplicates []struct {
+		PANIndex string
+		Cards    string
+	}
+	err := db.Raw(`SELECT pan_index, string_agg(id::text, ', ' ORDER BY id) AS cards FROM cards
+	               WHERE pan_index <> '' GROUP BY pan_index HAVING COUNT(*) > 1`).Scan(&duplicates).Error
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate PANs: %w", err)
+	}
+	if len(duplicates) > 0 {
+		for _, duplicate := range duplicates {
+			log.Printf("Cards %s share a PAN", duplicate.Cards)
+		}
+		return fmt.Errorf("%d PANs are shared by more than one card", len(duplicates))
+	}
+
+	// Databases migrated before the index was unique still have the plain one
+	if db.Migrator().HasIndex(&Card{}, "idx_cards_pan_index") {
+		if err := db.Migrator().DropIndex(&Card{}, "idx_cards_pan_index"); err != nil {
+			return fmt.Errorf("failed to drop the non-unique PAN index: %w", err)
+		}
+	}
+	if !db.Migrator().HasIndex(&Card{}, "idx_cards_pan_index_unique") {
+		if err := db.Migrator().CreateIndex(&Card{}, "idx_cards_pan_index_unique"); err != nil {
+			return fmt.Errorf("failed to create the unique PAN index: %w", err)
+		}
+	}
+	return nil
+}
@@ -36,13 +36,14 @@ func mustStartPostgresContainer() (func(context.Context) error, error) {
 	database = dbName
 	password = dbPwd
 	username = dbUser
+	schema = "public"
 
 	dbHost, err := dbContainer.Host(context.Background())
 	if err != nil {
 		return dbContainer.Terminate, err
 	}
 
-	dbPort, err := dbContainer.MappedPort(context.Background(), "5433/tcp")
+	dbPort, err := dbContainer.MappedPort(context.Background(), "5432/tcp")
 	if err != nil {
 		return dbContainer.Terminate, err
 	}
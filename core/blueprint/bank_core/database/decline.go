@@ -0,0 +1,74 @@
+package database
+
+import "errors"
+
+// DeclineCode is the ISO 8583 response code sent with a declined payment
+type DeclineCode string
+
+const (
+	DeclineInvalidTransaction DeclineCode = "12" // The card's account cannot pay in the currency of the payment
+	DeclineNoSuchCard         DeclineCode = "14" // No card of the bank has the PAN
+	DeclineInsufficientFunds  DeclineCode = "51" // Balance, or credit line, does not cover the payment
+	DeclineExpiredCard        DeclineCode = "54" // Expiry does not match the card, or the card expired
+	DeclineNotPermitted       DeclineCode = "57" // Refused by a control the account holder set
+	DeclineExceedsLimit       DeclineCode = "61" // Above a per-transaction or daily amount limit
+	DeclineRestrictedCard     DeclineCode = "62" // Card blocked or replaced by the bank
+	DeclineExceedsFrequency   DeclineCode = "65" // Above the daily number of payments
+	DeclineAccountNotActive   DeclineCode = "78" // Account closed or blocked
+	DeclineInvalidCVV         DeclineCode = "N7" // CVV2 does not match the card
+)
+
+var (
+	ErrNoSuchCard                = errors.New("no such card")
+	ErrExpiredCard               = errors.New("card expired or expiry does not match")
+	ErrCardRestricted            = errors.New("card is blocked")
+	ErrCardFrozen                = errors.New("card is frozen by the account holder")
+	ErrOnlineDisabled            = errors.New("online payments are disabled for the card")
+	ErrExceedsCardLimit          = errors.New("amount exceeds the card's transaction limit")
+	ErrSingleTransactionExceeded = errors.New("amount exceeds the single transaction limit")
+	ErrDailyAmountExceeded       = errors.New("daily spending limit reached")
+	ErrDailyCountExceeded        = errors.New("daily number of payments reached")
+	ErrCreditLineExceeded        = errors.New("credit line exceeded")
+	ErrInsufficientFunds         = errors.New("insufficient funds")
+	ErrAccountNotActive          = errors.New("bank account is closed or blocked")
+	ErrInvalidCVV                = errors.New("invalid CVV2")
+	ErrCurrencyMismatch          = errors.New("payment currency differs from the account currency")
+)
+
+// declines gives each decline its ISO code and a reason that tells apart
+// declines sharing a code
+var declines = []struct {
+	err    error
+	code   DeclineCode
+	reason string
+}{
+	{ErrNoSuchCard, DeclineNoSuchCard, "no_such_card"},
+	{ErrExpiredCard, DeclineExpiredCard, "expired_card"},
+	{ErrCardRestricted, DeclineRestrictedCard, "card_blocked"},
+	{ErrCardFrozen, DeclineNotPermitted, "card_frozen"},
+	{ErrOnlineDisabled, DeclineNotPermitted, "online_payments_disabled"},
+	{ErrExceedsCardLimit, DeclineExceedsLimit, "holder_transaction_limit"},
+	{ErrSingleTransactionExceeded, DeclineExceedsLimit, "single_transaction_limit"},
+	{ErrDailyAmountExceeded, DeclineExceedsLimit, "daily_amount_limit"},
+	{ErrDailyCountExceeded, DeclineExceedsFrequency, "daily_count_limit"},
+	{ErrCreditLineExceeded, DeclineInsufficientFunds, "credit_line_exceeded"},
+	{ErrInsufficientFunds, DeclineInsufficientFunds, "insufficient_funds"},
+	{ErrAccountNotActive, DeclineAccountNotActive, "account_not_active"},
+	{ErrInvalidCVV, DeclineInvalidCVV, "invalid_cvv"},
+	{ErrCurrencyMismatch, DeclineInvalidTransaction, "currency_mismatch"},
+}
+
+// DeclineFor returns the decline code and reason of a payment error, or
+// empty values if the error is not a decline
+func DeclineFor(err error) (DeclineCode, string) {
+	var issuerDecline *IssuerDecline
+	if errors.As(err, &issuerDecline) {
+		return issuerDecline.Code, issuerDecline.Reason
+	}
+	for _, decline := range declines {
+		if errors.Is(err, decline.err) {
+			return decline.code, decline.reason
+		}
+	}
+	return "", ""
+}
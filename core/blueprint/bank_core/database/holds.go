@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+	ErrHoldReleased = errors.New("hold was already released")
+	ErrInvalidHold  = errors.New("hold amount must be positive")
+)
+
+// HoldRequest puts an amount of a bank account on hold
+type HoldRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Reason string  `json:"reason"`
+}
+
+const holdColumns = `id, bank_account_id, amount, currency, reason, journal_id, created_at, released_at`
+
+func scanHold(row rowScanner) (*Hold, error) {
+	var hold Hold
+	err := row.Scan(&hold.ID, &hold.BankAccountId, &hold.Amount, &hold.Currency, &hold.Reason,
+		&hold.JournalId, &hold.CreatedAt, &hold.ReleasedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// PlaceHold moves an amount of a bank account's balance to its hold ledger
+// account, so it cannot be spent until the hold is released
+func (s *service) PlaceHold(bankAccountId uint, req HoldRequest) (*Hold, error) {
+	if cents(req.Amount) <= 0 {
+		return nil, ErrInvalidHold
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	var currency string
+	var status AccountStatus
+	err = tx.QueryRow(`SELECT balance, currency, status FROM bank_accounts WHERE id = $1 FOR UPDATE`, bankAccountId).
+		Scan(&balance, &currency, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bank account: %w", err)
+	}
+	if status != Active {
+		return nil, ErrAccountNotActive
+	}
+	if cents(balance) < cents(req.Amount) {
+		return nil, ErrInsufficientFunds
+	}
+
+	journalId, err := postJournal(tx, EntryHold, currency, nil,
+		Posting{Account: customerAccount(bankAccountId), Amount: -req.Amount},
+		Posting{Account: holdAccount(bankAccountId), Amount: req.Amount},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := &Hold{
+		ID:            uuid.New(),
+		BankAccountId: bankAccountId,
+		Amount:        float64(cents(req.Amount)) / 100,
+		Currency:      currency,
+		Reason:        req.Reason,
+		JournalId:     journalId,
+		CreatedAt:     time.Now(),
+	}
+	_, err = tx.Exec(`INSERT INTO holds (`+holdColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		hold.ID, hold.BankAccountId, hold.Amount, hold.Currency, hold.Reason, hold.JournalId, hold.CreatedAt, hold.ReleasedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit hold: %w", err)
+	}
+	return hold, nil
+}
+
+// ReleaseHold returns the amount of a hold to the bank account's balance
+func (s *service) ReleaseHold(holdId uuid.UUID) (*Hold, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hold, err := scanHold(tx.QueryRow(`SELECT `+holdColumns+` FROM holds WHERE id = $1 FOR UPDATE`, holdId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hold: %w", err)
+	}
+	if hold.ReleasedAt != nil {
+		return nil, ErrHoldReleased
+	}
+
+	_, err = postJournal(tx, EntryHoldRelease, hold.Currency, nil,
+		Posting{Account: holdAccount(hold.BankAccountId), Amount: -hold.Amount},
+		Posting{Account: customerAccount(hold.BankAccountId), Amount: hold.Amount},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE holds SET released_at = $1 WHERE id = $2`, now, holdId); err != nil {
+		return nil, fmt.Errorf("failed to release hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit hold release: %w", err)
+	}
+	hold.ReleasedAt = &now
+	return hold, nil
+}
+
+// GetHolds returns the holds of a bank account, newest first; released holds
+// are only included if asked for
+func (s *service) GetHolds(bankAccountId uint, includeReleased bool) ([]Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE bank_account_id = $1`
+	if !includeReleased {
+		query += ` AND released_at IS NULL`
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at DESC`, bankAccountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+	return holds, rows.Err()
+}
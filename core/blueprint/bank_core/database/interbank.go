@@ -0,0 +1,223 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthorizationRequest is a card payment the acquiring bank sends to the card's
+// issuing bank through PCC
+type AuthorizationRequest struct {
+	PccTransactionId     uuid.UUID `json:"pccTransactionId"` // Set by PCC
+	AcquirerId           uint      `json:"acquirerId"`       // Set by the acquirer's PCC client
+	AcquirerOrderId      uuid.UUID `json:"acquirerOrderId" binding:"required"`
+	AcquirerTimestamp    time.Time `json:"acquirerTimestamp" binding:"required"`
+	CardNumber           string    `json:"cardNumber" binding:"required,numeric"`
+	ExpiryDate           time.Time `json:"expiryDate" binding:"required"`
+	CardVerificationCode string    `json:"cardVerificationCode" binding:"required"`
+	Amount               float32   `json:"amount" binding:"required,gt=0"`
+	Currency             string    `json:"currency" binding:"required"`
+}
+
+// AuthorizationResponse is the issuing bank's answer, relayed by PCC
+type AuthorizationResponse struct {
+	AcquirerOrderId  uuid.UUID         `json:"acquirerOrderId"`
+	PccTransactionId uuid.UUID         `json:"pccTransactionId"`
+	IssuerId         uint              `json:"issuerId"`
+	Status           TransactionStatus `json:"status"`
+	DeclineCode      DeclineCode       `json:"declineCode,omitempty"`
+	DeclineReason    string            `json:"declineReason,omitempty"`
+}
+
+// Issuer authorizes payments with cards the bank did not issue
+type Issuer interface {
+	Authorize(req AuthorizationRequest) (*AuthorizationResponse, error)
+}
+
+// IssuerDecline is a payment declined by another bank's issuer
+type IssuerDecline struct {
+	Code   DeclineCode
+	Reason string
+}
+
+func (e *IssuerDecline) Error() string {
+	return fmt.Sprintf("declined by the issuer with code %s", e.Code)
+}
+
+// IsOwnBIN reports whether a PAN falls in the bank's BIN range. Without a
+// configured range every card is treated as the bank's own.
+func IsOwnBIN(pan string) bool {
+	low, high, err := parseBINRange(binRange)
+	if err != nil || len(pan) < len(low) {
+		return true
+	}
+	bin := pan[:len(low)]
+	return bin >= low && bin <= high
+}
+
+// PayWithForeignCard takes a payment for one of the bank's merchants with a
+// card of another bank. The issuer authorizes it through PCC; once approved
+// the merchant is credited against the PCC settlement account.
+func (s *service) PayWithForeignCard(issuer Issuer, acquirerOrderId uuid.UUID, currency string, amount float32, cardNumber string, expiryDate time.Time, cvv string, merchantId uint) (TransactionStatus, error) {
+	merchantAccountId, status, err := s.merchantAccount(merchantId)
+	if err != nil {
+		s.setTransactionStatus(acquirerOrderId, status)
+		return status, err
+	}
+
+	response, err := issuer.Authorize(AuthorizationRequest{
+		AcquirerOrderId:      acquirerOrderId,
+		AcquirerTimestamp:    time.Now(),
+		CardNumber:           cardNumber,
+		ExpiryDate:           expiryDate,
+		CardVerificationCode: cvv,
+		Amount:               amount,
+		Currency:             currency,
+	})
+	if err != nil {
+		s.setTransactionStatus(acquirerOrderId, Error)
+		return Error, fmt.Errorf("authorization through PCC failed: %w", err)
+	}
+
+	if response.Status != Successful {
+		s.setTransactionStatus(acquirerOrderId, response.Status)
+		if response.DeclineCode != "" {
+			return response.Status, &IssuerDecline{Code: response.DeclineCode, Reason: response.DeclineReason}
+		}
+		return response.Status, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.setTransactionStatus(acquirerOrderId, Error)
+		return Error, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	postings := []Posting{
+		{Account: PCCSettlementAccount, Amount: -float64(amount)},
+		{Account: customerAccount(merchantAccountId), Amount: float64(amount)},
+	}
+	if _, err = postJournal(tx, EntryPayment, currency, &acquirerOrderId, postings...); err == nil {
+		err = postMerchantFee(tx, currency, &acquirerOrderId, customerAccount(merchantAccountId), amount)
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE transactions SET status = $1 WHERE acquirer_order_id = $2`, Successful, acquirerOrderId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		// The issuer has already charged the card, so the payment has to be
+		// reconciled at settlement
+		s.setTransactionStatus(acquirerOrderId, Error)
+		return Error, fmt.Errorf("approved payment %s could not be credited: %w", response.PccTransactionId, err)
+	}
+
+	return Successful, nil
+}
+
+// AuthorizeRoutedPayment authorizes a payment with one of the bank's cards
+// that another bank acquired. The amount is charged to the card's account
+// against the PCC settlement account.
+func (s *service) AuthorizeRoutedPayment(req AuthorizationRequest) (TransactionStatus, error) {
+	if len(req.CardNumber) < 4 {
+		return Failed, errors.New("invalid card number")
+	}
+
+	// PCC may resend an authorization it got no answer to; the card is only
+	// charged once
+	var status TransactionStatus
+	err := s.db.QueryRow(`SELECT status FROM transactions WHERE acquirer_order_id = $1`, req.AcquirerOrderId).Scan(&status)
+	if err == nil {
+		return status, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Error, fmt.Errorf("failed to look up routed payment: %w", err)
+	}
+
+	transaction := Transaction{
+		TransactionId:     req.PccTransactionId,
+		AcquirerOrderId:   req.AcquirerOrderId,
+		AcquirerTimestamp: req.AcquirerTimestamp,
+		Status:            InProgress,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		Timestamp:         time.Now(),
+		PartialCardNumber: req.CardNumber[len(req.CardNumber)-4:],
+	}
+	if err := s.WriteTransaction(transaction); err != nil {
+		return Error, fmt.Errorf("failed to record routed payment: %w", err)
+	}
+
+	return s.chargeCard(req.AcquirerOrderId, req.CardNumber, req.ExpiryDate, req.CardVerificationCode, req.Currency, req.Amount, PCCSettlementAccount)
+}
+
+// AdviceMessage is a payment with one of the bank's cards that PCC approved
+// in stand-in while the bank was unavailable
+type AdviceMessage struct {
+	PccTransactionId  uuid.UUID `json:"pccTransactionId" binding:"required"`
+	AcquirerId        uint      `json:"acquirerId"`
+	AcquirerOrderId   uuid.UUID `json:"acquirerOrderId" binding:"required"`
+	AcquirerTimestamp time.Time `json:"acquirerTimestamp"`
+	CardNumber        string    `json:"cardNumber" binding:"required,numeric,min=12"`
+	ExpiryDate        time.Time `json:"expiryDate"`
+	Amount            float32   `json:"amount" binding:"required,gt=0"`
+	Currency          string    `json:"currency" binding:"required"`
+	ApprovedAt        time.Time `json:"approvedAt"`
+}
+
+// PostAdvice charges a stand-in approval to the card's account against the
+// PCC settlement account. The network has already approved the payment, so
+// it is posted regardless of the card's limits and the account's balance.
+// An advice for a payment the bank already charged is ignored.
+func (s *service) PostAdvice(advice AdviceMessage) error {
+	panIndex, err := BlindIndex(advice.CardNumber)
+	if err != nil {
+		return fmt.Errorf("failed to index card number: %w", err)
+	}
+
+	var cardId, bankAccountId uint
+	err = s.db.QueryRow(`SELECT id, bank_account_id FROM cards WHERE pan_index = $1`, panIndex).Scan(&cardId, &bankAccountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCardNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch card: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status TransactionStatus
+	err = tx.QueryRow(`SELECT status FROM transactions WHERE acquirer_order_id = $1 FOR UPDATE`, advice.AcquirerOrderId).Scan(&status)
+	if err == nil && status == Successful {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.Exec(`INSERT INTO transactions (transaction_id, acquirer_order_id, acquirer_timestamp, status, amount, currency, timestamp, partial_card_number)
+		                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			advice.PccTransactionId, advice.AcquirerOrderId, advice.AcquirerTimestamp, InProgress, advice.Amount, advice.Currency,
+			advice.ApprovedAt, advice.CardNumber[len(advice.CardNumber)-4:])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record advised payment: %w", err)
+	}
+
+	if _, err := postJournal(tx, EntryPayment, advice.Currency, &advice.AcquirerOrderId, paymentPostings(bankAccountId, PCCSettlementAccount, advice.Amount)...); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE transactions SET status = $1, card_id = $2 WHERE acquirer_order_id = $3`, Successful, cardId, advice.AcquirerOrderId)
+	if err != nil {
+		return fmt.Errorf("failed to update advised payment: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ledger accounts of the bank itself. Each bank account has a customer:<id>
+// ledger account for its balance and a hold:<id> one for funds on hold.
+const (
+	BankEquityAccount = "bank:equity" // Counterpart of balances that predate the ledger
+	BankFeesAccount   = "bank:fees"   // Fees the bank charges its clients
+	// What the bank owes to, or is owed by, other banks for card payments
+	// routed through PCC, until they are settled
+	PCCSettlementAccount = "settlement:pcc"
+	// The bank's account at the settlement bank, through which net positions
+	// with PCC are paid
+	SettlementNostroAccount = "nostro:settlement"
+)
+
+// customerAccount is the ledger account behind a bank account's balance
+func customerAccount(bankAccountId uint) string {
+	return "customer:" + strconv.FormatUint(uint64(bankAccountId), 10)
+}
+
+// holdAccount holds the funds of a bank account that are on hold
+func holdAccount(bankAccountId uint) string {
+	return "hold:" + strconv.FormatUint(uint64(bankAccountId), 10)
+}
+
+// Posting is one side of a journal: a signed amount posted to a ledger account
+type Posting struct {
+	Account string
+	Amount  float64
+}
+
+// cents converts an amount to whole cents, so balances are compared exactly
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// postJournal writes postings that sum to zero as one journal and applies
+// them to the balances of the bank accounts they touch. It must run in the
+// transaction that changes the business state the journal records.
+func postJournal(tx *sql.Tx, kind EntryKind, currency string, transactionId *uuid.UUID, postings ...Posting) (uuid.UUID, error) {
+	var sum int64
+	for _, posting := range postings {
+		sum += cents(posting.Amount)
+	}
+	if len(postings) < 2 || sum != 0 {
+		return uuid.Nil, fmt.Errorf("unbalanced %s journal: postings sum to %.2f", kind, float64(sum)/100)
+	}
+
+	journalId := uuid.New()
+	now := time.Now()
+	for _, posting := range postings {
+		amount := float64(cents(posting.Amount)) / 100
+
+		_, err := tx.Exec(`INSERT INTO ledger_entries (journal_id, kind, account, amount, currency, transaction_id, created_at)
+		                   VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			journalId, kind, posting.Account, amount, currency, transactionId, now)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to post to %s: %w", posting.Account, err)
+		}
+
+		if id, ok := strings.CutPrefix(posting.Account, "customer:"); ok {
+			bankAccountId, err := strconv.ParseUint(id, 10, 32)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("invalid ledger account %s", posting.Account)
+			}
+			_, err = tx.Exec(`UPDATE bank_accounts SET balance = balance + $1 WHERE id = $2`, amount, bankAccountId)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("failed to update balance of account %d: %w", bankAccountId, err)
+			}
+		}
+	}
+
+	return journalId, nil
+}
+
+// paymentPostings moves a card payment from the payer's account to the
+// merchant's, or to the PCC settlement account when the merchant banks elsewhere
+func paymentPostings(payerAccountId uint, creditAccount string, amount float32) []Posting {
+	return []Posting{
+		{Account: customerAccount(payerAccountId), Amount: -float64(amount)},
+		{Account: creditAccount, Amount: float64(amount)},
+	}
+}
+
+// merchantFeePercent is the fee the bank charges its merchants on the card
+// payments it credits to them, e.g. "1.5"; unset charges no fee
+var merchantFeePercent = os.Getenv("BANK_MERCHANT_FEE_PERCENT")
+
+// merchantFee returns the merchant fee on a card payment in cents
+func merchantFee(amount float32) (int64, error) {
+	if merchantFeePercent == "" {
+		return 0, nil
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSpace(merchantFeePercent), 64)
+	if err != nil || percent < 0 || percent >= 100 {
+		return 0, fmt.Errorf("invalid BANK_MERCHANT_FEE_PERCENT %q", merchantFeePercent)
+	}
+	return int64(math.Round(float64(cents(float64(amount))) * percent / 100)), nil
+}
+
+// postMerchantFee charges the merchant fee of a card payment credited to a
+// bank account as a fee journal from it to bank:fees. Payments credited to
+// other banks through PCC carry no fee here.
+func postMerchantFee(tx *sql.Tx, currency string, acquirerOrderId *uuid.UUID, creditAccount string, amount float32) error {
+	if _, ok := customerAccountId(creditAccount); !ok {
+		return nil
+	}
+	fee, err := merchantFee(amount)
+	if err != nil || fee == 0 {
+		return err
+	}
+	_, err = postJournal(tx, EntryFee, currency, acquirerOrderId,
+		Posting{Account: creditAccount, Amount: -float64(fee) / 100},
+		Posting{Account: BankFeesAccount, Amount: float64(fee) / 100},
+	)
+	return err
+}
+
+// AccountDrift is a bank account whose balance differs from its ledger account
+type AccountDrift struct {
+	BankAccountId uint    `json:"bankAccountId"`
+	Balance       float64 `json:"balance"`
+	LedgerBalance float64 `json:"ledgerBalance"`
+	Difference    float64 `json:"difference"`
+}
+
+// UnbalancedJournal is a journal whose entries do not sum to zero
+type UnbalancedJournal struct {
+	JournalId uuid.UUID `json:"journalId"`
+	Sum       float64   `json:"sum"`
+}
+
+// LedgerReport is the result of a ledger consistency check
+type LedgerReport struct {
+	CheckedAt          time.Time           `json:"checkedAt"`
+	Consistent         bool                `json:"consistent"`
+	Drift              []AccountDrift      `json:"drift"`
+	UnbalancedJournals []UnbalancedJournal `json:"unbalancedJournals"`
+}
+
+// CheckLedger compares every bank account balance with its ledger account
+// and looks for journals that do not balance
+func (s *service) CheckLedger() (*LedgerReport, error) {
+	report := &LedgerReport{
+		CheckedAt:          time.Now(),
+		Drift:              []AccountDrift{},
+		UnbalancedJournals: []UnbalancedJournal{},
+	}
+
+	driftQuery := `SELECT a.id, a.balance, COALESCE(SUM(e.amount), 0)
+	               FROM bank_accounts a
+	               LEFT JOIN ledger_entries e ON e.account = 'customer:' || a.id
+	               GROUP BY a.id, a.balance
+	               HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+	               ORDER BY a.id`
+	rows, err := s.db.Query(driftQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare balances with the ledger: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var drift AccountDrift
+		if err := rows.Scan(&drift.BankAccountId, &drift.Balance, &drift.LedgerBalance); err != nil {
+			return nil, err
+		}
+		drift.Difference = float64(cents(drift.Balance)-cents(drift.LedgerBalance)) / 100
+		report.Drift = append(report.Drift, drift)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	journalQuery := `SELECT journal_id, SUM(amount) FROM ledger_entries
+	                 GROUP BY journal_id HAVING SUM(amount) <> 0`
+	journalRows, err := s.db.Query(journalQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ledger journals: %w", err)
+	}
+	defer journalRows.Close()
+
+	for journalRows.Next() {
+		var journal UnbalancedJournal
+		if err := journalRows.Scan(&journal.JournalId, &journal.Sum); err != nil {
+			return nil, err
+		}
+		report.UnbalancedJournals = append(report.UnbalancedJournals, journal)
+	}
+	if err := journalRows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Consistent = len(report.Drift) == 0 && len(report.UnbalancedJournals) == 0
+	return report, nil
+}
+
+// openLedger posts the balance of every bank account that has no ledger
+// entries yet as an opening balance against the bank's equity, so balances
+// set before the ledger existed are accounted for
+func openLedger(db *gorm.DB) error {
+	var accounts []BankAccount
+	err := db.Where("balance <> 0 AND NOT EXISTS (SELECT 1 FROM ledger_entries e WHERE e.account = 'customer:' || bank_accounts.id)").
+		Find(&accounts).Error
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		journalId := uuid.New()
+		balance := float64(cents(account.Balance)) / 100
+		entries := []LedgerEntry{
+			{JournalId: journalId, Kind: EntryOpeningBalance, Account: customerAccount(account.ID), Amount: balance, Currency: account.Currency},
+			{JournalId: journalId, Kind: EntryOpeningBalance, Account: BankEquityAccount, Amount: -balance, Currency: account.Currency},
+		}
+		if err := db.Create(&entries).Error; err != nil {
+			return fmt.Errorf("account %d: %w", account.ID, err)
+		}
+	}
+
+	if len(accounts) > 0 {
+		log.Printf("Posted opening balances of %d bank accounts to the ledger", len(accounts))
+	}
+	return nil
+}
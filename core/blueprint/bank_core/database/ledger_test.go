@@ -0,0 +1,81 @@
+package database
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPaymentPostsMerchantFee(t *testing.T) {
+	t.Setenv("BANK_ENCRYPTION_KEYS", "1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("BANK_PAN_INDEX_KEY", "test-pan-index-key-of-32-bytes!!")
+	if err := LoadKeyring(); err != nil {
+		t.Fatal(err)
+	}
+	binRange, cardVerificationKey, merchantFeePercent = "411111-411199", "0123456789abcdef0123456789abcdef", "1.5"
+	defer func() { binRange, cardVerificationKey, merchantFeePercent = "", "", "" }()
+
+	Connect()
+	srv := New().(*service)
+
+	var clientId, payerAccountId, merchantAccountId uint
+	if err := srv.db.QueryRow(`INSERT INTO bank_clients (name, surname) VALUES ('Marko', 'Marković') RETURNING id`).Scan(&clientId); err != nil {
+		t.Fatal(err)
+	}
+	insertAccount := `INSERT INTO bank_accounts (account_number, user_id, balance, currency, date_created, status)
+	                  VALUES ($1, $2, 0, 'RSD', $3, $4) RETURNING id`
+	if err := srv.db.QueryRow(insertAccount, "265-0000000000002-00", clientId, time.Now(), Active).Scan(&payerAccountId); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.db.QueryRow(insertAccount, "265-0000000000003-00", clientId, time.Now(), Active).Scan(&merchantAccountId); err != nil {
+		t.Fatal(err)
+	}
+
+	issued, err := srv.IssueCard(payerAccountId, Credit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := srv.GetCard(issued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquirerOrderId := uuid.New()
+	status, err := srv.capturePayment(acquirerOrderId, *card, SpendingLimits{CreditLine: 1000}, payerAccountId,
+		customerAccount(merchantAccountId), "RSD", 100.10)
+	if status != Successful || err != nil {
+		t.Fatalf("payment failed: %d (%v)", status, err)
+	}
+
+	balances := map[uint]float64{}
+	for _, id := range []uint{payerAccountId, merchantAccountId} {
+		var balance float64
+		if err := srv.db.QueryRow(`SELECT balance FROM bank_accounts WHERE id = $1`, id).Scan(&balance); err != nil {
+			t.Fatal(err)
+		}
+		balances[id] = balance
+	}
+	if balances[payerAccountId] != -100.10 || balances[merchantAccountId] != 98.60 {
+		t.Errorf("unexpected balances: payer %.2f, merchant %.2f", balances[payerAccountId], balances[merchantAccountId])
+	}
+
+	var fees float64
+	err = srv.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1 AND kind = $2 AND transaction_id = $3`,
+		BankFeesAccount, EntryFee, acquirerOrderId).Scan(&fees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees != 1.50 {
+		t.Errorf("expected a fee of 1.50, got %.2f", fees)
+	}
+
+	report, err := srv.CheckLedger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Consistent {
+		t.Errorf("ledger inconsistent after the payment: %+v", report)
+	}
+}
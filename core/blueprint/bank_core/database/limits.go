@@ -0,0 +1,223 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultCardTypeLimits apply to card types the bank has not configured
+var DefaultCardTypeLimits = map[CardType]SpendingLimits{
+	Debit:   {DailyAmount: 2000, DailyCount: 20, SingleTransaction: 1000},
+	Credit:  {DailyAmount: 5000, DailyCount: 30, SingleTransaction: 3000, CreditLine: 1000},
+	Prepaid: {DailyAmount: 500, DailyCount: 10, SingleTransaction: 250},
+}
+
+// SpendingUsage is what a card has spent since the start of the day (UTC)
+type SpendingUsage struct {
+	Amount float32 `json:"amount"`
+	Count  int     `json:"count"`
+}
+
+// LimitsOverview shows a card's limits and how much of each is left.
+// Remaining values are omitted for limits that are not set.
+type LimitsOverview struct {
+	CardId               uint           `json:"cardId"`
+	CardType             CardType       `json:"cardType"`
+	Limits               SpendingLimits `json:"limits"`
+	UsedToday            SpendingUsage  `json:"usedToday"`
+	RemainingDailyAmount *float32       `json:"remainingDailyAmount,omitempty"`
+	RemainingDailyCount  *int           `json:"remainingDailyCount,omitempty"`
+	MaxSingleTransaction *float32       `json:"maxSingleTransaction,omitempty"` // Lowest of the bank's and the holder's limit
+	AvailableFunds       float32        `json:"availableFunds"`                 // Balance plus the unused credit line
+	RemainingCreditLine  *float32       `json:"remainingCreditLine,omitempty"`
+	Currency             string         `json:"currency"`
+}
+
+// GetCardTypeLimits returns the spending limits of a card type
+func (s *service) GetCardTypeLimits(cardType CardType) (SpendingLimits, error) {
+	limits, ok := DefaultCardTypeLimits[cardType]
+	if !ok {
+		return SpendingLimits{}, fmt.Errorf("unknown card type %d", cardType)
+	}
+
+	query := `SELECT daily_amount, daily_count, single_transaction, credit_line FROM card_type_limits WHERE card_type = $1`
+	err := s.db.QueryRow(query, cardType).Scan(&limits.DailyAmount, &limits.DailyCount, &limits.SingleTransaction, &limits.CreditLine)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return SpendingLimits{}, fmt.Errorf("failed to fetch card type limits: %w", err)
+	}
+
+	if cardType != Credit {
+		limits.CreditLine = 0
+	}
+	return limits, nil
+}
+
+// SetCardTypeLimits sets the spending limits of a card type
+func (s *service) SetCardTypeLimits(cardType CardType, limits SpendingLimits) error {
+	if _, ok := DefaultCardTypeLimits[cardType]; !ok {
+		return fmt.Errorf("unknown card type %d", cardType)
+	}
+	if cardType != Credit {
+		limits.CreditLine = 0
+	}
+
+	query := `INSERT INTO card_type_limits (card_type, daily_amount, daily_count, single_transaction, credit_line)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (card_type) DO UPDATE
+	          SET daily_amount = EXCLUDED.daily_amount, daily_count = EXCLUDED.daily_count,
+	              single_transaction = EXCLUDED.single_transaction, credit_line = EXCLUDED.credit_line`
+
+	_, err := s.db.Exec(query, cardType, limits.DailyAmount, limits.DailyCount, limits.SingleTransaction, limits.CreditLine)
+	return err
+}
+
+// SetCardLimits sets the bank's spending limits of a single card. Limits
+// left out of the request inherit those of the card type.
+func (s *service) SetCardLimits(cardId uint, req CardLimitsRequest) (*Card, error) {
+	card, err := s.GetCard(cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	creditLine := req.CreditLine
+	if card.CardType != Credit {
+		creditLine = nil
+	}
+
+	query := `UPDATE cards SET daily_amount_limit = $1, daily_count_limit = $2, single_transaction_limit = $3, credit_line = $4
+	          WHERE id = $5`
+	_, err = s.db.Exec(query, req.DailyAmountLimit, req.DailyCountLimit, req.SingleTransactionLimit, creditLine, cardId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update card limits: %w", err)
+	}
+
+	card.DailyAmountLimit = req.DailyAmountLimit
+	card.DailyCountLimit = req.DailyCountLimit
+	card.SingleTransactionLimit = req.SingleTransactionLimit
+	card.CreditLine = creditLine
+	return card, nil
+}
+
+// cardLimits returns the limits that apply to a card: its own where set,
+// otherwise its card type's
+func (s *service) cardLimits(card Card) (SpendingLimits, error) {
+	limits, err := s.GetCardTypeLimits(card.CardType)
+	if err != nil {
+		return limits, err
+	}
+
+	if card.DailyAmountLimit != nil {
+		limits.DailyAmount = *card.DailyAmountLimit
+	}
+	if card.DailyCountLimit != nil {
+		limits.DailyCount = *card.DailyCountLimit
+	}
+	if card.SingleTransactionLimit != nil {
+		limits.SingleTransaction = *card.SingleTransactionLimit
+	}
+	if card.CreditLine != nil && card.CardType == Credit {
+		limits.CreditLine = *card.CreditLine
+	}
+	return limits, nil
+}
+
+func startOfDay(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dailyUsage sums the successful payments charged to a card today
+func dailyUsage(db rowQuerier, cardId uint) (SpendingUsage, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM transactions
+	          WHERE card_id = $1 AND status = $2 AND acquirer_timestamp >= $3`
+
+	var usage SpendingUsage
+	err := db.QueryRow(query, cardId, Successful, startOfDay(time.Now())).Scan(&usage.Count, &usage.Amount)
+	return usage, err
+}
+
+// checkDailyLimits declines a payment that would take the card past its
+// daily count or amount
+func checkDailyLimits(limits SpendingLimits, usage SpendingUsage, amount float32) error {
+	if limits.DailyCount > 0 && usage.Count+1 > limits.DailyCount {
+		return ErrDailyCountExceeded
+	}
+	if limits.DailyAmount > 0 && usage.Amount+amount > limits.DailyAmount {
+		return ErrDailyAmountExceeded
+	}
+	return nil
+}
+
+// checkFunds declines a payment the account cannot cover, counting the
+// credit line of credit cards
+func checkFunds(limits SpendingLimits, balance float64, amount float32) error {
+	if cents(balance)+cents(float64(limits.CreditLine)) >= cents(float64(amount)) {
+		return nil
+	}
+	if limits.CreditLine > 0 {
+		return ErrCreditLineExceeded
+	}
+	return ErrInsufficientFunds
+}
+
+// GetCardLimitsOverview returns the limits of one of the client's cards and
+// how much of each is left today
+func (s *service) GetCardLimitsOverview(clientId uint, cardId uint) (*LimitsOverview, error) {
+	card, err := s.GetClientCard(clientId, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := s.cardLimits(*card)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := dailyUsage(s.db, card.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch card usage: %w", err)
+	}
+
+	var balance float64
+	var currency string
+	err = s.db.QueryRow(`SELECT balance, currency FROM bank_accounts WHERE id = $1`, card.BankAccountID).Scan(&balance, &currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bank account: %w", err)
+	}
+
+	overview := &LimitsOverview{
+		CardId:         card.ID,
+		CardType:       card.CardType,
+		Limits:         limits,
+		UsedToday:      usage,
+		AvailableFunds: float32(max(balance+float64(limits.CreditLine), 0)),
+		Currency:       currency,
+	}
+
+	if limits.DailyAmount > 0 {
+		remaining := max(limits.DailyAmount-usage.Amount, 0)
+		overview.RemainingDailyAmount = &remaining
+	}
+	if limits.DailyCount > 0 {
+		remaining := max(limits.DailyCount-usage.Count, 0)
+		overview.RemainingDailyCount = &remaining
+	}
+
+	single := limits.SingleTransaction
+	if card.TransactionLimit > 0 && (single == 0 || card.TransactionLimit < single) {
+		single = card.TransactionLimit
+	}
+	if single > 0 {
+		overview.MaxSingleTransaction = &single
+	}
+
+	if card.CardType == Credit {
+		used := float32(max(-balance, 0))
+		remaining := max(limits.CreditLine-used, 0)
+		overview.RemainingCreditLine = &remaining
+	}
+
+	return overview, nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type BankClient struct {
+	ID          uint      `gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Surname     string    `json:"surname"`
+	Birthday    time.Time `json:"birthday"`
+	Email       string    `json:"email"`
+	PhoneNumber string    `json:"phoneNumber"`
+	// bcrypt hash of the online banking password, empty until one is set
+	PasswordHash string `json:"-"`
+}
+
+type BankAccount struct {
+	ID            uint          `gorm:"primaryKey"`
+	AccountNumber string        `json:"accountNumber"`
+	UserID        uint          `json:"userId"`
+	User          BankClient    `gorm:"foreignKey:UserID" json:"-"`
+	Balance       float64       `gorm:"type:numeric(18,2)" json:"balance"`
+	Currency      string        `json:"currency"`
+	DateCreated   time.Time     `json:"dateCreated"`
+	Status        AccountStatus `json:"status"`
+}
+
+type Card struct {
+	ID            uint        `gorm:"primaryKey"`
+	BankAccountID uint        `json:"bankAccountID"`
+	BankAccount   BankAccount `gorm:"foreignKey:BankAccountID" json:"-"`
+	// PAN se nikada ne čuva kao plain text!
+    // Ovde čuvaš AES-256 kriptovan niz bajtova ili string
+    EncryptedPAN   string      `json:"-"` 
+    // Version of the encryption key EncryptedPAN is sealed with
+    KeyVersion     int         `gorm:"index;default:1" json:"-"`
+    // Keyed HMAC of the PAN (blind index), so the card can be found without decrypting every PAN
+    PANIndex       string      `gorm:"uniqueIndex:idx_cards_pan_index_unique" json:"-"`
+	// Plaintext PAN of cards stored before PANs were encrypted; emptied at
+	// startup once the PAN is encrypted
+	CardNumber string `json:"-"`
+    
+    // Ovo sme da se vidi u aplikaciji (npr. 411111XXXXXX1234)
+    MaskedPAN      string      `json:"maskedPan"`
+	ExpiryDate    time.Time   `json:"expiryDate"`
+	CardType      CardType    `json:"cardType"`
+	IsTokenized   bool        `json:"isTokenized"`
+	Status        CardStatus  `gorm:"default:0" json:"status"`
+
+	// Controls set by the account holder
+	Frozen                bool    `gorm:"default:false" json:"frozen"`
+	OnlinePaymentsEnabled bool    `gorm:"default:true" json:"onlinePaymentsEnabled"`
+	TransactionLimit      float32 `gorm:"default:0" json:"transactionLimit"` // Largest single payment, 0 for no limit
+
+	// Spending limits set by the bank for this card; nil inherits the
+	// limit of the card type
+	DailyAmountLimit       *float32 `json:"dailyAmountLimit,omitempty"`
+	DailyCountLimit        *int     `json:"dailyCountLimit,omitempty"`
+	SingleTransactionLimit *float32 `json:"singleTransactionLimit,omitempty"`
+	CreditLine             *float32 `json:"creditLine,omitempty"`
+}
+
+// SpendingLimits caps the spending of a card; zero means no limit
+type SpendingLimits struct {
+	DailyAmount       float32 `json:"dailyAmount" binding:"min=0"`
+	DailyCount        int     `json:"dailyCount" binding:"min=0"`
+	SingleTransaction float32 `json:"singleTransaction" binding:"min=0"`
+	CreditLine        float32 `json:"creditLine" binding:"min=0"` // Credit cards only: how far below zero the account may go
+}
+
+// CardTypeLimit holds the bank's spending limits for a card type
+type CardTypeLimit struct {
+	CardType       CardType `gorm:"primaryKey;autoIncrement:false" json:"cardType"`
+	SpendingLimits `gorm:"embedded"`
+}
+
+// CardLimitsRequest sets the spending limits of a single card; omitted
+// fields inherit the card type's limits
+type CardLimitsRequest struct {
+	DailyAmountLimit       *float32 `json:"dailyAmountLimit" binding:"omitempty,min=0"`
+	DailyCountLimit        *int     `json:"dailyCountLimit" binding:"omitempty,min=0"`
+	SingleTransactionLimit *float32 `json:"singleTransactionLimit" binding:"omitempty,min=0"`
+	CreditLine             *float32 `json:"creditLine" binding:"omitempty,min=0"`
+}
+
+// CardControlsRequest changes the controls of a card; omitted fields keep
+// their value
+type CardControlsRequest struct {
+	OnlinePaymentsEnabled *bool    `json:"onlinePaymentsEnabled"`
+	TransactionLimit      *float32 `json:"transactionLimit" binding:"omitempty,min=0"`
+}
+
+type Transaction struct {
+	ID                uint              `gorm:"primaryKey"`
+	TransactionId     uuid.UUID         `json:"transactionId"`
+	AcquirerOrderId   uuid.UUID         `json:"acquirerOrderId"`
+	AcquirerTimestamp time.Time         `json:"acquirerTimestamp"`
+	MerchantId        uint              `json:"merchantId"`
+	MerchantOrderId   uuid.UUID         `json:"merchantOrderId"`
+	Status            TransactionStatus `json:"status"`
+	Amount            float32           `json:"amount"`
+	Currency          string            `json:"currency"`
+	Timestamp         time.Time         `json:"timestamp"`
+	PartialCardNumber string            `json:"partialCardNumber"`
+	CardID            *uint             `gorm:"index" json:"cardId,omitempty"` // Set once the card is charged
+}
+
+// LedgerEntry is one side of a balanced journal. Amount is signed: credits
+// to the account are positive and debits negative, so the entries of a
+// journal sum to zero and a bank account's balance is the sum of the
+// entries of its customer ledger account.
+type LedgerEntry struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	JournalId     uuid.UUID  `gorm:"index" json:"journalId"`
+	Kind          EntryKind  `json:"kind"`
+	Account       string     `gorm:"index" json:"account"` // e.g. customer:12, hold:12, bank:fees
+	Amount        float64    `gorm:"type:numeric(18,2)" json:"amount"`
+	Currency      string     `json:"currency"`
+	TransactionId *uuid.UUID `gorm:"index" json:"transactionId,omitempty"` // Acquirer order of the payment, if any
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type EntryKind string
+
+const (
+	EntryOpeningBalance EntryKind = "opening_balance"
+	EntryPayment        EntryKind = "payment"
+	EntryRefund         EntryKind = "refund"
+	EntryHold           EntryKind = "hold"
+	EntryHoldRelease    EntryKind = "hold_release"
+	EntryFee            EntryKind = "fee"
+	EntrySettlement     EntryKind = "settlement"
+)
+
+// Hold reserves funds of a bank account: the amount is moved from the
+// account's customer ledger account to its hold one until it is released
+type Hold struct {
+	ID            uuid.UUID  `gorm:"primaryKey" json:"holdId"`
+	BankAccountId uint       `gorm:"index" json:"bankAccountId"`
+	Amount        float64    `gorm:"type:numeric(18,2)" json:"amount"`
+	Currency      string     `json:"currency"`
+	Reason        string     `json:"reason"`
+	JournalId     uuid.UUID  `json:"journalId"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ReleasedAt    *time.Time `json:"releasedAt,omitempty"`
+}
+
+// Settlement is a PCC clearing cycle whose settlement file the bank imported
+type Settlement struct {
+	ID               uint      `gorm:"primaryKey" json:"-"`
+	CycleId          uuid.UUID `gorm:"uniqueIndex" json:"cycleId"`
+	BusinessDate     string    `json:"businessDate"`
+	TransactionCount int       `json:"transactionCount"`
+	ImportedAt       time.Time `json:"importedAt"`
+}
+
+type Merchant struct {
+	MerchantId    uint        `json:"merchantId"`
+	BankAccountID uint        `json:"bankAccountID"`
+	BankAccount   BankAccount `gorm:"foreignKey:BankAccountID"`
+}
+
+type TransactionStatus int
+
+const (
+	Successful TransactionStatus = iota
+	InProgress
+	Failed
+	Error
+)
+
+type AccountStatus int
+
+const (
+	Active AccountStatus = iota
+	Closed
+	Blocked
+)
+
+type CardStatus int
+
+const (
+	CardActive CardStatus = iota
+	CardBlocked
+	CardReplaced
+)
+
+type CardType int
+
+const (
+	Debit CardType = iota
+	Credit
+	Prepaid
+)
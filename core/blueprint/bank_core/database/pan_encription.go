@@ -0,0 +1,251 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Keyring holds the versioned AES-256 keys that encrypt stored PANs.
+//
+// Ciphertexts are prefixed with the version of the key that sealed them
+// ("v2:<hex>"), so several keys can be loaded while stored PANs move to the
+// active one. Ciphertexts written before versioning have no prefix and were
+// sealed with version 1.
+type Keyring struct {
+	keys   map[int][]byte
+	active int
+}
+
+var ErrUnknownKeyVersion = errors.New("encryption key version is not loaded")
+
+// keyring is loaded once at startup by LoadKeyring
+var keyring *Keyring
+
+// LoadKeyring loads the encryption keyring from configuration.
+//
+// BANK_ENCRYPTION_KEYS lists the keys as comma separated "version:base64key"
+// pairs, each key being 32 bytes. BANK_ENCRYPTION_ACTIVE_KEY_VERSION selects
+// the version new ciphertexts are sealed with; it defaults to the highest
+// loaded version. Without BANK_ENCRYPTION_KEYS, the single raw
+// BANK_ENCRYPTION_KEY is loaded as version 1.
+func LoadKeyring() error {
+	var keys map[int][]byte
+	if config := os.Getenv("BANK_ENCRYPTION_KEYS"); config != "" {
+		var err error
+		keys, err = parseKeys(config)
+		if err != nil {
+			return err
+		}
+	} else if legacy := os.Getenv("BANK_ENCRYPTION_KEY"); legacy != "" {
+		keys = map[int][]byte{1: []byte(legacy)}
+	}
+
+	active := 0
+	if config := os.Getenv("BANK_ENCRYPTION_ACTIVE_KEY_VERSION"); config != "" {
+		var err error
+		active, err = strconv.Atoi(config)
+		if err != nil {
+			return fmt.Errorf("invalid BANK_ENCRYPTION_ACTIVE_KEY_VERSION: %w", err)
+		}
+	}
+
+	loaded, err := NewKeyring(keys, active)
+	if err != nil {
+		return err
+	}
+	keyring = loaded
+	return nil
+}
+
+// NewKeyring creates a keyring from loaded keys. An active version of 0
+// selects the highest version.
+func NewKeyring(keys map[int][]byte, active int) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys configured")
+	}
+
+	highest := 0
+	for version, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key version %d must be 32 bytes", version)
+		}
+		if version > highest {
+			highest = version
+		}
+	}
+
+	if active == 0 {
+		active = highest
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active encryption key version %d: %w", active, ErrUnknownKeyVersion)
+	}
+
+	return &Keyring{keys: keys, active: active}, nil
+}
+
+func parseKeys(config string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionStr, encoded, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid BANK_ENCRYPTION_KEYS entry, expected version:key")
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid encryption key version %q", versionStr)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key version %d: %w", version, err)
+		}
+
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("duplicate encryption key version %d", version)
+		}
+		keys[version] = key
+	}
+
+	return keys, nil
+}
+
+// ActiveKeyVersion returns the version new ciphertexts are sealed with
+func ActiveKeyVersion() int {
+	if keyring == nil {
+		return 0
+	}
+	return keyring.active
+}
+
+// CiphertextKeyVersion returns the version of the key that sealed a
+// ciphertext
+func CiphertextKeyVersion(encrypted string) (int, error) {
+	version, _, err := splitCiphertext(encrypted)
+	return version, err
+}
+
+func splitCiphertext(encrypted string) (int, string, error) {
+	prefix, encryptedHex, found := strings.Cut(encrypted, ":")
+	if !found {
+		return 1, encrypted, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(prefix, "v"))
+	if err != nil || !strings.HasPrefix(prefix, "v") {
+		return 0, "", fmt.Errorf("invalid ciphertext key version %q", prefix)
+	}
+	return version, encryptedHex, nil
+}
+
+func (k *Keyring) gcm(version int) (cipher.AEAD, error) {
+	key, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("version %d: %w", version, ErrUnknownKeyVersion)
+	}
+
+	// 2. Kreiranje cipher bloka
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. GCM mod rada (preporučen za PCI DSS)
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts plain text using AES-256-GCM with the active key
+func Encrypt(plainText string) (string, error) {
+	// 1. Ključ se uzima iz keyringa učitanog pri pokretanju
+	if keyring == nil {
+		return "", fmt.Errorf("encryption keyring not loaded")
+	}
+
+	gcm, err := keyring.gcm(keyring.active)
+	if err != nil {
+		return "", err
+	}
+
+	// 4. Kreiranje Nonce-a (jedinstveni broj za svaku enkripciju)
+	// PCI DSS zahteva da se isti ključ ne koristi isto bez nasumičnog dela
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// 5. Enkripcija (Sealing)
+	// Spajamo nonce i šifrovani tekst radi lakšeg čuvanja u bazi
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plainText), nil)
+
+	return fmt.Sprintf("v%d:%s", keyring.active, hex.EncodeToString(ciphertext)), nil
+}
+
+// Decrypt decrypts a ciphertext written by Encrypt with the key version it
+// names
+func Decrypt(encrypted string) (string, error) {
+	if keyring == nil {
+		return "", fmt.Errorf("encryption keyring not loaded")
+	}
+
+	version, encryptedHex, err := splitCiphertext(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := hex.DecodeString(encryptedHex)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := keyring.gcm(version)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	// Odvajanje nonce-a od podataka
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+
+	plainText, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainText), nil
+}
+
+// BlindIndex returns the keyed HMAC-SHA256 of a PAN, stored next to the
+// encrypted PAN so cards can be looked up by an indexed query. The index key
+// is separate from the encryption key.
+func BlindIndex(pan string) (string, error) {
+	key := []byte(os.Getenv("BANK_PAN_INDEX_KEY"))
+	if len(key) < 32 {
+		return "", fmt.Errorf("BANK_PAN_INDEX_KEY must be at least 32 bytes")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(pan))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
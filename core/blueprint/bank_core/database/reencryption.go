@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReencryptionProgress reports a run of the job that moves stored PANs to
+// the active encryption key
+type ReencryptionProgress struct {
+	Running       bool       `json:"running"`
+	ActiveVersion int        `json:"activeVersion"`
+	Total         int        `json:"total"` // Cards on older keys when the run started
+	Reencrypted   int        `json:"reencrypted"`
+	Failed        int        `json:"failed"`
+	LastCardId    uint       `json:"lastCardId"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+var ErrReencryptionRunning = errors.New("re-encryption is already running")
+
+const reencryptionBatchSize = 100
+
+// StartReencryption starts re-encrypting, in the background, every card whose
+// PAN is sealed with an older key. Cards are selected by key version, so a
+// run interrupted by a restart resumes where it stopped when started again.
+// Once a run reports no failures, older keys can be removed from
+// configuration.
+func (s *service) StartReencryption() (ReencryptionProgress, error) {
+	active := ActiveKeyVersion()
+	if active == 0 {
+		return ReencryptionProgress{}, fmt.Errorf("encryption keyring not loaded")
+	}
+
+	s.reencryptionMu.Lock()
+	defer s.reencryptionMu.Unlock()
+
+	if s.reencryption.Running {
+		return s.reencryption, ErrReencryptionRunning
+	}
+
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM cards WHERE key_version <> $1`, active).Scan(&total)
+	if err != nil {
+		return ReencryptionProgress{}, fmt.Errorf("failed to count cards: %w", err)
+	}
+
+	now := time.Now()
+	s.reencryption = ReencryptionProgress{
+		Running:       true,
+		ActiveVersion: active,
+		Total:         total,
+		StartedAt:     &now,
+	}
+
+	go s.reencryptCards(active)
+
+	return s.reencryption, nil
+}
+
+// GetReencryptionProgress returns the progress of the current or last
+// re-encryption run
+func (s *service) GetReencryptionProgress() ReencryptionProgress {
+	s.reencryptionMu.Lock()
+	defer s.reencryptionMu.Unlock()
+	return s.reencryption
+}
+
+func (s *service) reencryptCards(active int) {
+	err := s.reencryptBatches(active)
+
+	s.reencryptionMu.Lock()
+	defer s.reencryptionMu.Unlock()
+
+	now := time.Now()
+	s.reencryption.Running = false
+	s.reencryption.FinishedAt = &now
+	if err != nil {
+		s.reencryption.Error = err.Error()
+		fmt.Printf("card re-encryption stopped: %v\n", err)
+		return
+	}
+	fmt.Printf("card re-encryption to key version %d finished: %d re-encrypted, %d failed\n",
+		active, s.reencryption.Reencrypted, s.reencryption.Failed)
+}
+
+func (s *service) reencryptBatches(active int) error {
+	query := `SELECT id, encrypted_pan, key_version FROM cards
+	          WHERE key_version <> $1 AND id > $2
+	          ORDER BY id
+	          LIMIT $3`
+
+	afterId := uint(0)
+	for {
+		rows, err := s.db.Query(query, active, afterId, reencryptionBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to fetch cards: %w", err)
+		}
+
+		var cards []Card
+		for rows.Next() {
+			var card Card
+			if err := rows.Scan(&card.ID, &card.EncryptedPAN, &card.KeyVersion); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read card: %w", err)
+			}
+			cards = append(cards, card)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to fetch cards: %w", err)
+		}
+
+		if len(cards) == 0 {
+			return nil
+		}
+
+		for _, card := range cards {
+			afterId = card.ID
+			err := s.reencryptCard(card, active)
+
+			s.reencryptionMu.Lock()
+			s.reencryption.LastCardId = card.ID
+			if err != nil {
+				fmt.Printf("failed to re-encrypt card %d: %v\n", card.ID, err)
+				s.reencryption.Failed++
+			} else {
+				s.reencryption.Reencrypted++
+			}
+			s.reencryptionMu.Unlock()
+		}
+	}
+}
+
+// reencryptCard seals a card's PAN with the active key. The update only
+// applies if the card is still on the version that was read.
+func (s *service) reencryptCard(card Card, active int) error {
+	pan, err := Decrypt(card.EncryptedPAN)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := Encrypt(pan)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`UPDATE cards SET encrypted_pan = $1, key_version = $2 WHERE id = $3 AND key_version = $4`,
+		encrypted, active, card.ID, card.KeyVersion)
+	return err
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotFound      = errors.New("no successful payment with the acquirer order id")
+	ErrRefundNotOnUs        = errors.New("only payments between two accounts of the bank can be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left to refund")
+)
+
+// RefundRequest refunds part of a payment; without an amount whatever is
+// left of the payment is refunded
+type RefundRequest struct {
+	Amount float64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+// Refund is a refund posted to the ledger
+type Refund struct {
+	JournalId       uuid.UUID `json:"journalId"`
+	AcquirerOrderId uuid.UUID `json:"acquirerOrderId"`
+	Amount          float64   `json:"amount"`
+	Currency        string    `json:"currency"`
+	Refunded        float64   `json:"refunded"`  // Refunded so far, this refund included
+	Remaining       float64   `json:"remaining"` // Left to refund
+}
+
+// customerAccountId returns the bank account behind a customer ledger account
+func customerAccountId(account string) (uint, bool) {
+	id, ok := strings.CutPrefix(account, "customer:")
+	if !ok {
+		return 0, false
+	}
+	bankAccountId, err := strconv.ParseUint(id, 10, 32)
+	return uint(bankAccountId), err == nil
+}
+
+// RefundPayment moves an amount of a card payment back from the merchant's
+// account to the payer's. Payments routed through PCC are refunded by the
+// other bank, so only payments where the bank holds both accounts can be.
+func (s *service) RefundPayment(acquirerOrderId uuid.UUID, req RefundRequest) (*Refund, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Refunds of a payment are serialised by the lock on its transaction
+	var status TransactionStatus
+	err = tx.QueryRow(`SELECT status FROM transactions WHERE acquirer_order_id = $1 FOR UPDATE`, acquirerOrderId).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && status != Successful) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+
+	rows, err := tx.Query(`SELECT account, amount, currency FROM ledger_entries WHERE transaction_id = $1 AND kind = $2`,
+		acquirerOrderId, EntryPayment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment postings: %w", err)
+	}
+	var payer, payee Posting
+	var currency string
+	for rows.Next() {
+		var posting Posting
+		if err := rows.Scan(&posting.Account, &posting.Amount, &currency); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read payment postings: %w", err)
+		}
+		if posting.Amount < 0 {
+			payer = posting
+		} else {
+			payee = posting
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch payment postings: %w", err)
+	}
+	if payer.Account == "" || payee.Account == "" {
+		return nil, ErrPaymentNotFound
+	}
+
+	_, payerIsCustomer := customerAccountId(payer.Account)
+	merchantAccountId, payeeIsCustomer := customerAccountId(payee.Account)
+	if !payerIsCustomer || !payeeIsCustomer {
+		return nil, ErrRefundNotOnUs
+	}
+
+	var refunded float64
+	err = tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE transaction_id = $1 AND kind = $2 AND account = $3`,
+		acquirerOrderId, EntryRefund, payer.Account).Scan(&refunded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch refunds: %w", err)
+	}
+
+	remaining := cents(payee.Amount) - cents(refunded)
+	amount := cents(req.Amount)
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, ErrRefundExceedsPayment
+	}
+
+	var merchantBalance float64
+	err = tx.QueryRow(`SELECT balance FROM bank_accounts WHERE id = $1 FOR UPDATE`, merchantAccountId).Scan(&merchantBalance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch merchant balance: %w", err)
+	}
+	if cents(merchantBalance) < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	journalId, err := postJournal(tx, EntryRefund, currency, &acquirerOrderId,
+		Posting{Account: payee.Account, Amount: -float64(amount) / 100},
+		Posting{Account: payer.Account, Amount: float64(amount) / 100},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %w", err)
+	}
+
+	return &Refund{
+		JournalId:       journalId,
+		AcquirerOrderId: acquirerOrderId,
+		Amount:          float64(amount) / 100,
+		Currency:        currency,
+		Refunded:        float64(cents(refunded)+amount) / 100,
+		Remaining:       float64(remaining-amount) / 100,
+	}, nil
+}
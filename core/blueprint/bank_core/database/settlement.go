@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSettlementImported    = errors.New("settlement file was already imported")
+	ErrInvalidSettlementFile = errors.New("invalid settlement file")
+)
+
+// SettlementPosition is what the bank is owed and owes in one currency of a
+// PCC clearing cycle. A positive Net is paid to the bank, a negative one by it.
+type SettlementPosition struct {
+	Currency   string  `json:"currency" binding:"required"`
+	Receivable float64 `json:"receivable"`
+	Payable    float64 `json:"payable"`
+	Net        float64 `json:"net"`
+}
+
+// SettlementItem is a cleared transaction; the bank was its acquirer or issuer
+type SettlementItem struct {
+	PccTransactionId uuid.UUID `json:"pccTransactionId"`
+	AcquirerOrderId  uuid.UUID `json:"acquirerOrderId"`
+	Role             string    `json:"role" binding:"oneof=acquirer issuer"`
+	CounterpartyId   uint      `json:"counterpartyId"`
+	Amount           float64   `json:"amount"`
+	Currency         string    `json:"currency"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// SettlementFile is the bank's part of a PCC clearing cycle
+type SettlementFile struct {
+	CycleId      uuid.UUID            `json:"cycleId" binding:"required"`
+	BusinessDate string               `json:"businessDate" binding:"required"`
+	BankId       uint                 `json:"bankId" binding:"required"`
+	Positions    []SettlementPosition `json:"positions" binding:"dive"`
+	Transactions []SettlementItem     `json:"transactions" binding:"dive"`
+}
+
+// SettlementReport is the outcome of importing a settlement file.
+// Unmatched lists cleared transactions the bank has no successful record of.
+type SettlementReport struct {
+	Settlement
+	Positions []SettlementPosition `json:"positions"`
+	Unmatched []uuid.UUID          `json:"unmatched"`
+}
+
+// checkSettlementFile verifies that each position's net is its receivable
+// less its payable and that the transactions add up to the positions
+func checkSettlementFile(file SettlementFile) error {
+	receivable := map[string]int64{}
+	payable := map[string]int64{}
+	for _, item := range file.Transactions {
+		if item.Role == "acquirer" {
+			receivable[item.Currency] += cents(item.Amount)
+		} else {
+			payable[item.Currency] += cents(item.Amount)
+		}
+	}
+
+	currencies := map[string]bool{}
+	for _, position := range file.Positions {
+		if currencies[position.Currency] {
+			return fmt.Errorf("%w: two positions in %s", ErrInvalidSettlementFile, position.Currency)
+		}
+		currencies[position.Currency] = true
+		if cents(position.Net) != cents(position.Receivable)-cents(position.Payable) {
+			return fmt.Errorf("%w: net %s position is not receivable less payable", ErrInvalidSettlementFile, position.Currency)
+		}
+		if cents(position.Receivable) != receivable[position.Currency] || cents(position.Payable) != payable[position.Currency] {
+			return fmt.Errorf("%w: %s transactions do not add up to the position", ErrInvalidSettlementFile, position.Currency)
+		}
+	}
+	for currency := range receivable {
+		if !currencies[currency] {
+			return fmt.Errorf("%w: no position for %s transactions", ErrInvalidSettlementFile, currency)
+		}
+	}
+	for currency := range payable {
+		if !currencies[currency] {
+			return fmt.Errorf("%w: no position for %s transactions", ErrInvalidSettlementFile, currency)
+		}
+	}
+	return nil
+}
+
+// ImportSettlement settles a PCC clearing cycle. During the day payments
+// with other banks were posted against the PCC settlement account; the net
+// position of each currency now moves from there to the nostro account the
+// settlement bank pays it through, which leaves the settlement account at
+// zero once every cycle is settled.
+func (s *service) ImportSettlement(file SettlementFile) (*SettlementReport, error) {
+	if err := checkSettlementFile(file); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &SettlementReport{
+		Settlement: Settlement{
+			CycleId:          file.CycleId,
+			BusinessDate:     file.BusinessDate,
+			TransactionCount: len(file.Transactions),
+			ImportedAt:       time.Now(),
+		},
+		Positions: file.Positions,
+		Unmatched: []uuid.UUID{},
+	}
+
+	var imported bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM settlements WHERE cycle_id = $1)`, file.CycleId).Scan(&imported); err != nil {
+		return nil, err
+	}
+	if imported {
+		return nil, ErrSettlementImported
+	}
+
+	for _, position := range file.Positions {
+		if cents(position.Net) == 0 {
+			continue
+		}
+		_, err := postJournal(tx, EntrySettlement, position.Currency, nil,
+			Posting{Account: PCCSettlementAccount, Amount: position.Net},
+			Posting{Account: SettlementNostroAccount, Amount: -position.Net},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range file.Transactions {
+		var matched bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM transactions WHERE acquirer_order_id = $1 AND status = $2)`, item.AcquirerOrderId, Successful).
+			Scan(&matched)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			report.Unmatched = append(report.Unmatched, item.PccTransactionId)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO settlements (cycle_id, business_date, transaction_count, imported_at) VALUES ($1, $2, $3, $4)`,
+		file.CycleId, file.BusinessDate, report.TransactionCount, report.ImportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record settlement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultHistoryPageSize = 20
+	MaxHistoryPageSize     = 100
+)
+
+// AccountTransaction is a ledger entry of a bank account with the payment
+// it belongs to. Amount is negative for money leaving the account.
+type AccountTransaction struct {
+	EntryId         uint       `json:"entryId"`
+	Kind            EntryKind  `json:"kind"`
+	Amount          float64    `json:"amount"`
+	Currency        string     `json:"currency"`
+	BookedAt        time.Time  `json:"bookedAt"`
+	TransactionId   *uuid.UUID `json:"transactionId,omitempty"`
+	MerchantId      *uint      `json:"merchantId,omitempty"`
+	MerchantOrderId *uuid.UUID `json:"merchantOrderId,omitempty"`
+	MaskedPAN       *string    `json:"maskedPan,omitempty"`
+}
+
+// TransactionPage is one page of an account's transaction history, newest first
+type TransactionPage struct {
+	Transactions []AccountTransaction `json:"transactions"`
+	Page         int                  `json:"page"`
+	PageSize     int                  `json:"pageSize"`
+	Total        int                  `json:"total"`
+}
+
+// Statement is a bank account's monthly statement. Balances are those of
+// the account's ledger account at the start and end of the month.
+type Statement struct {
+	Account        BankAccount          `json:"account"`
+	Holder         string               `json:"holder"`
+	From           time.Time            `json:"from"`
+	To             time.Time            `json:"to"` // Exclusive
+	OpeningBalance float64              `json:"openingBalance"`
+	ClosingBalance float64              `json:"closingBalance"`
+	TotalDebits    float64              `json:"totalDebits"`
+	TotalCredits   float64              `json:"totalCredits"`
+	Transactions   []AccountTransaction `json:"transactions"`
+}
+
+const accountTransactionQuery = `SELECT e.id, e.kind, e.amount, e.currency, e.created_at,
+	e.transaction_id, t.merchant_id, t.merchant_order_id, c.masked_pan
+	FROM ledger_entries e
+	LEFT JOIN transactions t ON t.acquirer_order_id = e.transaction_id
+	LEFT JOIN cards c ON c.id = t.card_id`
+
+func (s *service) queryAccountTransactions(query string, args ...any) ([]AccountTransaction, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []AccountTransaction{}
+	for rows.Next() {
+		var t AccountTransaction
+		err := rows.Scan(&t.EntryId, &t.Kind, &t.Amount, &t.Currency, &t.BookedAt,
+			&t.TransactionId, &t.MerchantId, &t.MerchantOrderId, &t.MaskedPAN)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// GetAccountTransactions returns a page of the transaction history of one
+// of the client's bank accounts
+func (s *service) GetAccountTransactions(clientId uint, accountId uint, page int, pageSize int) (*TransactionPage, error) {
+	if _, err := s.GetClientAccount(clientId, accountId); err != nil {
+		return nil, err
+	}
+
+	page = max(page, 1)
+	if pageSize < 1 || pageSize > MaxHistoryPageSize {
+		pageSize = DefaultHistoryPageSize
+	}
+	account := customerAccount(accountId)
+
+	result := &TransactionPage{Page: page, PageSize: pageSize}
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM ledger_entries WHERE account = $1`, account).Scan(&result.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	query := accountTransactionQuery + ` WHERE e.account = $1 ORDER BY e.created_at DESC, e.id DESC LIMIT $2 OFFSET $3`
+	result.Transactions, err = s.queryAccountTransactions(query, account, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetStatement returns the statement of one of the client's bank accounts
+// for the month (UTC) that contains the given time
+func (s *service) GetStatement(clientId uint, accountId uint, month time.Time) (*Statement, error) {
+	bankAccount, err := s.GetClientAccount(clientId, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	month = month.UTC()
+	statement := &Statement{
+		Account: *bankAccount,
+		From:    time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC),
+	}
+	statement.To = statement.From.AddDate(0, 1, 0)
+
+	err = s.db.QueryRow(`SELECT name || ' ' || surname FROM bank_clients WHERE id = $1`, clientId).Scan(&statement.Holder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch client: %w", err)
+	}
+
+	account := customerAccount(accountId)
+	err = s.db.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1 AND created_at < $2`,
+		account, statement.From).Scan(&statement.OpeningBalance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate opening balance: %w", err)
+	}
+
+	query := accountTransactionQuery + ` WHERE e.account = $1 AND e.created_at >= $2 AND e.created_at < $3 ORDER BY e.created_at, e.id`
+	statement.Transactions, err = s.queryAccountTransactions(query, account, statement.From, statement.To)
+	if err != nil {
+		return nil, err
+	}
+
+	closing := cents(statement.OpeningBalance)
+	var debits, credits int64
+	for _, t := range statement.Transactions {
+		amount := cents(t.Amount)
+		closing += amount
+		if amount < 0 {
+			debits -= amount
+		} else {
+			credits += amount
+		}
+	}
+	statement.ClosingBalance = float64(closing) / 100
+	statement.TotalDebits = float64(debits) / 100
+	statement.TotalCredits = float64(credits) / 100
+
+	return statement, nil
+}
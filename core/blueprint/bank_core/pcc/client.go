@@ -0,0 +1,131 @@
+// Package pcc sends authorizations for cards of other banks to the Payment
+// Card Center, which routes them to the issuing bank.
+package pcc
+
+import (
+	"bank_core/database"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const requestTimeout = 15 * time.Second
+
+// ErrCycleNotFound is returned for a clearing cycle PCC does not know
+var ErrCycleNotFound = errors.New("clearing cycle not found at PCC")
+
+// Client authorizes payments at the issuing bank through PCC
+type Client struct {
+	baseURL string
+	bankId  uint
+	secret  string
+	http    *http.Client
+}
+
+// NewClient returns a PCC client configured by PCC_URL, BANK_ID and
+// PCC_SHARED_SECRET, or nil if PCC_URL is not set
+func NewClient() (*Client, error) {
+	baseURL := os.Getenv("PCC_URL")
+	if baseURL == "" {
+		return nil, nil
+	}
+
+	bankId, err := strconv.ParseUint(os.Getenv("BANK_ID"), 10, 32)
+	if err != nil || bankId == 0 {
+		return nil, fmt.Errorf("BANK_ID must be the bank's ID at PCC")
+	}
+
+	secret := os.Getenv("PCC_SHARED_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("PCC_SHARED_SECRET must be set to connect to PCC")
+	}
+
+	return &Client{
+		baseURL: baseURL,
+		bankId:  uint(bankId),
+		secret:  secret,
+		http:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// do sends a request to PCC authenticated as the bank
+func (c *Client) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.secret)
+	return c.http.Do(req)
+}
+
+// errorFrom returns the error PCC answered a request with
+func errorFrom(resp *http.Response) error {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+	return fmt.Errorf("PCC answered %s: %s", resp.Status, failure.Error)
+}
+
+// BankId is the bank's ID at PCC
+func (c *Client) BankId() uint {
+	return c.bankId
+}
+
+// Authorize sends an authorization to PCC and returns the issuer's answer
+func (c *Client) Authorize(req database.AuthorizationRequest) (*database.AuthorizationResponse, error) {
+	req.AcquirerId = c.bankId
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(http.MethodPost, "/authorizations", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorFrom(resp)
+	}
+
+	var response database.AuthorizationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid PCC response: %w", err)
+	}
+	return &response, nil
+}
+
+// SettlementFile fetches the bank's settlement file of a clearing cycle
+func (c *Client) SettlementFile(cycleId uuid.UUID) (*database.SettlementFile, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/clearing/cycles/%s/settlement-files/%d", cycleId, c.bankId), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrCycleNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorFrom(resp)
+	}
+
+	var file database.SettlementFile
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid settlement file: %w", err)
+	}
+	return &file, nil
+}
@@ -0,0 +1,57 @@
+package pcc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bank_core/database"
+
+	"github.com/google/uuid"
+)
+
+func TestClientAuthenticatesAsTheBank(t *testing.T) {
+	cycleId := uuid.New()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("%s called with Authorization %q", r.URL.Path, got)
+		}
+		switch r.URL.Path {
+		case "/authorizations":
+			json.NewEncoder(w).Encode(database.AuthorizationResponse{Status: database.Successful})
+		case fmt.Sprintf("/clearing/cycles/%s/settlement-files/3", cycleId):
+			json.NewEncoder(w).Encode(database.SettlementFile{CycleId: cycleId, BankId: 3})
+		default:
+			http.Error(w, `{"error": "cycle not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("PCC_URL", server.URL)
+	t.Setenv("BANK_ID", "3")
+	t.Setenv("PCC_SHARED_SECRET", "secret")
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Authorize(database.AuthorizationRequest{}); err != nil {
+		t.Errorf("authorize: %v", err)
+	}
+	file, err := client.SettlementFile(cycleId)
+	if err != nil || file.CycleId != cycleId || file.BankId != 3 {
+		t.Errorf("settlement file: got %+v (%v)", file, err)
+	}
+	if _, err := client.SettlementFile(uuid.New()); !errors.Is(err, ErrCycleNotFound) {
+		t.Errorf("unknown cycle: got %v", err)
+	}
+
+	t.Setenv("PCC_SHARED_SECRET", "")
+	if _, err := NewClient(); err == nil {
+		t.Error("expected error without shared secret")
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clientTokenLifetime = time.Hour
+
+// jwtSecret signs the tokens of online banking sessions
+var jwtSecret = []byte(os.Getenv("BANK_JWT_SECRET"))
+
+var errNoJWTSecret = errors.New("BANK_JWT_SECRET is not set")
+
+// adminToken is the bearer token of the bank's own back office, which
+// provisions client credentials
+var adminToken = os.Getenv("BANK_ADMIN_TOKEN")
+
+// gatewayToken is the bearer token of the bank gateway, which passes on the
+// refunds merchants request for payments the bank acquired
+var gatewayToken = os.Getenv("BANK_GATEWAY_TOKEN")
+
+// pccSecret is the secret the bank shares with PCC; PCC presents it when it
+// routes authorizations and advices for the bank's cards
+var pccSecret = os.Getenv("PCC_SHARED_SECRET")
+
+type clientClaims struct {
+	ClientId uint `json:"clientId"`
+	jwt.RegisteredClaims
+}
+
+func generateClientToken(clientId uint) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errNoJWTSecret
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, clientClaims{
+		ClientId: clientId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(clientTokenLifetime)),
+		},
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// ClientAuthMiddleware admits requests with a valid online banking token and
+// sets the client's ID on the context
+func (s *Server) ClientAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || tokenString == "" || len(jwtSecret) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing bearer token"})
+			return
+		}
+
+		var claims clientClaims
+		token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid || claims.ClientId == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		c.Set("clientId", claims.ClientId)
+		c.Next()
+	}
+}
+
+// hasBearer reports whether the request bears the expected token, never
+// when no token is expected
+func hasBearer(c *gin.Context, expected string) bool {
+	tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return found && expected != "" && subtle.ConstantTimeCompare([]byte(tokenString), []byte(expected)) == 1
+}
+
+// AdminAuthMiddleware admits requests bearing the bank's admin token; with
+// no token configured every request is refused
+func (s *Server) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasBearer(c, adminToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// PCCAuthMiddleware admits requests bearing the secret shared with PCC
+func (s *Server) PCCAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasBearer(c, pccSecret) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing PCC credentials"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func clientIdFrom(c *gin.Context) uint {
+	return c.GetUint("clientId")
+}
+
+// GatewayAuthMiddleware admits requests bearing the bank gateway's token
+func (s *Server) GatewayAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasBearer(c, gatewayToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing gateway token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	s := &Server{}
+	r := gin.New()
+	r.GET("/admin", s.AdminAuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		configured    string
+		authorization string
+		want          int
+	}{
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		adminToken = tt.configured
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d", tt.name, rr.Code, tt.want)
+		}
+	}
+	adminToken = ""
+}
+
+func TestRoutesRequireCredentials(t *testing.T) {
+	adminToken, pccSecret, gatewayToken = "admin", "pcc", "gateway"
+	defer func() { adminToken, pccSecret, gatewayToken = "", "", "" }()
+
+	s := &Server{}
+	r := s.RegisterRoutes()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/accounts/1/cards"},
+		{http.MethodGet, "/cards/1"},
+		{http.MethodPost, "/cards/1/block"},
+		{http.MethodPost, "/cards/1/unblock"},
+		{http.MethodPost, "/cards/1/replace"},
+		{http.MethodPut, "/cards/1/limits"},
+		{http.MethodPut, "/card-type-limits/CREDIT"},
+		{http.MethodPut, "/admin/clients/1/password"},
+		{http.MethodPost, "/authorizations"},
+		{http.MethodPost, "/authorizations/advice"},
+		{http.MethodPost, "/admin/settlements"},
+		{http.MethodGet, "/admin/ledger/consistency"},
+		{http.MethodGet, "/admin/reencryption"},
+		{http.MethodPost, "/admin/reencryption"},
+		{http.MethodPost, "/payments/00000000-0000-0000-0000-000000000001/refunds"},
+		{http.MethodGet, "/admin/accounts/1/holds"},
+		{http.MethodPost, "/admin/accounts/1/holds"},
+		{http.MethodPost, "/admin/holds/1/release"},
+	}
+	for _, route := range routes {
+		// No credentials, and wrong ones
+		for _, authorization := range []string{"", "Bearer other"} {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %q: got status %d want %d", route.method, route.path, authorization, rr.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bank_core/database"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Online banking endpoints. The client is the one the bearer token was issued
+// to, so only their own accounts are found.
+
+// SetClientPasswordHandler lets the bank provision a client's online banking
+// password; it is only reachable with the bank's admin token
+func (s *Server) SetClientPasswordHandler(c *gin.Context) {
+	clientId, err := strconv.ParseUint(c.Param("clientId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	err = s.db.SetClientPassword(uint(clientId), req.Password)
+	switch {
+	case errors.Is(err, database.ErrPasswordTooShort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		fmt.Printf("failed to set password of client %d: %v\n", clientId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Password set"})
+	}
+}
+
+// ChangePasswordHandler lets a logged in client change their own password
+func (s *Server) ChangePasswordHandler(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	clientId := clientIdFrom(c)
+	err := s.db.ChangeClientPassword(clientId, req.CurrentPassword, req.NewPassword)
+	switch {
+	case errors.Is(err, database.ErrPasswordTooShort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		fmt.Printf("failed to change password of client %d: %v\n", clientId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+	}
+}
+
+func (s *Server) ClientLoginHandler(c *gin.Context) {
+	var req struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	client, err := s.db.AuthenticateClient(req.Email, req.Password)
+	if errors.Is(err, database.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("client login failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+
+	token, err := generateClientToken(client.ID)
+	if err != nil {
+		fmt.Printf("failed to issue client token: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresIn": int(clientTokenLifetime.Seconds()), "client": client})
+}
+
+func (s *Server) GetAccountsHandler(c *gin.Context) {
+	accounts, err := s.db.GetClientAccounts(clientIdFrom(c))
+	if err != nil {
+		fmt.Printf("failed to fetch accounts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+func parseAccountId(c *gin.Context) (uint, bool) {
+	accountId, err := strconv.ParseUint(c.Param("accountId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return 0, false
+	}
+	return uint(accountId), true
+}
+
+func (s *Server) GetAccountHandler(c *gin.Context) {
+	accountId, ok := parseAccountId(c)
+	if !ok {
+		return
+	}
+
+	account, err := s.db.GetClientAccount(clientIdFrom(c), accountId)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// GetAccountTransactionsHandler returns the account's transaction history,
+// newest first, a page at a time (?page=1&pageSize=20)
+func (s *Server) GetAccountTransactionsHandler(c *gin.Context) {
+	accountId, ok := parseAccountId(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(database.DefaultHistoryPageSize)))
+	if err != nil || pageSize < 1 || pageSize > database.MaxHistoryPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Page size must be between 1 and %d", database.MaxHistoryPageSize)})
+		return
+	}
+
+	history, err := s.db.GetAccountTransactions(clientIdFrom(c), accountId, page, pageSize)
+	if err != nil {
+		fmt.Printf("failed to fetch transactions of account %d: %v\n", accountId, err)
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetStatementHandler downloads the account's statement for a month
+// (YYYY-MM) as CSV or PDF (?format=csv|pdf, PDF by default)
+func (s *Server) GetStatementHandler(c *gin.Context) {
+	accountId, ok := parseAccountId(c)
+	if !ok {
+		return
+	}
+
+	month, err := time.Parse("2006-01", c.Param("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Month must be formatted as YYYY-MM"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be pdf or csv"})
+		return
+	}
+
+	statement, err := s.db.GetStatement(clientIdFrom(c), accountId, month)
+	if err != nil {
+		fmt.Printf("failed to build statement of account %d: %v\n", accountId, err)
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var body []byte
+	var contentType string
+	if format == "csv" {
+		body, err = statementCSV(statement)
+		contentType = "text/csv"
+	} else {
+		body = statementPDF(statement)
+		contentType = "application/pdf"
+	}
+	if err != nil {
+		fmt.Printf("failed to render statement of account %d: %v\n", accountId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render statement"})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", statement.Account.AccountNumber, month.Format("2006-01"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, body)
+}
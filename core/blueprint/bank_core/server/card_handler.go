@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bank_core/database"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cardErrorStatus maps card management errors to HTTP statuses
+func cardErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrAccountNotFound), errors.Is(err, database.ErrCardNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrCardReplaced):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseCardId(c *gin.Context) (uint, bool) {
+	cardId, err := strconv.ParseUint(c.Param("cardId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+		return 0, false
+	}
+	return uint(cardId), true
+}
+
+// IssueCardHandler issues a card to a bank account. The response is the only
+// time the full PAN is returned.
+func (s *Server) IssueCardHandler(c *gin.Context) {
+	accountId, err := strconv.ParseUint(c.Param("accountId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	var req struct {
+		CardType database.CardType `json:"cardType"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	if req.CardType < database.Debit || req.CardType > database.Prepaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card type"})
+		return
+	}
+
+	card, err := s.db.IssueCard(uint(accountId), req.CardType)
+	if err != nil {
+		fmt.Printf("failed to issue card: %v\n", err)
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Card issued", "card": card})
+}
+
+func (s *Server) GetCardHandler(c *gin.Context) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	card, err := s.db.GetCard(cardId)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func (s *Server) BlockCardHandler(c *gin.Context) {
+	s.setCardStatus(c, database.CardBlocked, "Card blocked")
+}
+
+func (s *Server) UnblockCardHandler(c *gin.Context) {
+	s.setCardStatus(c, database.CardActive, "Card unblocked")
+}
+
+func (s *Server) setCardStatus(c *gin.Context, status database.CardStatus, message string) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	card, err := s.db.SetCardStatus(cardId, status)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "card": card})
+}
+
+// ReplaceCardHandler retires a card and issues its replacement, returning the
+// new card's PAN once
+func (s *Server) ReplaceCardHandler(c *gin.Context) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	card, err := s.db.ReplaceCard(cardId)
+	if err != nil {
+		fmt.Printf("failed to replace card %d: %v\n", cardId, err)
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Card replaced", "card": card})
+}
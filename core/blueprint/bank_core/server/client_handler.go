@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bank_core/database"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Self-service endpoints for account holders, behind the online banking
+// login. Every card is looked up on the accounts of the client the token was
+// issued to, so other clients' cards are not found.
+
+func (s *Server) GetClientCardsHandler(c *gin.Context) {
+	clientId := clientIdFrom(c)
+	cards, err := s.db.GetClientCards(clientId)
+	if err != nil {
+		fmt.Printf("failed to fetch cards of client %d: %v\n", clientId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cards)
+}
+
+func (s *Server) FreezeCardHandler(c *gin.Context) {
+	s.setCardFrozen(c, true, "Card frozen")
+}
+
+func (s *Server) UnfreezeCardHandler(c *gin.Context) {
+	s.setCardFrozen(c, false, "Card unfrozen")
+}
+
+func (s *Server) setCardFrozen(c *gin.Context, frozen bool, message string) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	card, err := s.db.SetCardFrozen(clientIdFrom(c), cardId, frozen)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "card": card})
+}
+
+// SetCardControlsHandler turns online payments on or off and sets the
+// per-transaction limit of a card; a limit of 0 removes it
+func (s *Server) SetCardControlsHandler(c *gin.Context) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	var req database.CardControlsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	card, err := s.db.SetCardControls(clientIdFrom(c), cardId, req)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Card controls updated", "card": card})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bank_core/database"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// holdErrorStatus maps hold errors to HTTP statuses
+func holdErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrAccountNotFound), errors.Is(err, database.ErrHoldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrHoldReleased):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidHold), errors.Is(err, database.ErrAccountNotActive), errors.Is(err, database.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetHoldsHandler lists the active holds of a bank account, and released
+// ones with ?released=true
+func (s *Server) GetHoldsHandler(c *gin.Context) {
+	accountId, ok := parseAccountId(c)
+	if !ok {
+		return
+	}
+
+	holds, err := s.db.GetHolds(accountId, c.Query("released") == "true")
+	if err != nil {
+		fmt.Printf("failed to fetch holds of account %d: %v\n", accountId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch holds"})
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+// PlaceHoldHandler puts an amount of a bank account on hold
+func (s *Server) PlaceHoldHandler(c *gin.Context) {
+	accountId, ok := parseAccountId(c)
+	if !ok {
+		return
+	}
+
+	var req database.HoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hold, err := s.db.PlaceHold(accountId, req)
+	if err != nil {
+		fmt.Printf("failed to place hold on account %d: %v\n", accountId, err)
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, hold)
+}
+
+// ReleaseHoldHandler returns the amount of a hold to its bank account
+func (s *Server) ReleaseHoldHandler(c *gin.Context) {
+	holdId, err := uuid.Parse(c.Param("holdId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return
+	}
+
+	hold, err := s.db.ReleaseHold(holdId)
+	if err != nil {
+		fmt.Printf("failed to release hold %s: %v\n", holdId, err)
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bank_core/database"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseCardType(c *gin.Context) (database.CardType, bool) {
+	cardType, err := strconv.Atoi(c.Param("cardType"))
+	if err != nil || database.CardType(cardType) < database.Debit || database.CardType(cardType) > database.Prepaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card type"})
+		return 0, false
+	}
+	return database.CardType(cardType), true
+}
+
+func (s *Server) GetCardTypeLimitsHandler(c *gin.Context) {
+	cardType, ok := parseCardType(c)
+	if !ok {
+		return
+	}
+
+	limits, err := s.db.GetCardTypeLimits(cardType)
+	if err != nil {
+		fmt.Printf("failed to fetch limits of card type %d: %v\n", cardType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch card type limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// SetCardTypeLimitsHandler sets the limits of every card of a type that has
+// no limits of its own; a limit of 0 removes it
+func (s *Server) SetCardTypeLimitsHandler(c *gin.Context) {
+	cardType, ok := parseCardType(c)
+	if !ok {
+		return
+	}
+
+	var limits database.SpendingLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	if err := s.db.SetCardTypeLimits(cardType, limits); err != nil {
+		fmt.Printf("failed to set limits of card type %d: %v\n", cardType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set card type limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Card type limits updated"})
+}
+
+// SetCardLimitsHandler overrides the card type's limits for a single card
+func (s *Server) SetCardLimitsHandler(c *gin.Context) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	var req database.CardLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	card, err := s.db.SetCardLimits(cardId, req)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Card limits updated", "card": card})
+}
+
+// GetCardLimitsHandler shows an account holder the limits of their card and
+// how much of each is left today
+func (s *Server) GetCardLimitsHandler(c *gin.Context) {
+	cardId, ok := parseCardId(c)
+	if !ok {
+		return
+	}
+
+	overview, err := s.db.GetCardLimitsOverview(clientIdFrom(c), cardId)
+	if err != nil {
+		c.JSON(cardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bank_core/database"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RefundPaymentHandler refunds all or part of a payment the bank acquired
+// with one of its own cards
+func (s *Server) RefundPaymentHandler(c *gin.Context) {
+	acquirerOrderId, err := uuid.Parse(c.Param("acquirerOrderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid acquirer order ID"})
+		return
+	}
+
+	var req database.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := s.db.RefundPayment(acquirerOrderId, req)
+	switch {
+	case errors.Is(err, database.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRefundNotOnUs):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRefundExceedsPayment), errors.Is(err, database.ErrInsufficientFunds):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case err != nil:
+		fmt.Printf("failed to refund payment %s: %v\n", acquirerOrderId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund payment"})
+	default:
+		c.JSON(http.StatusCreated, refund)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bank_core/database"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) RegisterRoutes() http.Handler {
+	r := gin.Default()
+
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:5173"}, // Add your frontend URL
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"Content-Disposition"},
+		AllowCredentials: true, // Enable cookies/auth
+	}))
+
+	r.GET("/", s.HelloWorldHandler)
+
+	r.GET("/health", s.healthHandler)
+
+	r.POST("/new-transaction", s.NewTransactionHandler)
+	r.POST("/payment", s.PaymentHandler)
+
+	// Payments with the bank's cards that PCC routes from other banks
+	pccAuth := s.PCCAuthMiddleware()
+	r.POST("/authorizations", pccAuth, s.AuthorizationHandler)
+	r.POST("/authorizations/advice", pccAuth, s.AdviceHandler)
+
+	r.POST("/payments/:acquirerOrderId/refunds", s.GatewayAuthMiddleware(), s.RefundPaymentHandler)
+
+	// Back office; issued cards are answered with their PAN and CVV2
+	adminAuth := s.AdminAuthMiddleware()
+	r.POST("/accounts/:accountId/cards", adminAuth, s.IssueCardHandler)
+	r.GET("/cards/:cardId", adminAuth, s.GetCardHandler)
+	r.POST("/cards/:cardId/block", adminAuth, s.BlockCardHandler)
+	r.POST("/cards/:cardId/unblock", adminAuth, s.UnblockCardHandler)
+	r.POST("/cards/:cardId/replace", adminAuth, s.ReplaceCardHandler)
+	r.PUT("/cards/:cardId/limits", adminAuth, s.SetCardLimitsHandler)
+
+	r.GET("/card-type-limits/:cardType", s.GetCardTypeLimitsHandler)
+	r.PUT("/card-type-limits/:cardType", adminAuth, s.SetCardTypeLimitsHandler)
+
+	r.PUT("/admin/clients/:clientId/password", adminAuth, s.SetClientPasswordHandler)
+
+	// Online banking
+	r.POST("/banking/login", s.ClientLoginHandler)
+	banking := r.Group("/banking", s.ClientAuthMiddleware())
+	banking.PUT("/password", s.ChangePasswordHandler)
+	banking.GET("/accounts", s.GetAccountsHandler)
+	banking.GET("/accounts/:accountId", s.GetAccountHandler)
+	banking.GET("/accounts/:accountId/transactions", s.GetAccountTransactionsHandler)
+	banking.GET("/accounts/:accountId/statements/:month", s.GetStatementHandler)
+	banking.GET("/cards", s.GetClientCardsHandler)
+	banking.POST("/cards/:cardId/freeze", s.FreezeCardHandler)
+	banking.POST("/cards/:cardId/unfreeze", s.UnfreezeCardHandler)
+	banking.PUT("/cards/:cardId/controls", s.SetCardControlsHandler)
+	banking.GET("/cards/:cardId/limits", s.GetCardLimitsHandler)
+
+	r.GET("/admin/reencryption", adminAuth, s.ReencryptionProgressHandler)
+	r.POST("/admin/reencryption", adminAuth, s.StartReencryptionHandler)
+	r.GET("/admin/ledger/consistency", adminAuth, s.LedgerConsistencyHandler)
+	r.POST("/admin/settlements", adminAuth, s.ImportSettlementHandler)
+	r.GET("/admin/accounts/:accountId/holds", adminAuth, s.GetHoldsHandler)
+	r.POST("/admin/accounts/:accountId/holds", adminAuth, s.PlaceHoldHandler)
+	r.POST("/admin/holds/:holdId/release", adminAuth, s.ReleaseHoldHandler)
+	return r
+}
+
+func (s *Server) HelloWorldHandler(c *gin.Context) {
+	resp := make(map[string]string)
+	resp["message"] = "Hello World"
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) healthHandler(c *gin.Context) {
+	health := s.db.Health()
+	health["bank"] = bankName
+	c.JSON(http.StatusOK, health)
+}
+
+func (s *Server) ReencryptionProgressHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.db.GetReencryptionProgress())
+}
+
+func (s *Server) StartReencryptionHandler(c *gin.Context) {
+	progress, err := s.db.StartReencryption()
+	if errors.Is(err, database.ErrReencryptionRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "progress": progress})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, progress)
+}
+
+// LedgerConsistencyHandler reports bank accounts whose balance drifted from
+// the ledger and journals that do not balance
+func (s *Server) LedgerConsistencyHandler(c *gin.Context) {
+	report, err := s.db.CheckLedger()
+	if err != nil {
+		fmt.Printf("ledger consistency check failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the ledger"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -9,21 +10,32 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 
-	"erstebank_microservice/internal/database"
+	"bank_core/database"
+	"bank_core/pcc"
 )
 
+// bankName is the name the bank runs under, shown in health checks and on
+// account statements
+var bankName = os.Getenv("BANK_NAME")
+
 type Server struct {
 	port int
 
-	db database.Service
+	db  database.Service
+	pcc *pcc.Client // nil when the bank is not connected to PCC
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	pccClient, err := pcc.NewClient()
+	if err != nil {
+		log.Fatalf("failed to configure PCC: %v", err)
+	}
 	NewServer := &Server{
 		port: port,
 
-		db: database.New(),
+		db:  database.New(),
+		pcc: pccClient,
 	}
 
 	// Declare Server config
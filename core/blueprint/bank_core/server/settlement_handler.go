@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bank_core/database"
+	"bank_core/pcc"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImportSettlementHandler fetches the bank's settlement file of a PCC
+// clearing cycle from PCC and posts its net positions to the ledger. The file
+// is only ever taken from PCC, never from the caller.
+func (s *Server) ImportSettlementHandler(c *gin.Context) {
+	if s.pcc == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Bank is not connected to PCC"})
+		return
+	}
+
+	var req struct {
+		CycleId uuid.UUID `json:"cycleId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	file, err := s.pcc.SettlementFile(req.CycleId)
+	if errors.Is(err, pcc.ErrCycleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to fetch settlement file of cycle %s: %v\n", req.CycleId, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch settlement file from PCC"})
+		return
+	}
+	if file.CycleId != req.CycleId || file.BankId != s.pcc.BankId() {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("PCC sent the settlement file of bank %d for cycle %s", file.BankId, file.CycleId)})
+		return
+	}
+
+	report, err := s.db.ImportSettlement(*file)
+	if errors.Is(err, database.ErrSettlementImported) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrInvalidSettlementFile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to import settlement of cycle %s: %v\n", file.CycleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import settlement file"})
+		return
+	}
+	if len(report.Unmatched) > 0 {
+		fmt.Printf("settlement of cycle %s: %d transactions without a successful record\n", file.CycleId, len(report.Unmatched))
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
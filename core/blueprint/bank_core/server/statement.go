@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bank_core/database"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+const statementDateFormat = "2006-01-02 15:04"
+
+// entryDescription describes a ledger entry to the account holder
+func entryDescription(t database.AccountTransaction) string {
+	switch t.Kind {
+	case database.EntryPayment:
+		if t.Amount < 0 {
+			return "Card payment"
+		}
+		return "Card payment received"
+	case database.EntryRefund:
+		if t.Amount < 0 {
+			return "Refund issued"
+		}
+		return "Refund"
+	case database.EntryHold:
+		return "Funds on hold"
+	case database.EntryHoldRelease:
+		return "Hold released"
+	case database.EntryFee:
+		return "Fee"
+	case database.EntryOpeningBalance:
+		return "Opening balance"
+	default:
+		return string(t.Kind)
+	}
+}
+
+func maskedPAN(t database.AccountTransaction) string {
+	if t.MaskedPAN == nil {
+		return ""
+	}
+	return *t.MaskedPAN
+}
+
+func merchantId(t database.AccountTransaction) string {
+	if t.MerchantId == nil {
+		return ""
+	}
+	return fmt.Sprint(*t.MerchantId)
+}
+
+// statementCSV renders a statement as one row per transaction with the
+// running balance, between opening and closing balance rows
+func statementCSV(statement *database.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	currency := statement.Account.Currency
+	balance := statement.OpeningBalance
+	w.Write([]string{"Date", "Description", "Merchant", "Merchant order", "Card", "Amount", "Balance", "Currency"})
+	w.Write([]string{statement.From.Format(statementDateFormat), "Opening balance", "", "", "", "", fmt.Sprintf("%.2f", balance), currency})
+
+	for _, t := range statement.Transactions {
+		balance += t.Amount
+		merchantOrder := ""
+		if t.MerchantOrderId != nil {
+			merchantOrder = t.MerchantOrderId.String()
+		}
+		w.Write([]string{
+			t.BookedAt.UTC().Format(statementDateFormat),
+			entryDescription(t),
+			merchantId(t),
+			merchantOrder,
+			maskedPAN(t),
+			fmt.Sprintf("%.2f", t.Amount),
+			fmt.Sprintf("%.2f", balance),
+			t.Currency,
+		})
+	}
+
+	last := statement.To.AddDate(0, 0, -1)
+	w.Write([]string{last.Format("2006-01-02") + " 23:59", "Closing balance", "", "", "", "", fmt.Sprintf("%.2f", statement.ClosingBalance), currency})
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// statementLines lays a statement out as fixed width text lines for the PDF
+func statementLines(statement *database.Statement) []string {
+	currency := statement.Account.Currency
+	last := statement.To.AddDate(0, 0, -1)
+
+	lines := []string{
+		bankName,
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("Account holder:   %s", statement.Holder),
+		fmt.Sprintf("Account number:   %s", statement.Account.AccountNumber),
+		fmt.Sprintf("Currency:         %s", currency),
+		fmt.Sprintf("Period:           %s - %s", statement.From.Format("02.01.2006"), last.Format("02.01.2006")),
+		"",
+		fmt.Sprintf("Opening balance:  %14.2f %s", statement.OpeningBalance, currency),
+		fmt.Sprintf("Total debits:     %14.2f %s", -statement.TotalDebits, currency),
+		fmt.Sprintf("Total credits:    %14.2f %s", statement.TotalCredits, currency),
+		fmt.Sprintf("Closing balance:  %14.2f %s", statement.ClosingBalance, currency),
+		"",
+		fmt.Sprintf("%-16s  %-22s  %-8s  %-16s  %12s  %12s", "Date", "Description", "Merchant", "Card", "Amount", "Balance"),
+		strings.Repeat("-", 94),
+	}
+
+	if len(statement.Transactions) == 0 {
+		lines = append(lines, "No transactions in this period.")
+	}
+
+	balance := statement.OpeningBalance
+	for _, t := range statement.Transactions {
+		balance += t.Amount
+		lines = append(lines, fmt.Sprintf("%-16s  %-22.22s  %-8.8s  %-16.16s  %12.2f  %12.2f",
+			t.BookedAt.UTC().Format(statementDateFormat), entryDescription(t), merchantId(t), maskedPAN(t), t.Amount, balance))
+	}
+
+	return lines
+}
+
+// statementPDF renders a statement as a PDF
+func statementPDF(statement *database.Statement) []byte {
+	return textPDF(statementLines(statement))
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultBankTimeout applies to banks registered without a timeout
+const DefaultBankTimeout = 15 * time.Second
+
+var (
+	ErrBankNotFound         = errors.New("bank not found")
+	ErrBankExists           = errors.New("bank already exists")
+	ErrInvalidBank          = errors.New("invalid bank")
+	ErrBankDisabled         = errors.New("bank is not accepting payments")
+	ErrCurrencyNotSupported = errors.New("bank does not accept the currency")
+)
+
+// Timeout is how long the gateway waits for the bank to answer
+func (b Bank) Timeout() time.Duration {
+	if b.TimeoutMs <= 0 {
+		return DefaultBankTimeout
+	}
+	return time.Duration(b.TimeoutMs) * time.Millisecond
+}
+
+// Accepts reports whether the bank takes payments in a currency
+func (b Bank) Accepts(currency string) bool {
+	if b.Currencies == "" {
+		return true
+	}
+	for _, accepted := range strings.Split(b.Currencies, ",") {
+		if strings.EqualFold(accepted, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccept checks that a payment in a currency can be forwarded to the bank
+func (b Bank) CanAccept(currency string) error {
+	if !b.Enabled {
+		return ErrBankDisabled
+	}
+	if !b.Accepts(currency) {
+		return fmt.Errorf("%w: %s", ErrCurrencyNotSupported, currency)
+	}
+	return nil
+}
+
+// ValidateBank normalises a bank's base URL and currencies and checks that
+// it can be routed to
+func ValidateBank(bank *Bank) error {
+	if bank.BankId == 0 || strings.TrimSpace(bank.Name) == "" {
+		return fmt.Errorf("%w: bankId and name are required", ErrInvalidBank)
+	}
+
+	baseURL, err := url.Parse(strings.TrimSpace(bank.BaseURL))
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return fmt.Errorf("%w: baseUrl must be an http or https URL", ErrInvalidBank)
+	}
+	bank.BaseURL = strings.TrimSuffix(baseURL.String(), "/")
+
+	currencies := []string{}
+	for _, currency := range strings.Split(bank.Currencies, ",") {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if currency == "" {
+			continue
+		}
+		if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return fmt.Errorf("%w: unknown currency %q", ErrInvalidBank, currency)
+		}
+		currencies = append(currencies, currency)
+	}
+	bank.Currencies = strings.Join(currencies, ",")
+
+	if bank.TimeoutMs < 0 {
+		return fmt.Errorf("%w: timeoutMs must not be negative", ErrInvalidBank)
+	}
+	return nil
+}
+
+const bankColumns = `bank_id, name, base_url, currencies, timeout_ms, enabled`
+
+func scanBank(row interface{ Scan(dest ...any) error }) (*Bank, error) {
+	var bank Bank
+	if err := row.Scan(&bank.BankId, &bank.Name, &bank.BaseURL, &bank.Currencies, &bank.TimeoutMs, &bank.Enabled); err != nil {
+		return nil, err
+	}
+	return &bank, nil
+}
+
+func (s *service) GetBanks() ([]Bank, error) {
+	rows, err := s.db.Query(`SELECT ` + bankColumns + ` FROM banks ORDER BY bank_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	banks := []Bank{}
+	for rows.Next() {
+		bank, err := scanBank(rows)
+		if err != nil {
+			return nil, err
+		}
+		banks = append(banks, *bank)
+	}
+	return banks, rows.Err()
+}
+
+func (s *service) GetBank(bankId uint) (*Bank, error) {
+	bank, err := scanBank(s.db.QueryRow(`SELECT `+bankColumns+` FROM banks WHERE bank_id = $1`, bankId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBankNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bank, nil
+}
+
+// SaveBank registers a bank, or updates the bank with the same bank ID
+func (s *service) SaveBank(bank Bank) (*Bank, error) {
+	if err := ValidateBank(&bank); err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO banks (` + bankColumns + `) VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (bank_id) DO UPDATE
+	          SET name = EXCLUDED.name, base_url = EXCLUDED.base_url, currencies = EXCLUDED.currencies,
+	              timeout_ms = EXCLUDED.timeout_ms, enabled = EXCLUDED.enabled`
+	_, err := s.db.Exec(query, bank.BankId, bank.Name, bank.BaseURL, bank.Currencies, bank.TimeoutMs, bank.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save bank: %w", err)
+	}
+	return &bank, nil
+}
+
+func (s *service) DeleteBank(bankId uint) error {
+	result, err := s.db.Exec(`DELETE FROM banks WHERE bank_id = $1`, bankId)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrBankNotFound
+	}
+	return nil
+}
+
+// GetMerchantBank returns the acquiring bank of a merchant
+func (s *service) GetMerchantBank(merchantId uint) (*Bank, error) {
+	bankId, err := s.GetBankByMerchantId(merchantId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBankNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBank(bankId)
+}
+
+// seedBanks registers the banks of BANK_SERVICE_URLS, comma separated
+// "bankId=url" pairs, while the registry is still empty, so deployments that
+// configured bank URLs before the registry keep routing
+func seedBanks(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&Bank{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	for _, entry := range strings.Split(os.Getenv("BANK_SERVICE_URLS"), ",") {
+		id, baseURL, found := strings.Cut(strings.TrimSpace(entry), "=")
+		bankId, err := strconv.ParseUint(id, 10, 32)
+		if !found || err != nil {
+			continue
+		}
+
+		bank := Bank{BankId: uint(bankId), Name: fmt.Sprintf("Bank %d", bankId), BaseURL: baseURL, Enabled: true}
+		if err := ValidateBank(&bank); err != nil {
+			log.Printf("bank %d from BANK_SERVICE_URLS not registered: %v", bankId, err)
+			continue
+		}
+		if err := db.Create(&bank).Error; err != nil {
+			return fmt.Errorf("bank %d: %w", bankId, err)
+		}
+	}
+	return nil
+}
@@ -28,6 +28,13 @@ type Service interface {
 	WriteTransaction(transaction Transaction) error
 
 	GetBankByMerchantId(merchantInfo uint) (uint, error)
+
+	// Registry of the acquiring banks payments are routed to
+	GetBanks() ([]Bank, error)
+	GetBank(bankId uint) (*Bank, error)
+	SaveBank(bank Bank) (*Bank, error)
+	DeleteBank(bankId uint) error
+	GetMerchantBank(merchantId uint) (*Bank, error)
 }
 
 type service struct {
@@ -162,5 +169,13 @@ func Connect() {
 	if err != nil {
 		return
 	}
+
+	err = db.AutoMigrate(&Bank{})
+	if err != nil {
+		return
+	}
+	if err := seedBanks(db); err != nil {
+		log.Printf("failed to register banks from BANK_SERVICE_URLS: %v", err)
+	}
 	//DB = db
 }
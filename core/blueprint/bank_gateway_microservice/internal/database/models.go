@@ -15,14 +15,16 @@ type Transaction struct {
 	Currency        string    `json:"currency" binding:"required"`
 }
 type PaymentRequest struct {
-	ExpDate         time.Time `json:"expDate" binding:"required"`
-	CardNumber      string    `json:"cardNumber" binding:"required"`
-	Currency        string    `json:"currency" binding:"required"`
-	Amount          float32   `json:"amount" binding:"required"`
-	MerchantId      uint      `json:"merchantId" binding:"required"`
-	MerchantOrderId uuid.UUID `json:"merchantOrderId" binding:"required"`
-	TransactionId   uuid.UUID `json:"transactionId" binding:"required"`
-	Timestamp       time.Time `json:"timestamp" binding:"required"`
+	ExpDate    time.Time `json:"expDate" binding:"required"`
+	CardNumber string    `json:"cardNumber" binding:"required"`
+	// Passed on to the issuer in memory only, never stored or logged
+	CardVerificationCode string    `json:"cardVerificationCode" binding:"required"`
+	Currency             string    `json:"currency" binding:"required"`
+	Amount               float32   `json:"amount" binding:"required"`
+	MerchantId           uint      `json:"merchantId" binding:"required"`
+	MerchantOrderId      uuid.UUID `json:"merchantOrderId" binding:"required"`
+	TransactionId        uuid.UUID `json:"transactionId" binding:"required"`
+	Timestamp            time.Time `json:"timestamp" binding:"required"`
 }
 
 type TransactionResponse struct {
@@ -31,6 +33,10 @@ type TransactionResponse struct {
 	MerchantOrderId   uuid.UUID         `json:"merchantOrderId" binding:"required"`
 	TransactionId     uuid.UUID         `json:"transactionId" binding:"required"`
 	Status            TransactionStatus `json:"status" binding:"required"`
+	// Sent by the bank when a card payment is declined and passed on to the
+	// PSP as is, e.g. 12 and currency_mismatch
+	DeclineCode   string `json:"declineCode,omitempty"`
+	DeclineReason string `json:"declineReason,omitempty"`
 }
 
 type MerchantInfo struct {
@@ -38,6 +44,19 @@ type MerchantInfo struct {
 	BankId     uint `json:"bankId"`
 }
 
+// Bank is an acquiring bank the gateway forwards the payments of its
+// merchants to
+type Bank struct {
+	BankId  uint   `gorm:"primaryKey;autoIncrement:false" json:"bankId"`
+	Name    string `json:"name"`
+	BaseURL string `json:"baseUrl"`
+	// Comma-separated ISO 4217 codes of the currencies the bank accepts, e.g.
+	// EUR,RSD; empty accepts every currency
+	Currencies string `json:"currencies"`
+	TimeoutMs  int    `json:"timeoutMs" binding:"min=0"` // Timeout of requests to the bank; 0 for the default
+	Enabled    bool   `json:"enabled"`
+}
+
 type TransactionStatus int
 
 const (
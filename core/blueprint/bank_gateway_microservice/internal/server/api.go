@@ -2,6 +2,7 @@ package server
 
 import (
 	"bank_gateway_microservice/internal/database"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -30,16 +31,27 @@ func (s *Server) PaymentHandler(c *gin.Context) {
 		return
 	}
 
-	bankId, err := s.db.GetBankByMerchantId(req.MerchantId)
-	fmt.Println("bank id")
-	fmt.Println(bankId)
-	if err != nil {
+	bank, err := s.db.GetMerchantBank(req.MerchantId)
+	if errors.Is(err, database.ErrBankNotFound) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Bank not recognized"})
-
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to find the bank of merchant %d: %v\n", req.MerchantId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error"})
+		return
+	}
+	if err := bank.CanAccept(req.Currency); err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, database.ErrCurrencyNotSupported) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"message": err.Error()})
+		return
 	}
 
 	transaction := database.Transaction{
-		RoutedBankId:    bankId,
+		RoutedBankId:    bank.BankId,
 		MerchantId:      req.MerchantId,
 		MerchantOrderId: req.MerchantOrderId,
 		Amount:          req.Amount,
@@ -52,9 +64,9 @@ func (s *Server) PaymentHandler(c *gin.Context) {
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Error"})
-
+		return
 	}
-	s.ForwardPaymentToBank(bankId, req)
+	s.ForwardPaymentToBank(*bank, req)
 	c.JSON(http.StatusOK, gin.H{"message": "Payment request forwarded to bank"})
 
 }
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bank_gateway_microservice/internal/database"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BankHealth is the outcome of calling a registered bank's health endpoint
+type BankHealth struct {
+	BankId    uint   `json:"bankId"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Status    string `json:"status"` // up or down
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// checkBankHealth calls the bank's /health endpoint within the bank's timeout
+func checkBankHealth(bank database.Bank) BankHealth {
+	health := BankHealth{BankId: bank.BankId, Name: bank.Name, Enabled: bank.Enabled, Status: "down"}
+
+	client := &http.Client{Timeout: bank.Timeout()}
+	start := time.Now()
+	resp, err := client.Get(bank.BaseURL + "/health")
+	health.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		health.Error = fmt.Sprintf("health check returned %s", resp.Status)
+		return health
+	}
+	health.Status = "up"
+	return health
+}
+
+func parseBankId(c *gin.Context) (uint, bool) {
+	bankId, err := strconv.ParseUint(c.Param("bankId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bankId"})
+		return 0, false
+	}
+	return uint(bankId), true
+}
+
+func (s *Server) GetBanksHandler(c *gin.Context) {
+	banks, err := s.db.GetBanks()
+	if err != nil {
+		fmt.Printf("failed to fetch banks: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch banks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, banks)
+}
+
+func (s *Server) GetBankHandler(c *gin.Context) {
+	bankId, ok := parseBankId(c)
+	if !ok {
+		return
+	}
+
+	bank, err := s.db.GetBank(bankId)
+	if errors.Is(err, database.ErrBankNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to fetch bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bank"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bank)
+}
+
+// SaveBankHandler registers a bank, or on PUT updates the bank in the path
+func (s *Server) SaveBankHandler(c *gin.Context) {
+	var bank database.Bank
+	if err := c.ShouldBindJSON(&bank); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	if c.Request.Method == http.MethodPut {
+		bankId, ok := parseBankId(c)
+		if !ok {
+			return
+		}
+		if _, err := s.db.GetBank(bankId); errors.Is(err, database.ErrBankNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		bank.BankId = bankId
+	} else if _, err := s.db.GetBank(bank.BankId); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": database.ErrBankExists.Error()})
+		return
+	}
+
+	saved, err := s.db.SaveBank(bank)
+	if errors.Is(err, database.ErrInvalidBank) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to save bank %d: %v\n", bank.BankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save bank"})
+		return
+	}
+
+	status := http.StatusOK
+	if c.Request.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	c.JSON(status, saved)
+}
+
+func (s *Server) DeleteBankHandler(c *gin.Context) {
+	bankId, ok := parseBankId(c)
+	if !ok {
+		return
+	}
+
+	err := s.db.DeleteBank(bankId)
+	if errors.Is(err, database.ErrBankNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to delete bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bank"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bank deleted"})
+}
+
+// BanksHealthHandler checks the health of every registered bank at once
+func (s *Server) BanksHealthHandler(c *gin.Context) {
+	banks, err := s.db.GetBanks()
+	if err != nil {
+		fmt.Printf("failed to fetch banks: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch banks"})
+		return
+	}
+
+	results := make([]BankHealth, len(banks))
+	var wg sync.WaitGroup
+	for i, bank := range banks {
+		wg.Add(1)
+		go func(i int, bank database.Bank) {
+			defer wg.Done()
+			results[i] = checkBankHealth(bank)
+		}(i, bank)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (s *Server) BankHealthHandler(c *gin.Context) {
+	bankId, ok := parseBankId(c)
+	if !ok {
+		return
+	}
+
+	bank, err := s.db.GetBank(bankId)
+	if errors.Is(err, database.ErrBankNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to fetch bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bank"})
+		return
+	}
+
+	c.JSON(http.StatusOK, checkBankHealth(*bank))
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bank_gateway_microservice/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckBankHealth(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("health check called %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name    string
+		baseURL string
+		status  string
+	}{
+		{"up", up.URL, "up"},
+		{"failing", failing.URL, "down"},
+		{"unreachable", unreachable.URL, "down"},
+	}
+	for _, tt := range tests {
+		health := checkBankHealth(database.Bank{BankId: 1, Name: tt.name, BaseURL: tt.baseURL, Enabled: true})
+		if health.Status != tt.status {
+			t.Errorf("%s: got status %q want %q (%s)", tt.name, health.Status, tt.status, health.Error)
+		}
+		if tt.status == "down" && health.Error == "" {
+			t.Errorf("%s: down without an error", tt.name)
+		}
+	}
+}
@@ -10,22 +10,25 @@ import (
 	"net/http"
 )
 
-func (s *Server) ForwardPaymentToBank(bankId uint, transaction database.PaymentRequest) {
+// ForwardPaymentToBank posts a payment to the merchant's acquiring bank and
+// passes the bank's answer on to the PSP
+func (s *Server) ForwardPaymentToBank(bank database.Bank, transaction database.PaymentRequest) {
 
 	go func() {
 		fmt.Println("salje banci")
-		bankServiceURL := "http://erstebank_service:8080/payment"
+		bankServiceURL := bank.BaseURL + "/payment"
 		reqBody, err := json.Marshal(transaction)
-		fmt.Println("transaction")
-		fmt.Println(string(reqBody))
+		fmt.Println("transaction", transaction.TransactionId)
 		if err != nil {
 			// Handle error, perhaps log and return an error response to the client
 			return
 		}
 
-		resp, err := http.Post(bankServiceURL, "application/json", bytes.NewBuffer(reqBody))
+		client := &http.Client{Timeout: bank.Timeout()}
+		resp, err := client.Post(bankServiceURL, "application/json", bytes.NewBuffer(reqBody))
 		if err != nil {
-			// Handle error, perhaps log and retry or notify an admin
+			fmt.Printf("failed to forward payment %s to bank %d: %v\n", transaction.TransactionId, bank.BankId, err)
+			go processBankResponseForPSP(database.TransactionResponse{}, err)
 			return
 		}
 
@@ -62,6 +65,9 @@ func (s *Server) ForwardPaymentToBank(bankId uint, transaction database.PaymentR
 	}()
 }
 
+// pspCallbackURL is where the PSP takes the outcome of a payment
+var pspCallbackURL = "http://psp_service:8080/payment-callback"
+
 func processBankResponseForPSP(response database.TransactionResponse, bankError error) {
 
 	var responseBytes []byte
@@ -79,9 +85,8 @@ func processBankResponseForPSP(response database.TransactionResponse, bankError
 	}
 	fmt.Println(string(responseBytes))
 
-	pspURL := "http://psp_service:8080/payment-callback"
 	fmt.Println("sss")
-	req, err := http.NewRequest("PUT", pspURL, bytes.NewBuffer(responseBytes))
+	req, err := http.NewRequest("PUT", pspCallbackURL, bytes.NewBuffer(responseBytes))
 	if err != nil {
 		fmt.Println(err)
 		return
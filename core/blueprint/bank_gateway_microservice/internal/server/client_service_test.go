@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bank_gateway_microservice/internal/database"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestForwardPaymentPassesDeclineToPSP(t *testing.T) {
+	transactionId := uuid.New()
+
+	bank := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"message": "Failed payment", "transaction": {"transactionId": "%s", "status": %d,
+			"declineCode": "12", "declineReason": "currency_mismatch"}}`, transactionId, database.Failed)
+	}))
+	defer bank.Close()
+
+	callbacks := make(chan database.TransactionResponse, 1)
+	psp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response database.TransactionResponse
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			t.Errorf("invalid callback: %v", err)
+		}
+		callbacks <- response
+	}))
+	defer psp.Close()
+
+	defaultURL := pspCallbackURL
+	pspCallbackURL = psp.URL
+	defer func() { pspCallbackURL = defaultURL }()
+
+	s := &Server{}
+	s.ForwardPaymentToBank(database.Bank{BaseURL: bank.URL}, database.PaymentRequest{TransactionId: transactionId})
+
+	select {
+	case response := <-callbacks:
+		if response.TransactionId != transactionId || response.Status != database.Failed {
+			t.Errorf("unexpected callback %+v", response)
+		}
+		if response.DeclineCode != "12" || response.DeclineReason != "currency_mismatch" {
+			t.Errorf("decline not passed on: code %q, reason %q", response.DeclineCode, response.DeclineReason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PSP was not called back")
+	}
+}
@@ -23,6 +23,14 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.POST("/test-postgre", s.NewTransactionHandler)
 	r.POST("/payment", s.PaymentHandler)
 	r.PUT("/payment-callback", s.PaymentCallbackHandler)
+
+	r.GET("/admin/banks", s.GetBanksHandler)
+	r.POST("/admin/banks", s.SaveBankHandler)
+	r.GET("/admin/banks/health", s.BanksHealthHandler)
+	r.GET("/admin/banks/:bankId", s.GetBankHandler)
+	r.PUT("/admin/banks/:bankId", s.SaveBankHandler)
+	r.DELETE("/admin/banks/:bankId", s.DeleteBankHandler)
+	r.GET("/admin/banks/:bankId/health", s.BankHealthHandler)
 	return r
 }
 
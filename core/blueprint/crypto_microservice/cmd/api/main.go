@@ -5,10 +5,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"crypto_microservice/internal/blockchain"
 	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/keystore"
+	"crypto_microservice/internal/payout"
+	"crypto_microservice/internal/rates"
 	"crypto_microservice/internal/server"
 )
 
@@ -17,12 +23,56 @@ func main() {
 	dbService := database.New()
 	defer dbService.Close()
 
+	// Initialize keystore for merchant wallet private keys
+	keys, err := keystore.New(dbService)
+	if err != nil {
+		log.Fatalf("Failed to initialize keystore: %v", err)
+	}
+
 	// Initialize blockchain monitor
 	monitor := blockchain.NewMonitor(dbService)
+	if os.Getenv("CHAIN_SOURCE") == "testnet" {
+		sources, err := blockchain.NewTestnetSources()
+		if err != nil {
+			log.Fatalf("Failed to initialize chain sources: %v", err)
+		}
+		monitor.SetTransactionSources(sources)
+	}
+	if workers := os.Getenv("MONITOR_WORKERS"); workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil {
+			log.Fatalf("Invalid MONITOR_WORKERS: %v", err)
+		}
+		monitor.SetWorkers(n)
+	}
 	go monitor.Start()
 
+	// Exchange rates for refunds and confirmation policies
+	var rateSource rates.Source
+	if os.Getenv("RATE_SOURCE") == "static" {
+		log.Println("Using static example exchange rates")
+		rateSource = rates.NewStaticSource()
+	} else {
+		fiats := []string{"RSD", "EUR", "USD"}
+		if list := os.Getenv("RATE_FIAT_CURRENCIES"); list != "" {
+			fiats = strings.Split(list, ",")
+		}
+		rateSource = rates.NewCoinGeckoSource(os.Getenv("RATE_API_URL"), fiats)
+	}
+
+	// Initialize payouts and deposit sweeps
+	payouts := payout.NewService(dbService, keys, blockchain.NewSimulatedProviders(), rateSource)
+	if delay := os.Getenv("PAYOUT_ADDRESS_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			log.Fatalf("Invalid PAYOUT_ADDRESS_DELAY: %v", err)
+		}
+		payouts.SetAddressDelay(d)
+	}
+	go payouts.Start()
+
 	// Create server
-	srv := server.NewServer(dbService, monitor)
+	srv := server.NewServer(dbService, monitor, keys, payouts, rateSource)
 
 	// Graceful shutdown
 	go func() {
@@ -32,6 +82,7 @@ func main() {
 
 		log.Println("Shutting down gracefully...")
 		monitor.Stop()
+		payouts.Stop()
 	}()
 
 	// Start server
@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+)
+
+// Human readable parts for Bitcoin segwit addresses
+const (
+	BTCMainnetHrp = "bc"
+	BTCTestnetHrp = "tb"
+)
+
+// btcHrp returns the segwit address prefix for the network
+func btcHrp(testnet bool) string {
+	if testnet {
+		return BTCTestnetHrp
+	}
+	return BTCMainnetHrp
+}
+
+// hash160 computes RIPEMD160(SHA256(data))
+func hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
+
+// keccak256 computes the legacy Keccak-256 hash used by Ethereum
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// P2WPKHAddress returns the Bech32 pay-to-witness-public-key-hash address
+// for a compressed secp256k1 public key
+func P2WPKHAddress(compressedPubKey []byte, testnet bool) (string, error) {
+	if len(compressedPubKey) != 33 {
+		return "", fmt.Errorf("P2WPKH requires a 33-byte compressed public key")
+	}
+	return EncodeSegwitAddress(btcHrp(testnet), 0, hash160(compressedPubKey))
+}
+
+// EthereumAddress returns the EIP-55 checksummed address for an uncompressed
+// secp256k1 public key: the last 20 bytes of Keccak-256(X || Y)
+func EthereumAddress(uncompressedPubKey []byte) (string, error) {
+	if len(uncompressedPubKey) != 65 || uncompressedPubKey[0] != 0x04 {
+		return "", fmt.Errorf("ethereum address requires a 65-byte uncompressed public key")
+	}
+	hash := keccak256(uncompressedPubKey[1:])
+	return ToChecksumAddress(hex.EncodeToString(hash[12:])), nil
+}
+
+// ToChecksumAddress applies EIP-55 mixed-case checksum encoding to a
+// 20-byte hex address, with or without the 0x prefix
+func ToChecksumAddress(address string) string {
+	addr := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X"))
+	hash := hex.EncodeToString(keccak256([]byte(addr)))
+
+	out := []byte(addr)
+	for i, ch := range out {
+		if ch >= 'a' && ch <= 'f' && hash[i] >= '8' {
+			out[i] = ch - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
+
+// ValidateAddress validates that an address is well-formed for the currency
+// and network. Bitcoin addresses must be segwit addresses with a valid
+// Bech32/Bech32m checksum and the network's prefix. Ethereum addresses must
+// be 20 bytes of hex; mixed-case addresses must carry a valid EIP-55 checksum.
+func ValidateAddress(address string, currency string, testnet bool) error {
+	switch currency {
+	case "BTC":
+		if _, _, err := DecodeSegwitAddress(btcHrp(testnet), strings.ToLower(address)); err != nil {
+			return fmt.Errorf("invalid bitcoin address: %w", err)
+		}
+		if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+			return fmt.Errorf("invalid bitcoin address: mixed case")
+		}
+		return nil
+	case "ETH", "USDT":
+		if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+			return fmt.Errorf("invalid ethereum address: expected 0x followed by 40 hex characters")
+		}
+		body := address[2:]
+		if _, err := hex.DecodeString(body); err != nil {
+			return fmt.Errorf("invalid ethereum address: %w", err)
+		}
+		if body == strings.ToLower(body) || body == strings.ToUpper(body) {
+			return nil
+		}
+		if ToChecksumAddress(address) != address {
+			return fmt.Errorf("invalid ethereum address: EIP-55 checksum mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported currency: %s", currency)
+	}
+}
+
+// NormalizeAddress returns the canonical form of an address so it can be
+// compared with stored addresses: lowercase for segwit, EIP-55 for Ethereum
+func NormalizeAddress(address string, currency string) string {
+	switch currency {
+	case "BTC":
+		return strings.ToLower(address)
+	case "ETH", "USDT":
+		return ToChecksumAddress(address)
+	default:
+		return address
+	}
+}
@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSegwitAddressVectors(t *testing.T) {
+	// Test vectors from BIP-173 and BIP-350
+	valid := []struct {
+		hrp     string
+		address string
+		version byte
+	}{
+		{"bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", 0},
+		{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", 0},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1},
+		{"tb", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", 1},
+	}
+	for _, v := range valid {
+		version, _, err := DecodeSegwitAddress(v.hrp, v.address)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", v.address, err)
+			continue
+		}
+		if version != v.version {
+			t.Errorf("%s: got version %d want %d", v.address, version, v.version)
+		}
+	}
+
+	invalid := []struct {
+		hrp     string
+		address string
+	}{
+		{"tb", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsy"},                     // bad checksum
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd"}, // v1 with bech32 checksum
+		{"tb", "tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47"}, // v0 with bech32m checksum
+		{"tb", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},                     // wrong network
+	}
+	for _, v := range invalid {
+		if _, _, err := DecodeSegwitAddress(v.hrp, v.address); err == nil {
+			t.Errorf("%s: expected decode error", v.address)
+		}
+	}
+}
+
+func TestP2WPKHAddress(t *testing.T) {
+	// BIP-173 example key
+	pubKey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+
+	address, err := P2WPKHAddress(pubKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4" {
+		t.Errorf("got %s", address)
+	}
+
+	address, err = P2WPKHAddress(pubKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx" {
+		t.Errorf("got %s", address)
+	}
+}
+
+func TestEthereumAddress(t *testing.T) {
+	// Private key 1 maps to the generator point
+	wallet, err := WalletFromPrivateKey("0000000000000000000000000000000000000000000000000000000000000001", "ETH", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Address != "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf" {
+		t.Errorf("got %s", wallet.Address)
+	}
+
+	// EIP-55 test vectors
+	for _, address := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		if err := ValidateAddress(address, "ETH", true); err != nil {
+			t.Errorf("%s: %v", address, err)
+		}
+	}
+
+	if err := ValidateAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "ETH", true); err == nil {
+		t.Error("expected checksum mismatch")
+	}
+}
+
+func TestGeneratedWalletsValidate(t *testing.T) {
+	for _, currency := range []string{"BTC", "ETH", "USDT"} {
+		wallet, err := GenerateWallet(currency, true)
+		if err != nil {
+			t.Fatalf("%s: %v", currency, err)
+		}
+		if err := ValidateAddress(wallet.Address, currency, true); err != nil {
+			t.Errorf("%s: generated address %s is invalid: %v", currency, wallet.Address, err)
+		}
+
+		restored, err := WalletFromPrivateKey(wallet.GetPrivateKeyHex(), currency, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.Address != wallet.Address {
+			t.Errorf("%s: restored address %s want %s", currency, restored.Address, wallet.Address)
+		}
+
+		sig, err := wallet.SignMessage([]byte("rent-a-car"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := VerifySignature(wallet.PublicKey, []byte("rent-a-car"), sig)
+		if err != nil || !ok {
+			t.Errorf("%s: signature did not verify: %v", currency, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"math"
+	"time"
+
+	"crypto_microservice/internal/database"
+)
+
+// amountEpsilon absorbs float rounding below the 8 decimals stored in the database
+const amountEpsilon = 1e-9
+
+// ApplyReceivedAmount updates a payment's status and refund bookkeeping from
+// its received total.
+//
+//   - Received within tolerance of the requested amount, or more: the payment
+//     moves on to Confirming. Any excess beyond the tolerance is recorded as
+//     refund due.
+//   - Received less: the payment becomes PartiallyPaid and waits for a top-up
+//     until TopUpDeadline. Currencies configured with RefundUnderpayments
+//     fail immediately instead, with the whole received amount refund due.
+//
+// It reports whether the status changed.
+func ApplyReceivedAmount(payment *database.CryptoPayment, config database.CryptoConfig, now time.Time) bool {
+	previous := payment.Status
+
+	// Only payments still waiting for funds react to new transactions
+	if payment.Status != database.Pending &&
+		payment.Status != database.PartiallyPaid &&
+		payment.Status != database.Confirming {
+		return false
+	}
+
+	tolerance := payment.Amount * config.AmountTolerance
+	minimum := payment.Amount - tolerance
+	maximum := payment.Amount + tolerance
+
+	switch {
+	case payment.ReceivedAmount+amountEpsilon >= minimum:
+		payment.Status = database.Confirming
+		payment.RefundDueAmount = 0
+		if payment.ReceivedAmount > maximum+amountEpsilon {
+			payment.RefundDueAmount = RoundAmount(payment.ReceivedAmount - payment.Amount)
+		}
+	case payment.ReceivedAmount <= 0:
+		payment.Status = database.Pending
+	case config.RefundUnderpayments:
+		payment.Status = database.PaymentFailed
+		payment.RefundDueAmount = payment.ReceivedAmount
+	default:
+		payment.Status = database.PartiallyPaid
+		if payment.TopUpDeadline == nil {
+			deadline := now.Add(config.TopUpWindow)
+			payment.TopUpDeadline = &deadline
+		}
+	}
+
+	return payment.Status != previous
+}
+
+// ExpirePartialPayment fails a partially paid payment whose top-up window
+// has passed and marks everything received so far as refund due. It reports
+// whether the payment was expired.
+func ExpirePartialPayment(payment *database.CryptoPayment, now time.Time) bool {
+	if payment.Status != database.PartiallyPaid || payment.TopUpDeadline == nil {
+		return false
+	}
+	if now.Before(*payment.TopUpDeadline) {
+		return false
+	}
+
+	payment.Status = database.Expired
+	payment.RefundDueAmount = payment.ReceivedAmount
+	return true
+}
+
+// RemainingAmount returns how much the customer still has to send
+func RemainingAmount(payment *database.CryptoPayment) float64 {
+	remaining := payment.Amount - payment.ReceivedAmount
+	if remaining < amountEpsilon {
+		return 0
+	}
+	return RoundAmount(remaining)
+}
+
+// RoundAmount rounds to the 8 decimals stored in the database
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*1e8) / 1e8
+}
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"crypto_microservice/internal/database"
+)
+
+func TestApplyReceivedAmount(t *testing.T) {
+	config := database.CryptoConfig{AmountTolerance: 0.01, TopUpWindow: time.Hour}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		received   float64
+		refundMode bool
+		wantStatus database.PaymentStatus
+		wantRefund float64
+	}{
+		{"exact", 1.0, false, database.Confirming, 0},
+		{"within tolerance short", 0.995, false, database.Confirming, 0},
+		{"within tolerance over", 1.005, false, database.Confirming, 0},
+		{"overpaid", 1.5, false, database.Confirming, 0.5},
+		{"underpaid waits for top-up", 0.5, false, database.PartiallyPaid, 0},
+		{"underpaid refunded", 0.5, true, database.PaymentFailed, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config
+			cfg.RefundUnderpayments = tt.refundMode
+			payment := &database.CryptoPayment{Amount: 1.0, ReceivedAmount: tt.received, Status: database.Pending}
+
+			if !ApplyReceivedAmount(payment, cfg, now) {
+				t.Fatal("expected status change")
+			}
+			if payment.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", payment.Status, tt.wantStatus)
+			}
+			if payment.RefundDueAmount != tt.wantRefund {
+				t.Errorf("refund due = %f, want %f", payment.RefundDueAmount, tt.wantRefund)
+			}
+		})
+	}
+}
+
+func TestPartialPaymentTopUpAndExpiry(t *testing.T) {
+	config := database.CryptoConfig{AmountTolerance: 0.01, TopUpWindow: time.Hour}
+	now := time.Now()
+
+	payment := &database.CryptoPayment{Amount: 1.0, ReceivedAmount: 0.4, Status: database.Pending}
+	ApplyReceivedAmount(payment, config, now)
+	if payment.TopUpDeadline == nil || !payment.TopUpDeadline.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected top-up deadline %v", payment.TopUpDeadline)
+	}
+
+	// A second partial transaction keeps the original deadline
+	payment.ReceivedAmount = 0.7
+	ApplyReceivedAmount(payment, config, now.Add(30*time.Minute))
+	if !payment.TopUpDeadline.Equal(now.Add(time.Hour)) {
+		t.Error("top-up deadline moved")
+	}
+	if RemainingAmount(payment) != 0.3 {
+		t.Errorf("remaining = %f, want 0.3", RemainingAmount(payment))
+	}
+
+	if ExpirePartialPayment(payment, now.Add(59*time.Minute)) {
+		t.Fatal("expired before the deadline")
+	}
+	if !ExpirePartialPayment(payment, now.Add(time.Hour)) {
+		t.Fatal("expected expiry at the deadline")
+	}
+	if payment.Status != database.Expired || payment.RefundDueAmount != 0.7 {
+		t.Errorf("got status %s refund %f", payment.Status, payment.RefundDueAmount)
+	}
+}
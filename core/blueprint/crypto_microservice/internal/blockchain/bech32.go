@@ -0,0 +1,220 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bech32 (BIP-173) and Bech32m (BIP-350) encoding for segwit addresses.
+// Witness version 0 programs use Bech32, versions 1-16 use Bech32m.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+type bech32Encoding int
+
+const (
+	encodingBech32 bech32Encoding = iota
+	encodingBech32m
+)
+
+// Checksum constants XORed into the polymod result
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func (e bech32Encoding) constant() uint32 {
+	if e == encodingBech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+func bech32CreateChecksum(hrp string, data []byte, enc bech32Encoding) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(values) ^ enc.constant()
+
+	checksum := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		checksum[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
+
+// bech32Encode encodes 5-bit groups with the given human readable part
+func bech32Encode(hrp string, data []byte, enc bech32Encoding) string {
+	combined := append(append([]byte{}, data...), bech32CreateChecksum(hrp, data, enc)...)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, b := range combined {
+		sb.WriteByte(bech32Charset[b])
+	}
+	return sb.String()
+}
+
+// bech32Decode decodes a Bech32 or Bech32m string and reports which
+// checksum variant it carries
+func bech32Decode(s string) (string, []byte, bech32Encoding, error) {
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("bech32 string too long")
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, fmt.Errorf("bech32 string has mixed case")
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, 0, fmt.Errorf("invalid bech32 separator position")
+	}
+
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid character in bech32 prefix")
+		}
+	}
+
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		idx := strings.IndexByte(bech32Charset, s[i])
+		if idx < 0 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 character %q", s[i])
+		}
+		data = append(data, byte(idx))
+	}
+
+	var enc bech32Encoding
+	switch bech32Polymod(append(bech32HrpExpand(hrp), data...)) {
+	case bech32Const:
+		enc = encodingBech32
+	case bech32mConst:
+		enc = encodingBech32m
+	default:
+		return "", nil, 0, fmt.Errorf("invalid bech32 checksum")
+	}
+
+	return hrp, data[:len(data)-6], enc, nil
+}
+
+// convertBits regroups a byte slice from one bit width to another
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data range")
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return out, nil
+}
+
+// EncodeSegwitAddress encodes a witness program as a segwit address
+func EncodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if version > 16 {
+		return "", fmt.Errorf("invalid witness version %d", version)
+	}
+
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	enc := encodingBech32
+	if version > 0 {
+		enc = encodingBech32m
+	}
+
+	address := bech32Encode(hrp, append([]byte{version}, data...), enc)
+
+	// Round-trip to make sure the program length is valid for the version
+	if _, _, err := DecodeSegwitAddress(hrp, address); err != nil {
+		return "", err
+	}
+
+	return address, nil
+}
+
+// DecodeSegwitAddress decodes a segwit address and returns its witness
+// version and program. The prefix must match the expected network.
+func DecodeSegwitAddress(expectedHrp, address string) (byte, []byte, error) {
+	hrp, data, enc, err := bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if hrp != expectedHrp {
+		return 0, nil, fmt.Errorf("address prefix %q does not match network prefix %q", hrp, expectedHrp)
+	}
+	if len(data) < 1 {
+		return 0, nil, fmt.Errorf("empty witness data")
+	}
+
+	version := data[0]
+	if version > 16 {
+		return 0, nil, fmt.Errorf("invalid witness version %d", version)
+	}
+
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return 0, nil, fmt.Errorf("invalid witness program length %d", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return 0, nil, fmt.Errorf("invalid witness v0 program length %d", len(program))
+	}
+	if version == 0 && enc != encodingBech32 || version != 0 && enc != encodingBech32m {
+		return 0, nil, fmt.Errorf("wrong checksum variant for witness version %d", version)
+	}
+
+	return version, program, nil
+}
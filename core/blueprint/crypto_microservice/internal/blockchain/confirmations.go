@@ -0,0 +1,62 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"crypto_microservice/internal/database"
+)
+
+// DoubleSpendChecker is implemented by transaction sources that can tell
+// whether the inputs of an unconfirmed transaction were spent elsewhere
+type DoubleSpendChecker interface {
+	IsDoubleSpent(txHash string) (bool, error)
+}
+
+// ConfirmationsFor returns the confirmations a payment worth fiatValue
+// requires under a policy: those of the first tier whose limit covers the
+// value, or of the unlimited last tier
+func ConfirmationsFor(tiers []database.ConfirmationTier, fiatValue float64) int {
+	for _, tier := range tiers {
+		if tier.MaxFiatAmount <= 0 || fiatValue <= tier.MaxFiatAmount {
+			return tier.Confirmations
+		}
+	}
+	if len(tiers) == 0 {
+		return 0
+	}
+	return tiers[len(tiers)-1].Confirmations
+}
+
+// ValidateTiers checks that a policy's tiers have ascending limits, end with
+// an unlimited tier and never require fewer confirmations for more value
+func ValidateTiers(tiers []database.ConfirmationTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("policy needs at least one tier")
+	}
+
+	for i, tier := range tiers {
+		if tier.Confirmations < 0 {
+			return fmt.Errorf("tier %d: confirmations cannot be negative", i+1)
+		}
+
+		last := i == len(tiers)-1
+		if last && tier.MaxFiatAmount != 0 {
+			return fmt.Errorf("last tier must have no limit (maxFiatAmount 0)")
+		}
+		if !last && tier.MaxFiatAmount <= 0 {
+			return fmt.Errorf("tier %d: only the last tier can be unlimited", i+1)
+		}
+
+		if i > 0 {
+			previous := tiers[i-1]
+			if !last && tier.MaxFiatAmount <= previous.MaxFiatAmount {
+				return fmt.Errorf("tier %d: limits must be ascending", i+1)
+			}
+			if tier.Confirmations < previous.Confirmations {
+				return fmt.Errorf("tier %d: larger payments cannot need fewer confirmations", i+1)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"testing"
+
+	"crypto_microservice/internal/database"
+)
+
+func TestConfirmationsFor(t *testing.T) {
+	tiers := database.SupportedCurrencies["BTC"].ConfirmationTiers
+	if err := ValidateTiers(tiers); err != nil {
+		t.Fatalf("default BTC policy is invalid: %v", err)
+	}
+
+	tests := []struct {
+		fiatValue float64
+		want      int
+	}{
+		{10, 0},
+		{50, 0},
+		{50.01, 1},
+		{1000, 1},
+		{9999, 3},
+		{250000, 6},
+	}
+
+	for _, tt := range tests {
+		if got := ConfirmationsFor(tiers, tt.fiatValue); got != tt.want {
+			t.Errorf("ConfirmationsFor(%.2f) = %d, want %d", tt.fiatValue, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []database.ConfirmationTier
+	}{
+		{"empty", nil},
+		{"limited last tier", []database.ConfirmationTier{{MaxFiatAmount: 100, Confirmations: 1}}},
+		{"unlimited middle tier", []database.ConfirmationTier{{Confirmations: 1}, {Confirmations: 2}}},
+		{"descending limits", []database.ConfirmationTier{
+			{MaxFiatAmount: 100, Confirmations: 1}, {MaxFiatAmount: 50, Confirmations: 2}, {Confirmations: 3}}},
+		{"fewer confirmations for more value", []database.ConfirmationTier{
+			{MaxFiatAmount: 100, Confirmations: 3}, {Confirmations: 1}}},
+		{"negative confirmations", []database.ConfirmationTier{{Confirmations: -1}}},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateTiers(tt.tiers); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	valid := []database.ConfirmationTier{{MaxFiatAmount: 20, Confirmations: 0}, {Confirmations: 2}}
+	if err := ValidateTiers(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
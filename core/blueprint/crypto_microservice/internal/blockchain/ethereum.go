@@ -0,0 +1,420 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransferEventTopic is the Keccak-256 hash of the ERC-20
+// Transfer(address,address,uint256) event signature, the first topic of
+// every Transfer log
+var TransferEventTopic = "0x" + hex.EncodeToString(keccak256([]byte("Transfer(address,address,uint256)")))
+
+// ethClient is a minimal Ethereum JSON-RPC client
+type ethClient struct {
+	rpcURL string
+	client *http.Client
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// EthLog is an event log as returned by eth_getLogs and in receipts
+type EthLog struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Removed         bool     `json:"removed"`
+}
+
+// EthReceipt is a transaction receipt as returned by eth_getTransactionReceipt
+type EthReceipt struct {
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	BlockNumber      string   `json:"blockNumber"`
+	Status           string   `json:"status"`
+	Logs             []EthLog `json:"logs"`
+}
+
+// call performs a JSON-RPC call and decodes its result into result
+func (c *ethClient) call(result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.rpcURL, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected HTTP status %d", method, resp.StatusCode)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s: RPC error %d: %s", method, rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// blockNumber returns the number of the latest block
+func (c *ethClient) blockNumber() (uint64, error) {
+	var result string
+	if err := c.call(&result, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return parseHexUint(result)
+}
+
+// transactionReceipt returns the receipt of a mined transaction, or nil if
+// the transaction is unknown or still pending
+func (c *ethClient) transactionReceipt(txHash string) (*EthReceipt, error) {
+	var receipt *EthReceipt
+	if err := c.call(&receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// confirmations returns the confirmation count of a block given the latest
+// block number; the block itself counts as the first confirmation
+func confirmations(block, latest uint64) int {
+	if block == 0 || latest < block {
+		return 0
+	}
+	return int(latest - block + 1)
+}
+
+// TokenTransfer is a decoded ERC-20 Transfer event
+type TokenTransfer struct {
+	TxHash        string
+	LogIndex      uint64
+	From          string
+	To            string
+	Value         *big.Int // Raw on-chain amount in the token's smallest unit
+	Amount        float64  // Value scaled by the token decimals
+	BlockNumber   uint64
+	Confirmations int
+}
+
+// ERC20Provider reads transfers of a single ERC-20 token contract from an
+// Ethereum JSON-RPC endpoint
+type ERC20Provider struct {
+	ethClient
+	contract string
+	decimals int
+}
+
+// NewERC20Provider creates a provider for the token contract at the given
+// address with the given number of decimals
+func NewERC20Provider(rpcURL, contract string, decimals int) (*ERC20Provider, error) {
+	if err := ValidateAddress(contract, "ETH", true); err != nil {
+		return nil, fmt.Errorf("invalid token contract: %w", err)
+	}
+	if decimals < 0 || decimals > 77 {
+		return nil, fmt.Errorf("invalid token decimals: %d", decimals)
+	}
+
+	return &ERC20Provider{
+		ethClient: ethClient{
+			rpcURL: rpcURL,
+			client: &http.Client{
+				Timeout: 30 * time.Second,
+			},
+		},
+		contract: ToChecksumAddress(contract),
+		decimals: decimals,
+	}, nil
+}
+
+// GetTransfers returns the token transfers to an address from fromBlock up
+// to the latest block
+func (p *ERC20Provider) GetTransfers(to string, fromBlock uint64) ([]TokenTransfer, error) {
+	if err := ValidateAddress(to, "ETH", true); err != nil {
+		return nil, err
+	}
+
+	latest, err := p.blockNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := map[string]interface{}{
+		"fromBlock": fmt.Sprintf("0x%x", fromBlock),
+		"toBlock":   "latest",
+		"address":   p.contract,
+		"topics":    []interface{}{TransferEventTopic, nil, addressTopic(to)},
+	}
+
+	var logs []EthLog
+	if err := p.call(&logs, "eth_getLogs", filter); err != nil {
+		return nil, err
+	}
+
+	transfers := make([]TokenTransfer, 0, len(logs))
+	for _, log := range logs {
+		transfer, ok, err := p.decodeTransfer(log)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		transfer.Confirmations = confirmations(transfer.BlockNumber, latest)
+		transfers = append(transfers, *transfer)
+	}
+
+	return transfers, nil
+}
+
+// GetReceiptTransfers returns the token transfers made by a mined
+// transaction. It returns nil if the transaction is not mined yet and an
+// error if it reverted.
+func (p *ERC20Provider) GetReceiptTransfers(txHash string) ([]TokenTransfer, error) {
+	receipt, err := p.transactionReceipt(txHash)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	if receipt.Status != "0x1" {
+		return nil, fmt.Errorf("transaction %s reverted", txHash)
+	}
+
+	latest, err := p.blockNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []TokenTransfer
+	for _, log := range receipt.Logs {
+		transfer, ok, err := p.decodeTransfer(log)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		transfer.Confirmations = confirmations(transfer.BlockNumber, latest)
+		transfers = append(transfers, *transfer)
+	}
+
+	return transfers, nil
+}
+
+// GetConfirmations returns the confirmation count of a transaction, zero
+// while it is pending
+func (p *ERC20Provider) GetConfirmations(txHash string) (int, error) {
+	receipt, err := p.transactionReceipt(txHash)
+	if err != nil || receipt == nil {
+		return 0, err
+	}
+
+	block, err := parseHexUint(receipt.BlockNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	latest, err := p.blockNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	return confirmations(block, latest), nil
+}
+
+// GetIncomingTransaction returns the tokens a mined transaction transfers to
+// the address. Token transfers are only visible once the transaction is
+// mined.
+func (p *ERC20Provider) GetIncomingTransaction(txHash, address string) (*IncomingTransaction, error) {
+	receipt, err := p.transactionReceipt(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("%w: not mined yet", ErrTransactionNotFound)
+	}
+
+	incoming := &IncomingTransaction{TxHash: receipt.TransactionHash}
+	if receipt.Status != "0x1" {
+		return incoming, nil
+	}
+
+	block, err := parseHexUint(receipt.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := p.blockNumber()
+	if err != nil {
+		return nil, err
+	}
+	incoming.BlockHeight = int64(block)
+	incoming.Confirmations = confirmations(block, latest)
+
+	to := ToChecksumAddress(address)
+	value := new(big.Int)
+	for _, log := range receipt.Logs {
+		transfer, ok, err := p.decodeTransfer(log)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || transfer.To != to {
+			continue
+		}
+		if incoming.FromAddress == "" {
+			incoming.FromAddress = transfer.From
+		}
+		value.Add(value, transfer.Value)
+	}
+	incoming.Amount = RoundAmount(FromBaseUnits(value, p.decimals))
+
+	return incoming, nil
+}
+
+// FindPayment looks for a transfer of exactly amount tokens to an address
+// since fromBlock. It returns nil if there is none yet.
+func (p *ERC20Provider) FindPayment(to string, amount float64, fromBlock uint64) (*TokenTransfer, error) {
+	transfers, err := p.GetTransfers(to, fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	return MatchTransfer(transfers, to, amount, p.decimals), nil
+}
+
+// MatchTransfer returns the first transfer paying exactly amount, at the
+// token's precision, to the address
+func MatchTransfer(transfers []TokenTransfer, to string, amount float64, decimals int) *TokenTransfer {
+	want := ToBaseUnits(amount, decimals)
+	to = ToChecksumAddress(to)
+
+	for i := range transfers {
+		if transfers[i].To == to && transfers[i].Value.Cmp(want) == 0 {
+			return &transfers[i]
+		}
+	}
+	return nil
+}
+
+// decodeTransfer decodes a Transfer log emitted by the provider's contract.
+// It reports false for logs of other contracts or events and for logs
+// removed by a reorg.
+func (p *ERC20Provider) decodeTransfer(log EthLog) (*TokenTransfer, bool, error) {
+	if log.Removed || !strings.EqualFold(log.Address, p.contract) {
+		return nil, false, nil
+	}
+	// Indexed from and to plus the signature; ERC-721 also indexes the value
+	if len(log.Topics) != 3 || !strings.EqualFold(log.Topics[0], TransferEventTopic) {
+		return nil, false, nil
+	}
+
+	from, err := topicAddress(log.Topics[1])
+	if err != nil {
+		return nil, false, err
+	}
+	to, err := topicAddress(log.Topics[2])
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(log.Data, "0x"))
+	if err != nil || len(data) != 32 {
+		return nil, false, fmt.Errorf("invalid transfer value in log of %s", log.TransactionHash)
+	}
+	value := new(big.Int).SetBytes(data)
+
+	block, err := parseHexUint(log.BlockNumber)
+	if err != nil {
+		return nil, false, err
+	}
+	logIndex, err := parseHexUint(log.LogIndex)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &TokenTransfer{
+		TxHash:      log.TransactionHash,
+		LogIndex:    logIndex,
+		From:        from,
+		To:          to,
+		Value:       value,
+		Amount:      FromBaseUnits(value, p.decimals),
+		BlockNumber: block,
+	}, true, nil
+}
+
+// addressTopic left-pads an address to a 32-byte log topic
+func addressTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// topicAddress extracts the address from a 32-byte log topic
+func topicAddress(topic string) (string, error) {
+	body := strings.TrimPrefix(topic, "0x")
+	if len(body) != 64 {
+		return "", fmt.Errorf("invalid address topic: %s", topic)
+	}
+	if _, err := hex.DecodeString(body); err != nil {
+		return "", fmt.Errorf("invalid address topic: %w", err)
+	}
+	return ToChecksumAddress(body[24:]), nil
+}
+
+// ToBaseUnits converts a token amount to the on-chain integer amount,
+// rounding to the token's decimals
+func ToBaseUnits(amount float64, decimals int) *big.Int {
+	digits := strings.Replace(strconv.FormatFloat(amount, 'f', decimals, 64), ".", "", 1)
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return value
+}
+
+// FromBaseUnits converts an on-chain integer amount to a token amount
+func FromBaseUnits(value *big.Int, decimals int) float64 {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	amount, _ := new(big.Rat).SetFrac(value, scale).Float64()
+	return amount
+}
+
+func parseHexUint(s string) (uint64, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex quantity %q: %w", s, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,222 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testToken     = "0xaa8e23fb1079ea71e0a56f48a2aa51851d8433d0"
+	testRecipient = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	testSender    = "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"
+)
+
+// rpcStub is a JSON-RPC server answering the calls made by ERC20Provider
+type rpcStub struct {
+	latest   uint64
+	logs     []EthLog
+	receipts map[string]*EthReceipt
+	txs      map[string]*ethTransaction
+	filters  []map[string]interface{}
+}
+
+func (s *rpcStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = fmt.Sprintf("0x%x", s.latest)
+	case "eth_getLogs":
+		var filter map[string]interface{}
+		json.Unmarshal(req.Params[0], &filter)
+		s.filters = append(s.filters, filter)
+		result = s.logs
+	case "eth_getTransactionReceipt":
+		var txHash string
+		json.Unmarshal(req.Params[0], &txHash)
+		result = s.receipts[txHash]
+	case "eth_getTransactionByHash":
+		var txHash string
+		json.Unmarshal(req.Params[0], &txHash)
+		result = s.txs[txHash]
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0", "id": 1,
+			"error": map[string]interface{}{"code": -32601, "message": "method not found"},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": result})
+}
+
+func transferLog(txHash string, block uint64, from, to string, value uint64) EthLog {
+	return EthLog{
+		Address:         testToken,
+		Topics:          []string{TransferEventTopic, addressTopic(from), addressTopic(to)},
+		Data:            fmt.Sprintf("0x%064x", value),
+		BlockNumber:     fmt.Sprintf("0x%x", block),
+		TransactionHash: txHash,
+		LogIndex:        "0x0",
+	}
+}
+
+func newStubProvider(t *testing.T, stub *rpcStub) *ERC20Provider {
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	provider, err := NewERC20Provider(server.URL, testToken, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestTransferEventTopic(t *testing.T) {
+	want := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	if TransferEventTopic != want {
+		t.Errorf("topic = %s, want %s", TransferEventTopic, want)
+	}
+}
+
+func TestBaseUnits(t *testing.T) {
+	if got := ToBaseUnits(12.5, 6).String(); got != "12500000" {
+		t.Errorf("ToBaseUnits = %s", got)
+	}
+	// Float noise is rounded away at the token's precision
+	if got := ToBaseUnits(0.1+0.2, 6).String(); got != "300000" {
+		t.Errorf("ToBaseUnits = %s", got)
+	}
+	if got := FromBaseUnits(ToBaseUnits(1234.567891, 6), 6); got != 1234.567891 {
+		t.Errorf("FromBaseUnits = %f", got)
+	}
+}
+
+func TestERC20ProviderFindPayment(t *testing.T) {
+	other := "0x0000000000000000000000000000000000000001"
+	foreign := transferLog("0xccc", 100, testSender, testRecipient, 25_000_000)
+	foreign.Address = other
+
+	stub := &rpcStub{
+		latest: 105,
+		logs: []EthLog{
+			transferLog("0xaaa", 100, testSender, testRecipient, 10_000_000),
+			foreign,
+			transferLog("0xbbb", 103, testSender, testRecipient, 25_000_000),
+		},
+	}
+	provider := newStubProvider(t, stub)
+
+	transfer, err := provider.FindPayment(ToChecksumAddress(testRecipient), 25, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer == nil {
+		t.Fatal("expected a matching transfer")
+	}
+	if transfer.TxHash != "0xbbb" || transfer.Amount != 25 || transfer.Confirmations != 3 {
+		t.Errorf("unexpected transfer %+v", transfer)
+	}
+	if transfer.From != ToChecksumAddress(testSender) {
+		t.Errorf("from = %s", transfer.From)
+	}
+
+	filter := stub.filters[0]
+	topics := filter["topics"].([]interface{})
+	if filter["fromBlock"] != "0x5a" || topics[0] != TransferEventTopic || topics[1] != nil ||
+		topics[2] != "0x000000000000000000000000"+testRecipient[2:] {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	if !strings.EqualFold(filter["address"].(string), testToken) {
+		t.Errorf("filter address = %v", filter["address"])
+	}
+
+	transfer, err = provider.FindPayment(testRecipient, 12, 90)
+	if err != nil || transfer != nil {
+		t.Errorf("expected no match, got %+v, %v", transfer, err)
+	}
+}
+
+func TestERC20ProviderReceipt(t *testing.T) {
+	stub := &rpcStub{
+		latest: 120,
+		receipts: map[string]*EthReceipt{
+			"0xaaa": {
+				TransactionHash: "0xaaa",
+				BlockNumber:     "0x6e",
+				Status:          "0x1",
+				Logs:            []EthLog{transferLog("0xaaa", 110, testSender, testRecipient, 1_500_000)},
+			},
+			"0xbad": {TransactionHash: "0xbad", BlockNumber: "0x6e", Status: "0x0"},
+		},
+	}
+	provider := newStubProvider(t, stub)
+
+	transfers, err := provider.GetReceiptTransfers("0xaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 || transfers[0].Amount != 1.5 || transfers[0].Confirmations != 11 {
+		t.Errorf("unexpected transfers %+v", transfers)
+	}
+
+	if _, err := provider.GetReceiptTransfers("0xbad"); err == nil {
+		t.Error("expected reverted transaction to fail")
+	}
+
+	confirmations, err := provider.GetConfirmations("0xaaa")
+	if err != nil || confirmations != 11 {
+		t.Errorf("confirmations = %d, %v", confirmations, err)
+	}
+
+	// Pending transactions have no receipt yet
+	confirmations, err = provider.GetConfirmations("0xpending")
+	if err != nil || confirmations != 0 {
+		t.Errorf("pending confirmations = %d, %v", confirmations, err)
+	}
+}
+
+func TestERC20ProviderIncomingTransaction(t *testing.T) {
+	other := "0x0000000000000000000000000000000000000002"
+	stub := &rpcStub{
+		latest: 110,
+		receipts: map[string]*EthReceipt{
+			"0xaaa": {
+				TransactionHash: "0xaaa",
+				BlockNumber:     "0x6e",
+				Status:          "0x1",
+				Logs: []EthLog{
+					transferLog("0xaaa", 110, testSender, testRecipient, 4_000_000),
+					transferLog("0xaaa", 110, testSender, other, 9_000_000),
+					transferLog("0xaaa", 110, testSender, testRecipient, 1_000_000),
+				},
+			},
+		},
+	}
+	provider := newStubProvider(t, stub)
+
+	incoming, err := provider.GetIncomingTransaction("0xaaa", testRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incoming.Amount != 5 || incoming.Confirmations != 1 || incoming.FromAddress != ToChecksumAddress(testSender) {
+		t.Errorf("unexpected incoming transaction %+v", incoming)
+	}
+
+	if _, err := provider.GetIncomingTransaction("0xpending", testRecipient); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"crypto_microservice/internal/database"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -14,19 +15,71 @@ type CallbackSender interface {
 	SendCallbackToPSP(payment *database.CryptoPayment)
 }
 
+const (
+	// DefaultMonitorWorkers is the number of payments checked concurrently
+	// unless set with SetWorkers
+	DefaultMonitorWorkers = 8
+
+	// monitorBatchSize is the number of open payments read per query
+	monitorBatchSize = 500
+)
+
+// Monitor polls the open payments of each currency from the database at the
+// currency's poll interval and checks them on a bounded pool of workers
 type Monitor struct {
 	db             database.Service
-	activePayments map[uuid.UUID]bool
-	mu             sync.RWMutex
 	stopChan       chan struct{}
 	callbackSender CallbackSender
+
+	// sources look up transactions on chain, by currency. Payments in
+	// currencies without a source are confirmed by simulation.
+	sources map[string]TransactionSource
+
+	workers int
+	jobs    chan monitorJob
+
+	metricsMu sync.Mutex
+	startedAt time.Time
+	metrics   map[string]*CurrencyMetrics
+}
+
+// monitorJob is a payment queued for a check during a poll cycle
+type monitorJob struct {
+	payment *database.CryptoPayment
+	cycle   *pollCycle
+}
+
+// pollCycle tracks the checks queued by one poll of a currency
+type pollCycle struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	errors int
 }
 
+func (c *pollCycle) fail() {
+	c.mu.Lock()
+	c.errors++
+	c.mu.Unlock()
+}
+
+func (c *pollCycle) done(err error) {
+	if err != nil {
+		c.fail()
+	}
+	c.wg.Done()
+}
+
+var (
+	ErrNoTransactionSource = errors.New("no transaction source for currency")
+	ErrWrongDestination    = errors.New("transaction does not pay the payment address")
+)
+
 func NewMonitor(db database.Service) *Monitor {
 	return &Monitor{
-		db:             db,
-		activePayments: make(map[uuid.UUID]bool),
-		stopChan:       make(chan struct{}),
+		db:       db,
+		stopChan: make(chan struct{}),
+		workers:  DefaultMonitorWorkers,
+		metrics:  make(map[string]*CurrencyMetrics),
 	}
 }
 
@@ -35,88 +88,434 @@ func (m *Monitor) SetCallbackSender(sender CallbackSender) {
 	m.callbackSender = sender
 }
 
+// SetTransactionSources sets the on-chain transaction sources by currency
+func (m *Monitor) SetTransactionSources(sources map[string]TransactionSource) {
+	m.sources = sources
+}
+
+// SetWorkers sets the number of payments checked concurrently. It must be
+// called before Start.
+func (m *Monitor) SetWorkers(workers int) {
+	if workers > 0 {
+		m.workers = workers
+	}
+}
+
+// Start runs the workers and a poller per supported currency until Stop is
+// called
 func (m *Monitor) Start() {
-	ticker := time.NewTicker(10 * time.Second)
+	m.metricsMu.Lock()
+	m.jobs = make(chan monitorJob, m.workers*2)
+	m.startedAt = time.Now()
+	m.metricsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.work()
+		}()
+	}
+
+	for currency, config := range database.SupportedCurrencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.poll(currency, config.PollInterval)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (m *Monitor) Stop() {
+	close(m.stopChan)
+}
+
+func (m *Monitor) work() {
+	for {
+		select {
+		case job := <-m.jobs:
+			err := m.checkPayment(job.payment)
+			if err != nil {
+				fmt.Printf("Failed to check payment %s: %v\n", job.payment.PaymentId, err)
+			}
+			job.cycle.done(err)
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// poll checks the open payments of a currency on start and then every
+// interval. A cycle that overruns the interval delays the next one.
+func (m *Monitor) poll(currency string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
+		m.runCycle(currency)
+
 		select {
 		case <-ticker.C:
-			m.checkAllActivePayments()
 		case <-m.stopChan:
 			return
 		}
 	}
 }
 
-func (m *Monitor) Stop() {
-	close(m.stopChan)
+// runCycle reads the open payments of a currency in batches, queues them for
+// the workers and waits for their checks to finish. Final payments are
+// filtered out by the query, so they cost nothing.
+func (m *Monitor) runCycle(currency string) {
+	start := time.Now()
+	cycle := &pollCycle{}
+	backlog := 0
+
+	var afterId uint
+	for {
+		payments, err := m.db.GetOpenPayments(currency, afterId, monitorBatchSize)
+		if err != nil {
+			fmt.Printf("Failed to load open %s payments: %v\n", currency, err)
+			cycle.fail()
+			break
+		}
+
+		for i := range payments {
+			cycle.wg.Add(1)
+			select {
+			case m.jobs <- monitorJob{payment: &payments[i], cycle: cycle}:
+			case <-m.stopChan:
+				return
+			}
+		}
+		backlog += len(payments)
+
+		if len(payments) < monitorBatchSize {
+			break
+		}
+		afterId = payments[len(payments)-1].ID
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		cycle.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-m.stopChan:
+		return
+	}
+
+	m.recordCycle(currency, start, backlog, cycle.errors)
+}
+
+// checkPayment advances an open payment: it expires payments whose window
+// has passed and confirms those whose transactions have enough
+// confirmations
+func (m *Monitor) checkPayment(payment *database.CryptoPayment) error {
+	// Check if expired before any funds arrived
+	if payment.Status == database.Pending && time.Now().After(payment.ExpiryTime) {
+		payment.Status = database.Expired
+		if err := m.db.UpdatePayment(payment); err != nil {
+			return err
+		}
+		m.sendCallback(payment)
+		return nil
+	}
+
+	// Check if the top-up window of a partially paid payment has passed
+	if ExpirePartialPayment(payment, time.Now()) {
+		if err := m.db.UpdatePayment(payment); err != nil {
+			return err
+		}
+		m.sendCallback(payment)
+		return nil
+	}
+
+	if payment.Status != database.Confirming {
+		return nil
+	}
+
+	// Refresh confirmations from the chain, or increment them in simulation
+	ready := true
+	if source, ok := m.sources[payment.Currency]; ok && payment.TxHash != "" {
+		var err error
+		ready, err = m.refreshConfirmations(source, payment)
+		if err != nil {
+			return fmt.Errorf("checking confirmations: %w", err)
+		}
+	} else {
+		payment.Confirmations++
+	}
+
+	confirmed := ready && payment.Confirmations >= payment.RequiredConfirmations
+	if confirmed {
+		payment.Status = database.Confirmed
+		now := time.Now()
+		payment.ConfirmedAt = &now
+	}
+
+	if err := m.db.UpdatePayment(payment); err != nil {
+		return err
+	}
+	if confirmed {
+		m.sendCallback(payment)
+	}
+	return nil
 }
 
-func (m *Monitor) MonitorPayment(paymentId uuid.UUID) {
-	m.mu.Lock()
-	m.activePayments[paymentId] = true
-	m.mu.Unlock()
+// refreshConfirmations updates a payment's confirmations from its latest
+// incoming transaction, which has the fewest confirmations. Unconfirmed
+// transactions are checked for double spends and reversed if they were
+// double spent or dropped from the mempool.
+//
+// It reports whether the payment may be confirmed on this check: not if its
+// transaction was reversed, nor while an unconfirmed transaction is still
+// watched for double spends.
+func (m *Monitor) refreshConfirmations(source TransactionSource, payment *database.CryptoPayment) (bool, error) {
+	incoming, err := source.GetIncomingTransaction(payment.TxHash, payment.DestinationAddress)
+	if errors.Is(err, ErrTransactionNotFound) && payment.Confirmations == 0 {
+		// Dropped from the mempool, usually because a conflicting
+		// transaction replaced it
+		return false, m.reverseTransaction(payment)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if incoming.Confirmations == 0 {
+		if checker, ok := source.(DoubleSpendChecker); ok {
+			spent, err := checker.IsDoubleSpent(payment.TxHash)
+			if err != nil {
+				return false, err
+			}
+			if spent {
+				return false, m.reverseTransaction(payment)
+			}
+		}
+	}
+
+	payment.Confirmations = incoming.Confirmations
+	payment.BlockHeight = incoming.BlockHeight
+
+	// A transaction signalling replace-by-fee can be swapped for one paying
+	// elsewhere until it is mined, so it is never accepted unconfirmed
+	if payment.RequiredConfirmations == 0 && incoming.Replaceable {
+		fmt.Printf("Payment %s transaction %s is replaceable, waiting for a confirmation\n", payment.PaymentId, payment.TxHash)
+		payment.RequiredConfirmations = 1
+	}
+
+	status := transactionStatus(incoming.Confirmations, payment.RequiredConfirmations)
+	if err := m.db.UpdateTransactionConfirmations(payment.TxHash, incoming.Confirmations, incoming.BlockHeight, status); err != nil {
+		return false, err
+	}
+
+	if payment.Confirmations > 0 {
+		return true, nil
+	}
+	return m.watchedLongEnough(payment)
 }
 
-func (m *Monitor) checkAllActivePayments() {
-	m.mu.RLock()
-	paymentIds := make([]uuid.UUID, 0, len(m.activePayments))
-	for id := range m.activePayments {
-		paymentIds = append(paymentIds, id)
+// watchedLongEnough reports whether a payment's unconfirmed transaction has
+// been watched for the currency's zero-confirmation delay
+func (m *Monitor) watchedLongEnough(payment *database.CryptoPayment) (bool, error) {
+	config, exists := database.SupportedCurrencies[payment.Currency]
+	if !exists {
+		return false, fmt.Errorf("unsupported currency: %s", payment.Currency)
 	}
-	m.mu.RUnlock()
 
-	for _, paymentId := range paymentIds {
-		m.checkPayment(paymentId)
+	transactions, err := m.db.GetPaymentTransactions(payment.PaymentId)
+	if err != nil {
+		return false, err
 	}
+
+	for _, transaction := range transactions {
+		if transaction.TxHash == payment.TxHash {
+			return !time.Now().Before(transaction.DetectedAt.Add(config.ZeroConfDelay)), nil
+		}
+	}
+	return false, nil
 }
 
-func (m *Monitor) checkPayment(paymentId uuid.UUID) {
-	payment, err := m.db.GetPaymentByPaymentId(paymentId)
+// reverseTransaction takes a double spent transaction off a payment's
+// received total and re-evaluates the payment, which usually falls back to
+// Pending or PartiallyPaid. The payment is updated in place.
+func (m *Monitor) reverseTransaction(payment *database.CryptoPayment) error {
+	config, exists := database.SupportedCurrencies[payment.Currency]
+	if !exists {
+		return fmt.Errorf("unsupported currency: %s", payment.Currency)
+	}
+
+	if _, err := m.db.ReverseIncomingTransaction(payment.TxHash); err != nil {
+		return err
+	}
+	fmt.Printf("Payment %s transaction %s was double spent\n", payment.PaymentId, payment.TxHash)
+
+	reloaded, err := m.db.GetPaymentByPaymentId(payment.PaymentId)
 	if err != nil {
-		return
+		return err
 	}
 
-	// Skip if already confirmed or failed
-	if payment.Status == database.Confirmed ||
-		payment.Status == database.PaymentFailed ||
-		payment.Status == database.Expired {
-		m.mu.Lock()
-		delete(m.activePayments, paymentId)
-		m.mu.Unlock()
-		return
+	transactions, err := m.db.GetPaymentTransactions(payment.PaymentId)
+	if err != nil {
+		return err
 	}
 
-	// Check if expired
-	if time.Now().After(payment.ExpiryTime) {
-		payment.Status = database.Expired
-		m.db.UpdatePayment(payment)
+	// Fall back to the latest transaction that still stands
+	reloaded.TxHash = ""
+	reloaded.Confirmations = 0
+	reloaded.BlockHeight = 0
+	for _, transaction := range transactions {
+		if transaction.Status != "double_spent" {
+			reloaded.TxHash = transaction.TxHash
+			reloaded.Confirmations = transaction.Confirmations
+			reloaded.BlockHeight = transaction.BlockHeight
+		}
+	}
+
+	// Overpayment is worked out again from what is left
+	reloaded.RefundDueAmount = 0
+	changed := ApplyReceivedAmount(reloaded, config, time.Now())
+
+	if err := m.db.UpdatePayment(reloaded); err != nil {
+		return err
+	}
+	*payment = *reloaded
+
+	if changed {
 		m.sendCallback(payment)
+	}
+	return nil
+}
 
-		m.mu.Lock()
-		delete(m.activePayments, paymentId)
-		m.mu.Unlock()
-		return
+// transactionStatus returns the status recorded for an incoming transaction
+func transactionStatus(confirmations, required int) string {
+	switch {
+	case confirmations > 0 && confirmations >= required:
+		return "confirmed"
+	case confirmations > 0:
+		return "confirming"
+	default:
+		return "pending"
 	}
+}
 
-	// For confirming payments, increment confirmations (simulation)
-	if payment.Status == database.Confirming {
-		payment.Confirmations++
+// VerifyTransaction looks up a transaction submitted by the customer on
+// chain and records it against the payment if it pays the payment's
+// destination address. Recording it moves the payment on to Confirming once
+// enough has been received. It returns the updated payment and the
+// transaction as seen on chain.
+func (m *Monitor) VerifyTransaction(paymentId uuid.UUID, txHash string) (*database.CryptoPayment, *IncomingTransaction, error) {
+	payment, err := m.db.GetPaymentByPaymentId(paymentId)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if payment.Confirmations >= payment.RequiredConfirmations {
-			payment.Status = database.Confirmed
-			now := time.Now()
-			payment.ConfirmedAt = &now
-			m.sendCallback(payment)
+	source, ok := m.sources[payment.Currency]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoTransactionSource, payment.Currency)
+	}
 
-			m.mu.Lock()
-			delete(m.activePayments, paymentId)
-			m.mu.Unlock()
-		}
+	incoming, err := source.GetIncomingTransaction(txHash, payment.DestinationAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if incoming.Amount <= 0 {
+		return nil, incoming, ErrWrongDestination
+	}
+
+	payment, err = m.RecordIncomingTransaction(paymentId, *incoming)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return payment, incoming, nil
+}
+
+// IncomingTransaction describes a transaction paying into a payment's
+// destination address
+type IncomingTransaction struct {
+	TxHash        string
+	FromAddress   string
+	Amount        float64
+	Confirmations int
+	BlockHeight   int64
+	Replaceable   bool // Signals replace-by-fee or can otherwise still be replaced while unconfirmed
+}
 
-		m.db.UpdatePayment(payment)
+// RecordIncomingTransaction adds a detected transaction to the payment's
+// received total and re-evaluates the payment. Transactions that were
+// already recorded are ignored. It returns the updated payment.
+func (m *Monitor) RecordIncomingTransaction(paymentId uuid.UUID, incoming IncomingTransaction) (*database.CryptoPayment, error) {
+	payment, err := m.db.GetPaymentByPaymentId(paymentId)
+	if err != nil {
+		return nil, err
 	}
+
+	config, exists := database.SupportedCurrencies[payment.Currency]
+	if !exists {
+		return nil, fmt.Errorf("unsupported currency: %s", payment.Currency)
+	}
+
+	status := "pending"
+	if incoming.Confirmations > 0 {
+		status = "confirming"
+	}
+
+	added, err := m.db.AddIncomingTransaction(&database.BlockchainTransaction{
+		TxHash:        incoming.TxHash,
+		PaymentId:     payment.PaymentId,
+		FromAddress:   incoming.FromAddress,
+		ToAddress:     payment.DestinationAddress,
+		Amount:        incoming.Amount,
+		Currency:      payment.Currency,
+		BlockHeight:   incoming.BlockHeight,
+		Confirmations: incoming.Confirmations,
+		Status:        status,
+		DetectedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return payment, nil
+	}
+
+	// Reload to pick up the new received total
+	payment, err = m.db.GetPaymentByPaymentId(paymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.SourceAddress == "" {
+		payment.SourceAddress = incoming.FromAddress
+	}
+	payment.TxHash = incoming.TxHash
+	payment.Confirmations = incoming.Confirmations
+	payment.BlockHeight = incoming.BlockHeight
+	if payment.RequiredConfirmations == 0 && incoming.Replaceable && incoming.Confirmations == 0 {
+		payment.RequiredConfirmations = 1
+	}
+
+	changed := ApplyReceivedAmount(payment, config, time.Now())
+
+	if err := m.db.UpdatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Payment %s received %.8f %s (total %.8f of %.8f)\n",
+		payment.PaymentId, incoming.Amount, payment.Currency,
+		payment.ReceivedAmount, payment.Amount)
+
+	if changed {
+		m.sendCallback(payment)
+	}
+
+	return payment, nil
 }
 
 func (m *Monitor) sendCallback(payment *database.CryptoPayment) {
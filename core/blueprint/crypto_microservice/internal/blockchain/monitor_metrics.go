@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"crypto_microservice/internal/database"
+	"time"
+)
+
+// CurrencyMetrics describes the monitor's polling of one currency
+type CurrencyMetrics struct {
+	PollIntervalSeconds float64    `json:"pollIntervalSeconds"`
+	Backlog             int        `json:"backlog"` // Open payments found by the last cycle
+	Errors              int        `json:"errors"`  // Failed reads and checks in the last cycle
+	Cycles              int64      `json:"cycles"`
+	TotalChecks         int64      `json:"totalChecks"`
+	TotalErrors         int64      `json:"totalErrors"`
+	LastCycleAt         *time.Time `json:"lastCycleAt,omitempty"`
+	LastCycleSeconds    float64    `json:"lastCycleSeconds"`
+
+	// LagSeconds is the time since the last cycle finished, or since the
+	// monitor started if none has. It stays near the poll interval while
+	// the workers keep up and grows when they fall behind.
+	LagSeconds float64 `json:"lagSeconds"`
+}
+
+// MonitorMetrics is a snapshot of the monitor's workers and polling
+type MonitorMetrics struct {
+	Workers    int                        `json:"workers"`
+	QueueDepth int                        `json:"queueDepth"` // Payments queued for a worker
+	Currencies map[string]CurrencyMetrics `json:"currencies"`
+}
+
+// recordCycle records a finished poll cycle of a currency
+func (m *Monitor) recordCycle(currency string, start time.Time, backlog, errors int) {
+	m.metricsMu.Lock()
+	defer m.metricsMu.Unlock()
+
+	metrics, ok := m.metrics[currency]
+	if !ok {
+		metrics = &CurrencyMetrics{}
+		m.metrics[currency] = metrics
+	}
+
+	finished := time.Now()
+	metrics.Backlog = backlog
+	metrics.Errors = errors
+	metrics.Cycles++
+	metrics.TotalChecks += int64(backlog)
+	metrics.TotalErrors += int64(errors)
+	metrics.LastCycleAt = &finished
+	metrics.LastCycleSeconds = finished.Sub(start).Seconds()
+}
+
+// Metrics returns a snapshot of the monitor's backlog and lag by currency
+func (m *Monitor) Metrics() MonitorMetrics {
+	m.metricsMu.Lock()
+	defer m.metricsMu.Unlock()
+
+	now := time.Now()
+	snapshot := MonitorMetrics{
+		Workers:    m.workers,
+		QueueDepth: len(m.jobs),
+		Currencies: make(map[string]CurrencyMetrics),
+	}
+
+	for currency, config := range database.SupportedCurrencies {
+		var metrics CurrencyMetrics
+		if recorded, ok := m.metrics[currency]; ok {
+			metrics = *recorded
+		}
+		metrics.PollIntervalSeconds = config.PollInterval.Seconds()
+
+		since := m.startedAt
+		if metrics.LastCycleAt != nil {
+			since = *metrics.LastCycleAt
+		}
+		if !since.IsZero() {
+			metrics.LagSeconds = now.Sub(since).Seconds()
+		}
+
+		snapshot.Currencies[currency] = metrics
+	}
+
+	return snapshot
+}
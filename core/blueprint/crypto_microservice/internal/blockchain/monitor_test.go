@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"crypto_microservice/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// openPaymentsDB serves GetOpenPayments from memory and records updates
+type openPaymentsDB struct {
+	database.Service
+
+	mu       sync.Mutex
+	payments []database.CryptoPayment
+	pages    []uint
+	updated  map[uuid.UUID]database.PaymentStatus
+}
+
+func (db *openPaymentsDB) GetOpenPayments(currency string, afterId uint, limit int) ([]database.CryptoPayment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pages = append(db.pages, afterId)
+
+	var page []database.CryptoPayment
+	for _, payment := range db.payments {
+		if payment.Currency == currency && payment.ID > afterId && len(page) < limit {
+			page = append(page, payment)
+		}
+	}
+	return page, nil
+}
+
+func (db *openPaymentsDB) UpdatePayment(payment *database.CryptoPayment) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.updated[payment.PaymentId] = payment.Status
+	return nil
+}
+
+func TestMonitorRunCycle(t *testing.T) {
+	db := &openPaymentsDB{updated: make(map[uuid.UUID]database.PaymentStatus)}
+	for i := 1; i <= monitorBatchSize+20; i++ {
+		db.payments = append(db.payments, database.CryptoPayment{
+			ID:         uint(i),
+			PaymentId:  uuid.New(),
+			Currency:   "BTC",
+			Status:     database.Pending,
+			ExpiryTime: time.Now().Add(time.Hour),
+		})
+	}
+	expired := &db.payments[3]
+	expired.ExpiryTime = time.Now().Add(-time.Minute)
+	confirming := &db.payments[monitorBatchSize+5]
+	confirming.Status = database.Confirming
+	confirming.RequiredConfirmations = 1
+
+	monitor := NewMonitor(db)
+	monitor.SetWorkers(4)
+	monitor.jobs = make(chan monitorJob, 8)
+	for i := 0; i < monitor.workers; i++ {
+		go monitor.work()
+	}
+	defer monitor.Stop()
+
+	monitor.runCycle("BTC")
+
+	if len(db.pages) != 2 || db.pages[0] != 0 || db.pages[1] != monitorBatchSize {
+		t.Errorf("pages read after ids %v", db.pages)
+	}
+	if len(db.updated) != 2 {
+		t.Errorf("updated %d payments, want 2", len(db.updated))
+	}
+	if db.updated[expired.PaymentId] != database.Expired {
+		t.Errorf("expired payment status = %v", db.updated[expired.PaymentId])
+	}
+	if db.updated[confirming.PaymentId] != database.Confirmed {
+		t.Errorf("confirming payment status = %v", db.updated[confirming.PaymentId])
+	}
+
+	metrics := monitor.Metrics().Currencies["BTC"]
+	if metrics.Backlog != monitorBatchSize+20 || metrics.Errors != 0 || metrics.Cycles != 1 || metrics.LastCycleAt == nil {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+	if metrics.PollIntervalSeconds != 30 {
+		t.Errorf("poll interval = %v", metrics.PollIntervalSeconds)
+	}
+}
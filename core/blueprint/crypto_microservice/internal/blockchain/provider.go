@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"crypto_microservice/internal/database"
+)
+
+// ChainProvider sends outgoing transactions to a blockchain network and
+// reports their progress
+type ChainProvider interface {
+	// EstimateFee returns the network fee for one outgoing transaction, in
+	// units of the provider's currency
+	EstimateFee() (float64, error)
+
+	// BroadcastTransaction submits a signed transaction and returns its hash
+	BroadcastTransaction(tx *Transaction) (string, error)
+
+	// GetConfirmations returns the current confirmation count of a
+	// broadcast transaction
+	GetConfirmations(txHash string) (int, error)
+}
+
+// TransactionSource looks up incoming transactions on a blockchain network
+type TransactionSource interface {
+	// GetIncomingTransaction returns what a transaction pays to the address,
+	// with its current confirmation count. The amount is zero if the
+	// transaction pays nothing to the address.
+	GetIncomingTransaction(txHash, address string) (*IncomingTransaction, error)
+}
+
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// NewTestnetSources creates a transaction source for every supported
+// currency with a testnet endpoint configured. Tokens are read from their
+// contract's Transfer events.
+func NewTestnetSources() (map[string]TransactionSource, error) {
+	sources := make(map[string]TransactionSource)
+	for currency, config := range database.SupportedCurrencies {
+		if config.TestnetRPC == "" {
+			continue
+		}
+
+		if config.TokenContract != "" {
+			provider, err := NewERC20Provider(config.TestnetRPC, config.TokenContract, config.TokenDecimals)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", currency, err)
+			}
+			sources[currency] = provider
+			continue
+		}
+
+		if currency == "BTC" || currency == "ETH" {
+			sources[currency] = NewTestnetProvider(currency, config.TestnetRPC)
+		}
+	}
+	return sources, nil
+}
+
+// SimulatedProvider is a ChainProvider backed by the in-memory Simulator.
+// Every confirmation query mines one more block on top of the transaction,
+// matching how the monitor simulates incoming payments.
+type SimulatedProvider struct {
+	currency  string
+	fee       float64
+	simulator *Simulator
+	mu        sync.Mutex
+}
+
+// NewSimulatedProvider creates a simulated provider charging a fixed fee
+func NewSimulatedProvider(currency string, fee float64) *SimulatedProvider {
+	return &SimulatedProvider{
+		currency:  currency,
+		fee:       fee,
+		simulator: NewSimulator(),
+	}
+}
+
+// NewSimulatedProviders creates a simulated provider for every supported
+// currency using its configured network fee
+func NewSimulatedProviders() map[string]ChainProvider {
+	providers := make(map[string]ChainProvider)
+	for currency, config := range database.SupportedCurrencies {
+		providers[currency] = NewSimulatedProvider(currency, config.NetworkFee)
+	}
+	return providers
+}
+
+func (p *SimulatedProvider) EstimateFee() (float64, error) {
+	return p.fee, nil
+}
+
+func (p *SimulatedProvider) BroadcastTransaction(tx *Transaction) (string, error) {
+	if tx.Currency != p.currency {
+		return "", fmt.Errorf("provider for %s cannot broadcast %s transaction", p.currency, tx.Currency)
+	}
+
+	txHash, err := BroadcastTransaction(tx)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.simulator.RecordTransaction(txHash, tx.FromAddress, tx.ToAddress, tx.Amount, tx.Currency)
+
+	return txHash, nil
+}
+
+func (p *SimulatedProvider) GetConfirmations(txHash string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.simulator.AddConfirmation(txHash); err != nil {
+		return 0, err
+	}
+
+	tx, err := p.simulator.GetTransaction(txHash)
+	if err != nil {
+		return 0, err
+	}
+	return tx.Confirmations, nil
+}
+
+func (p *SimulatedProvider) GetIncomingTransaction(txHash, address string) (*IncomingTransaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tx, err := p.simulator.GetTransaction(txHash)
+	if err != nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	incoming := &IncomingTransaction{
+		TxHash:        tx.TxHash,
+		FromAddress:   tx.FromAddress,
+		Confirmations: tx.Confirmations,
+		BlockHeight:   tx.BlockHeight,
+	}
+	if NormalizeAddress(tx.ToAddress, p.currency) == NormalizeAddress(address, p.currency) {
+		incoming.Amount = tx.Amount
+	}
+	return incoming, nil
+}
@@ -0,0 +1,55 @@
+package blockchain
+
+import "testing"
+
+func TestSimulatedProviderBroadcast(t *testing.T) {
+	wallet, err := GenerateWallet("BTC", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewSimulatedProvider("BTC", 0.0001)
+
+	tb := NewTransactionBuilder().
+		SetFromAddress(wallet.Address).
+		SetToAddress("tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx").
+		SetAmount(0.5).
+		SetCurrency("BTC")
+
+	tx, err := tb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.BroadcastTransaction(tx); err == nil {
+		t.Fatal("expected unsigned transaction to be rejected")
+	}
+
+	if err := tb.Sign(wallet); err != nil {
+		t.Fatal(err)
+	}
+
+	txHash, err := provider.BroadcastTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want := 1; want <= 3; want++ {
+		confirmations, err := provider.GetConfirmations(txHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confirmations != want {
+			t.Errorf("confirmations = %d, want %d", confirmations, want)
+		}
+	}
+
+	if _, err := provider.GetConfirmations("0xunknown"); err == nil {
+		t.Error("expected error for unknown transaction")
+	}
+
+	tx.Currency = "ETH"
+	if _, err := provider.BroadcastTransaction(tx); err == nil {
+		t.Error("expected error for a different currency")
+	}
+}
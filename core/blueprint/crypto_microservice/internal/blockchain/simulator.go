@@ -54,6 +54,21 @@ func (s *Simulator) CreateTransaction(fromAddr, toAddr string, amount float64, c
 	return tx
 }
 
+// RecordTransaction adds an externally built transaction to the simulated chain
+func (s *Simulator) RecordTransaction(txHash, fromAddr, toAddr string, amount float64, currency string) *SimulatedTransaction {
+	tx := &SimulatedTransaction{
+		TxHash:      txHash,
+		FromAddress: fromAddr,
+		ToAddress:   toAddr,
+		Amount:      amount,
+		Currency:    currency,
+		Status:      "pending",
+	}
+
+	s.transactions[tx.TxHash] = tx
+	return tx
+}
+
 // GetTransaction retrieves a simulated transaction
 func (s *Simulator) GetTransaction(txHash string) (*SimulatedTransaction, error) {
 	tx, exists := s.transactions[txHash]
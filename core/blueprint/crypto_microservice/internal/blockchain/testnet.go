@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,13 +29,28 @@ func NewTestnetProvider(currency, rpcURL string) *TestnetProvider {
 	}
 }
 
-// BTCTestnetResponse represents a Bitcoin testnet transaction response
+// BTCTestnetResponse represents a Bitcoin testnet transaction response from
+// an Esplora API. Confirmations and BlockHeight are filled in from the
+// status and the chain tip.
 type BTCTestnetResponse struct {
 	TxID          string `json:"txid"`
 	Confirmations int    `json:"confirmations"`
 	BlockHeight   int64  `json:"block_height"`
-	Status        struct {
-		Confirmed bool `json:"confirmed"`
+	Vin           []struct {
+		TxID     string `json:"txid"`
+		Vout     int    `json:"vout"`
+		Sequence uint32 `json:"sequence"`
+		Prevout  *struct {
+			Address string `json:"scriptpubkey_address"`
+		} `json:"prevout"`
+	} `json:"vin"`
+	Vout []struct {
+		Address string `json:"scriptpubkey_address"`
+		Value   int64  `json:"value"` // Satoshis
+	} `json:"vout"`
+	Status struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int64 `json:"block_height"`
 	} `json:"status"`
 }
 
@@ -65,6 +83,9 @@ func (p *TestnetProvider) getBTCTransaction(txHash string) (*BTCTestnetResponse,
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrTransactionNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("testnet API error: %s", string(body))
@@ -75,13 +96,217 @@ func (p *TestnetProvider) getBTCTransaction(txHash string) (*BTCTestnetResponse,
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if tx.Status.Confirmed {
+		tip, err := p.getBTCTipHeight()
+		if err != nil {
+			return nil, err
+		}
+		tx.BlockHeight = tx.Status.BlockHeight
+		tx.Confirmations = confirmations(uint64(tx.BlockHeight), uint64(tip))
+	}
+
 	return &tx, nil
 }
 
+func (p *TestnetProvider) getBTCTipHeight() (int64, error) {
+	resp, err := p.client.Get(p.rpcURL + "/blocks/tip/height")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query testnet: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("testnet API error: %s", string(body))
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
 func (p *TestnetProvider) getETHTransaction(txHash string) (*ETHTestnetResponse, error) {
-	// For Ethereum, we would use Infura or similar
-	// This is a placeholder implementation
-	return nil, fmt.Errorf("ETH testnet integration not yet implemented")
+	rpc := ethClient{rpcURL: p.rpcURL, client: p.client}
+
+	receipt, err := rpc.transactionReceipt(txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query testnet: %w", err)
+	}
+	if receipt == nil {
+		return &ETHTestnetResponse{Hash: txHash}, nil
+	}
+
+	block, err := parseHexUint(receipt.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := rpc.blockNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ETHTestnetResponse{
+		Hash:             receipt.TransactionHash,
+		BlockNumber:      receipt.BlockNumber,
+		Confirmations:    confirmations(block, latest),
+		TransactionIndex: receipt.TransactionIndex,
+	}, nil
+}
+
+// IsDoubleSpent reports whether an input of an unconfirmed Bitcoin
+// transaction has been spent by a different transaction
+func (p *TestnetProvider) IsDoubleSpent(txHash string) (bool, error) {
+	if p.currency != "BTC" {
+		return false, nil
+	}
+
+	tx, err := p.getBTCTransaction(txHash)
+	if err != nil {
+		return false, err
+	}
+	if tx.Status.Confirmed {
+		return false, nil
+	}
+
+	for _, in := range tx.Vin {
+		url := fmt.Sprintf("%s/tx/%s/outspend/%d", p.rpcURL, in.TxID, in.Vout)
+
+		resp, err := p.client.Get(url)
+		if err != nil {
+			return false, fmt.Errorf("failed to query testnet: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return false, fmt.Errorf("testnet API error: status %d", resp.StatusCode)
+		}
+
+		var outspend struct {
+			Spent bool   `json:"spent"`
+			TxID  string `json:"txid"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&outspend)
+		resp.Body.Close()
+		if err != nil {
+			return false, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if outspend.Spent && outspend.TxID != tx.TxID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ethTransaction is a transaction as returned by eth_getTransactionByHash
+type ethTransaction struct {
+	Hash        string  `json:"hash"`
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Value       string  `json:"value"`
+	BlockNumber *string `json:"blockNumber"`
+}
+
+// GetIncomingTransaction returns what a transaction pays to the address:
+// the sum of the Bitcoin outputs to it, or the value of an Ethereum
+// transaction sent to it. Reverted Ethereum transactions pay nothing.
+func (p *TestnetProvider) GetIncomingTransaction(txHash, address string) (*IncomingTransaction, error) {
+	switch p.currency {
+	case "BTC":
+		return p.getBTCIncoming(txHash, address)
+	case "ETH":
+		return p.getETHIncoming(txHash, address)
+	default:
+		return nil, fmt.Errorf("incoming %s transactions need a token provider", p.currency)
+	}
+}
+
+func (p *TestnetProvider) getBTCIncoming(txHash, address string) (*IncomingTransaction, error) {
+	tx, err := p.getBTCTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	incoming := &IncomingTransaction{
+		TxHash:        tx.TxID,
+		Confirmations: tx.Confirmations,
+		BlockHeight:   tx.BlockHeight,
+	}
+	if len(tx.Vin) > 0 && tx.Vin[0].Prevout != nil {
+		incoming.FromAddress = strings.ToLower(tx.Vin[0].Prevout.Address)
+	}
+
+	// BIP125: any input sequence below 0xfffffffe opts in to replace-by-fee
+	if !tx.Status.Confirmed {
+		for _, in := range tx.Vin {
+			if in.Sequence < 0xfffffffe {
+				incoming.Replaceable = true
+			}
+		}
+	}
+
+	var satoshis int64
+	for _, out := range tx.Vout {
+		if strings.EqualFold(out.Address, address) {
+			satoshis += out.Value
+		}
+	}
+	incoming.Amount = RoundAmount(float64(satoshis) / 1e8)
+
+	return incoming, nil
+}
+
+func (p *TestnetProvider) getETHIncoming(txHash, address string) (*IncomingTransaction, error) {
+	rpc := ethClient{rpcURL: p.rpcURL, client: p.client}
+
+	var tx *ethTransaction
+	if err := rpc.call(&tx, "eth_getTransactionByHash", txHash); err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	// Until it is mined, a transaction can be replaced by another from the
+	// same sender with the same nonce
+	incoming := &IncomingTransaction{
+		TxHash:      tx.Hash,
+		FromAddress: ToChecksumAddress(tx.From),
+		Replaceable: tx.BlockNumber == nil,
+	}
+
+	if tx.BlockNumber != nil {
+		receipt, err := rpc.transactionReceipt(txHash)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil && receipt.Status != "0x1" {
+			return incoming, nil
+		}
+
+		block, err := parseHexUint(*tx.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := rpc.blockNumber()
+		if err != nil {
+			return nil, err
+		}
+		incoming.BlockHeight = int64(block)
+		incoming.Confirmations = confirmations(block, latest)
+	}
+
+	if strings.EqualFold(tx.To, address) {
+		value, ok := new(big.Int).SetString(strings.TrimPrefix(tx.Value, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid transaction value %q", tx.Value)
+		}
+		incoming.Amount = RoundAmount(FromBaseUnits(value, 18))
+	}
+
+	return incoming, nil
 }
 
 // CheckAddressBalance checks the balance of an address on testnet
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTestnetProviderBTCIncoming(t *testing.T) {
+	address := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blocks/tip/height", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2500004")
+	})
+	mux.HandleFunc("/tx/abc", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"txid": "abc",
+			"vin": [{"prevout": {"scriptpubkey_address": "tb1qsender"}}],
+			"vout": [
+				{"scriptpubkey_address": "%s", "value": 150000},
+				{"scriptpubkey_address": "tb1qchange", "value": 900000},
+				{"scriptpubkey_address": "%s", "value": 50000}
+			],
+			"status": {"confirmed": true, "block_height": 2500000}
+		}`, address, address)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewTestnetProvider("BTC", server.URL)
+
+	incoming, err := provider.GetIncomingTransaction("abc", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incoming.Amount != 0.002 || incoming.Confirmations != 5 || incoming.BlockHeight != 2500000 {
+		t.Errorf("unexpected incoming transaction %+v", incoming)
+	}
+	if incoming.FromAddress != "tb1qsender" {
+		t.Errorf("from = %s", incoming.FromAddress)
+	}
+
+	if _, err := provider.GetIncomingTransaction("missing", address); err != ErrTransactionNotFound {
+		t.Errorf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+func TestTestnetProviderBTCDoubleSpend(t *testing.T) {
+	address := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx/rbf", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"txid": "rbf",
+			"vin": [{"txid": "prev", "vout": 0, "sequence": 4294967293}],
+			"vout": [{"scriptpubkey_address": "%s", "value": 1000}],
+			"status": {"confirmed": false}
+		}`, address)
+	})
+	mux.HandleFunc("/tx/prev/outspend/0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"spent": true, "txid": "conflict"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewTestnetProvider("BTC", server.URL)
+
+	incoming, err := provider.GetIncomingTransaction("rbf", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !incoming.Replaceable || incoming.Confirmations != 0 {
+		t.Errorf("expected an unconfirmed replaceable transaction, got %+v", incoming)
+	}
+
+	spent, err := provider.IsDoubleSpent("rbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spent {
+		t.Error("expected the conflicting spend to be detected")
+	}
+}
+
+func TestTestnetProviderETHIncoming(t *testing.T) {
+	block := "0x64"
+	stub := &rpcStub{
+		latest: 102,
+		txs: map[string]*ethTransaction{
+			"0xaaa": {Hash: "0xaaa", From: testSender, To: testRecipient, Value: "0x6f05b59d3b20000", BlockNumber: &block},
+			"0xbbb": {Hash: "0xbbb", From: testSender, To: testRecipient, Value: "0xde0b6b3a7640000"},
+		},
+		receipts: map[string]*EthReceipt{
+			"0xaaa": {TransactionHash: "0xaaa", BlockNumber: block, Status: "0x1"},
+		},
+	}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider := NewTestnetProvider("ETH", server.URL)
+
+	incoming, err := provider.GetIncomingTransaction("0xaaa", ToChecksumAddress(testRecipient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incoming.Amount != 0.5 || incoming.Confirmations != 3 {
+		t.Errorf("unexpected incoming transaction %+v", incoming)
+	}
+
+	// Pending transactions are visible with no confirmations
+	incoming, err = provider.GetIncomingTransaction("0xbbb", testRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incoming.Amount != 1 || incoming.Confirmations != 0 {
+		t.Errorf("unexpected pending transaction %+v", incoming)
+	}
+
+	incoming, err = provider.GetIncomingTransaction("0xaaa", testSender)
+	if err != nil || incoming.Amount != 0 {
+		t.Errorf("expected nothing paid to the sender, got %+v, %v", incoming, err)
+	}
+
+	if _, err := provider.GetIncomingTransaction("0xmissing", testRecipient); err != ErrTransactionNotFound {
+		t.Errorf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
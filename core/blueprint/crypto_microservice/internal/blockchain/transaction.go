@@ -93,12 +93,22 @@ func (tb *TransactionBuilder) generateTxHash() string {
 	return "0x" + hex.EncodeToString(hash[:])
 }
 
-// Sign simulates signing a transaction
-func (tb *TransactionBuilder) Sign(privateKey string) error {
-	// In a real implementation, this would use proper cryptographic signing
-	// For testnet simulation, we just create a mock signature
-	signature := sha256.Sum256([]byte(tb.transaction.TxHash + privateKey))
-	tb.transaction.Signature = hex.EncodeToString(signature[:])
+// Sign signs the built transaction hash with the sender's wallet. Wallets
+// holding merchant keys are only available inside the keystore.
+func (tb *TransactionBuilder) Sign(wallet *Wallet) error {
+	if tb.transaction.TxHash == "" {
+		return fmt.Errorf("transaction must be built before signing")
+	}
+	if wallet.Address != tb.transaction.FromAddress {
+		return fmt.Errorf("wallet %s does not own from address %s", wallet.Address, tb.transaction.FromAddress)
+	}
+
+	signature, err := wallet.SignMessage([]byte(tb.transaction.TxHash))
+	if err != nil {
+		return err
+	}
+
+	tb.transaction.Signature = signature
 	return nil
 }
 
@@ -0,0 +1,81 @@
+package blockchain
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"crypto_microservice/internal/database"
+)
+
+// EIP-155 chain IDs of the Ethereum networks
+const (
+	EthereumMainnetChainID = 1
+	SepoliaChainID         = 11155111
+)
+
+// ethChainID returns the chain ID for the network
+func ethChainID(testnet bool) int {
+	if testnet {
+		return SepoliaChainID
+	}
+	return EthereumMainnetChainID
+}
+
+// PaymentURI returns a payment request URI wallets understand: a BIP21
+// bitcoin: URI for BTC, an EIP-681 ethereum: URI for ETH and the EIP-681
+// ERC-20 transfer form for tokens such as USDT
+func PaymentURI(currency, address string, amount float64, label string, testnet bool) (string, error) {
+	config, exists := database.SupportedCurrencies[currency]
+	if !exists {
+		return "", fmt.Errorf("unsupported currency: %s", currency)
+	}
+	if err := ValidateAddress(address, currency, testnet); err != nil {
+		return "", err
+	}
+
+	switch {
+	case currency == "BTC":
+		return BIP21URI(address, amount, label), nil
+	case config.TokenContract != "":
+		return ERC20TransferURI(config.TokenContract, address, amount, config.TokenDecimals, testnet)
+	case currency == "ETH":
+		return EIP681URI(address, amount, testnet), nil
+	default:
+		return "", fmt.Errorf("no token contract configured for %s", currency)
+	}
+}
+
+// BIP21URI returns a bitcoin: URI requesting amount BTC to the address
+func BIP21URI(address string, amount float64, label string) string {
+	params := []string{"amount=" + strconv.FormatFloat(RoundAmount(amount), 'f', -1, 64)}
+	if label != "" {
+		params = append(params, "label="+uriEscape(label))
+	}
+	return "bitcoin:" + address + "?" + strings.Join(params, "&")
+}
+
+// EIP681URI returns an ethereum: URI requesting amount ETH, in wei, to the
+// address on the network's chain
+func EIP681URI(address string, amount float64, testnet bool) string {
+	return fmt.Sprintf("ethereum:%s@%d?value=%s",
+		ToChecksumAddress(address), ethChainID(testnet), ToBaseUnits(amount, 18).String())
+}
+
+// ERC20TransferURI returns an EIP-681 URI calling transfer(address,uint256)
+// on the token contract to pay amount tokens to the recipient
+func ERC20TransferURI(contract, recipient string, amount float64, decimals int, testnet bool) (string, error) {
+	if err := ValidateAddress(contract, "ETH", testnet); err != nil {
+		return "", fmt.Errorf("invalid token contract: %w", err)
+	}
+	return fmt.Sprintf("ethereum:%s@%d/transfer?address=%s&uint256=%s",
+		ToChecksumAddress(contract), ethChainID(testnet), ToChecksumAddress(recipient),
+		ToBaseUnits(amount, decimals).String()), nil
+}
+
+// uriEscape percent-encodes a URI parameter value. Spaces become %20 since
+// BIP21 does not treat + as a space.
+func uriEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
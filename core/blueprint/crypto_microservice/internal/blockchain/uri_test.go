@@ -0,0 +1,49 @@
+package blockchain
+
+import (
+	"testing"
+
+	"crypto_microservice/internal/database"
+)
+
+func TestBIP21URI(t *testing.T) {
+	got := BIP21URI("tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", 0.00150000, "Payment a1b2&c3")
+	want := "bitcoin:tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx?amount=0.0015&label=Payment%20a1b2%26c3"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestEIP681URI(t *testing.T) {
+	got := EIP681URI("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 0.25, true)
+	want := "ethereum:0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed@11155111?value=250000000000000000"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestERC20TransferURI(t *testing.T) {
+	got, err := ERC20TransferURI("0xaa8e23fb1079ea71e0a56f48a2aa51851d8433d0",
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 12.5, 6, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ethereum:0xaA8E23Fb1079EA71e0a56F48a2aA51851D8433D0@1/transfer" +
+		"?address=0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed&uint256=12500000"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPaymentURIRequiresTokenContract(t *testing.T) {
+	config := database.SupportedCurrencies["USDT"]
+	defer func() { database.SupportedCurrencies["USDT"] = config }()
+
+	unconfigured := config
+	unconfigured.TokenContract = ""
+	database.SupportedCurrencies["USDT"] = unconfigured
+
+	if _, err := PaymentURI("USDT", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 10, "", true); err == nil {
+		t.Error("expected an error without a token contract")
+	}
+}
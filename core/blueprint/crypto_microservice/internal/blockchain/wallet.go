@@ -1,71 +1,76 @@
 package blockchain
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 )
 
-// Wallet represents a cryptocurrency wallet
+// Wallet represents a cryptocurrency wallet. Keys use the secp256k1
+// implementation of dcrd, whose scalar and field arithmetic is constant time.
 type Wallet struct {
-	PrivateKey *ecdsa.PrivateKey
-	PublicKey  []byte
+	PrivateKey *secp256k1.PrivateKey
+	PublicKey  []byte // SEC1 encoded: compressed for BTC, uncompressed for ETH/USDT
 	Address    string
 	Currency   string
+	Testnet    bool
 }
 
-// GenerateWallet generates a new cryptocurrency wallet
-func GenerateWallet(currency string) (*Wallet, error) {
-	// Generate ECDSA private key
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+// GenerateWallet generates a new secp256k1 wallet for the currency and network
+func GenerateWallet(currency string, testnet bool) (*Wallet, error) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate private key: %w", err)
 	}
 
-	// Get public key
-	publicKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
-
-	// Generate address based on currency
-	address := generateAddress(publicKey, currency)
-
-	return &Wallet{
-		PrivateKey: privateKey,
-		PublicKey:  publicKey,
-		Address:    address,
-		Currency:   currency,
-	}, nil
+	return newWallet(privateKey, currency, testnet)
 }
 
-// generateAddress generates a blockchain address from public key
-func generateAddress(publicKey []byte, currency string) string {
-	hash := sha256.Sum256(publicKey)
+func newWallet(privateKey *secp256k1.PrivateKey, currency string, testnet bool) (*Wallet, error) {
+	pubKey := privateKey.PubKey()
+
+	var publicKey []byte
+	var address string
+	var err error
 
 	switch currency {
 	case "BTC":
-		// Bitcoin testnet addresses start with 'tb1' or 'n'/'m'
-		return "tb1q" + hex.EncodeToString(hash[:20])
+		publicKey = pubKey.SerializeCompressed()
+		address, err = P2WPKHAddress(publicKey, testnet)
 	case "ETH", "USDT":
-		// Ethereum addresses are 0x + 40 hex characters
-		return "0x" + hex.EncodeToString(hash[:20])
+		publicKey = pubKey.SerializeUncompressed()
+		address, err = EthereumAddress(publicKey)
 	default:
-		return hex.EncodeToString(hash[:])
+		return nil, fmt.Errorf("unsupported currency: %s", currency)
 	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive address: %w", err)
+	}
+
+	return &Wallet{
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+		Address:    address,
+		Currency:   currency,
+		Testnet:    testnet,
+	}, nil
 }
 
-// SignMessage signs a message with the wallet's private key
+// SignMessage signs a message with the wallet's private key. The nonce is
+// derived deterministically (RFC 6979) and the signature is r || s in hex.
 func (w *Wallet) SignMessage(message []byte) (string, error) {
 	hash := sha256.Sum256(message)
 
-	r, s, err := ecdsa.Sign(rand.Reader, w.PrivateKey, hash[:])
-	if err != nil {
-		return "", fmt.Errorf("failed to sign message: %w", err)
-	}
+	sig := ecdsa.Sign(w.PrivateKey, hash[:])
+	r, s := sig.R(), sig.S()
 
-	signature := append(r.Bytes(), s.Bytes()...)
+	signature := make([]byte, 64)
+	r.PutBytesUnchecked(signature[:32])
+	s.PutBytesUnchecked(signature[32:])
 	return hex.EncodeToString(signature), nil
 }
 
@@ -80,27 +85,23 @@ func VerifySignature(publicKey []byte, message []byte, signature string) (bool,
 		return false, fmt.Errorf("invalid signature length")
 	}
 
-	r := new(big.Int).SetBytes(sigBytes[:32])
-	s := new(big.Int).SetBytes(sigBytes[32:])
-
-	hash := sha256.Sum256(message)
-
-	// Reconstruct public key
-	x := new(big.Int).SetBytes(publicKey[:32])
-	y := new(big.Int).SetBytes(publicKey[32:])
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(sigBytes[:32]) || s.SetByteSlice(sigBytes[32:]) || r.IsZero() || s.IsZero() {
+		return false, nil
+	}
 
-	pubKey := &ecdsa.PublicKey{
-		Curve: elliptic.P256(),
-		X:     x,
-		Y:     y,
+	pubKey, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key: %w", err)
 	}
 
-	return ecdsa.Verify(pubKey, hash[:], r, s), nil
+	hash := sha256.Sum256(message)
+	return ecdsa.NewSignature(&r, &s).Verify(hash[:], pubKey), nil
 }
 
-// GetPrivateKeyHex returns the private key as a hex string
+// GetPrivateKeyHex returns the private key as a 32-byte hex string
 func (w *Wallet) GetPrivateKeyHex() string {
-	return hex.EncodeToString(w.PrivateKey.D.Bytes())
+	return hex.EncodeToString(w.PrivateKey.Serialize())
 }
 
 // GetPublicKeyHex returns the public key as a hex string
@@ -109,38 +110,21 @@ func (w *Wallet) GetPublicKeyHex() string {
 }
 
 // WalletFromPrivateKey reconstructs a wallet from a private key hex string
-func WalletFromPrivateKey(privateKeyHex string, currency string) (*Wallet, error) {
+func WalletFromPrivateKey(privateKeyHex string, currency string, testnet bool) (*Wallet, error) {
 	keyBytes, err := hex.DecodeString(privateKeyHex)
 	if err != nil {
 		return nil, fmt.Errorf("invalid private key format: %w", err)
 	}
 
-	privateKey := new(ecdsa.PrivateKey)
-	privateKey.D = new(big.Int).SetBytes(keyBytes)
-	privateKey.PublicKey.Curve = elliptic.P256()
-	privateKey.PublicKey.X, privateKey.PublicKey.Y = privateKey.PublicKey.Curve.ScalarBaseMult(keyBytes)
-
-	publicKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
-	address := generateAddress(publicKey, currency)
-
-	return &Wallet{
-		PrivateKey: privateKey,
-		PublicKey:  publicKey,
-		Address:    address,
-		Currency:   currency,
-	}, nil
+	return WalletFromPrivateKeyBytes(keyBytes, currency, testnet)
 }
 
-// ValidateAddress validates a cryptocurrency address format
-func ValidateAddress(address string, currency string) bool {
-	switch currency {
-	case "BTC":
-		// Bitcoin testnet addresses
-		return len(address) >= 26 && (address[:2] == "tb" || address[0] == 'n' || address[0] == 'm')
-	case "ETH", "USDT":
-		// Ethereum addresses
-		return len(address) == 42 && address[:2] == "0x"
-	default:
-		return len(address) > 0
+// WalletFromPrivateKeyBytes reconstructs a wallet from a raw 32-byte private key
+func WalletFromPrivateKeyBytes(keyBytes []byte, currency string, testnet bool) (*Wallet, error) {
+	var d secp256k1.ModNScalar
+	if len(keyBytes) > 32 || d.SetByteSlice(keyBytes) || d.IsZero() {
+		return nil, fmt.Errorf("private key out of range")
 	}
+
+	return newWallet(secp256k1.NewPrivateKey(&d), currency, testnet)
 }
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -27,13 +28,70 @@ type Service interface {
 	// Payment operations
 	CreatePayment(payment *CryptoPayment) error
 	GetPaymentByPaymentId(paymentId uuid.UUID) (*CryptoPayment, error)
+	GetPaymentByTransactionId(transactionId uuid.UUID) (*CryptoPayment, error)
+	GetOpenPayments(currency string, afterId uint, limit int) ([]CryptoPayment, error)
 	UpdatePayment(payment *CryptoPayment) error
+	AddIncomingTransaction(transaction *BlockchainTransaction) (bool, error)
+	GetPaymentTransactions(paymentId uuid.UUID) ([]BlockchainTransaction, error)
+	UpdateTransactionConfirmations(txHash string, confirmations int, blockHeight int64, status string) error
+	ReverseIncomingTransaction(txHash string) (bool, error)
+
+	// Confirmation policy operations
+	GetConfirmationPolicy(merchantId uint, currency string) (*ConfirmationPolicy, error)
+	SetConfirmationPolicy(policy *ConfirmationPolicy) error
+	DeleteConfirmationPolicy(merchantId uint, currency string) error
 
 	// Wallet operations
-	GetOrCreateMerchantWallet(merchantId uint, currency string) (*MerchantWallet, error)
+	CreateMerchantWallet(wallet *MerchantWallet) error
 	GetWallet(merchantId uint, currency string) (*MerchantWallet, error)
+	GetWalletsForRewrap(activeKeyVersion int, afterId uint, limit int) ([]MerchantWallet, error)
+	UpdateWalletKeyWrap(walletId uint, wrappedKey string, keyVersion int, previousKeyVersion int) error
+
+	// Deposit address operations
+	CreateDepositAddress(deposit *DepositAddress) error
+	GetDepositAddress(paymentId uuid.UUID) (*DepositAddress, error)
+	GetDepositAddressesForRewrap(activeKeyVersion int, afterId uint, limit int) ([]DepositAddress, error)
+	UpdateDepositKeyWrap(depositId uint, wrappedKey string, keyVersion int, previousKeyVersion int) error
+
+	// Key audit operations
+	CreateKeyAuditEntry(entry *KeyAuditEntry) error
+	GetKeyAuditEntries(walletId uint, limit int) ([]KeyAuditEntry, error)
+
+	// Settlement operations
+	GetUnsettledPayments(limit int) ([]CryptoPayment, error)
+	SettlePayment(paymentId uuid.UUID, credit float64) (bool, error)
+
+	// Merchant credentials
+	SetMerchantApiKey(merchantId uint, keyHash string) error
+	GetMerchantIdByApiKey(keyHash string) (uint, error)
+
+	// Payout operations
+	CreatePayoutAddress(address *PayoutAddress) error
+	GetPayoutAddress(id uint) (*PayoutAddress, error)
+	GetPayoutAddresses(merchantId uint, currency string) ([]PayoutAddress, error)
+	DeletePayoutAddress(id uint, merchantId uint) error
+	CreateWithdrawal(payout *Payout) error
+	CreatePayout(payout *Payout) error
+	UpdatePayout(payout *Payout) error
+	FailPayout(payout *Payout, reason string) error
+	GetPayout(payoutId uuid.UUID) (*Payout, error)
+	GetPayouts(merchantId uint, limit int) ([]Payout, error)
+	GetPayoutsByStatus(status PayoutStatus, limit int) ([]Payout, error)
+
+	// Refund operations
+	CreateRefund(refund *CryptoRefund) error
+	GetRefund(refundId uuid.UUID) (*CryptoRefund, error)
+	GetRefundByPayoutId(payoutId uuid.UUID) (*CryptoRefund, error)
+	StartRefund(refund *CryptoRefund, payout *Payout, refundDueCovered float64) error
+	CompleteRefund(payoutId uuid.UUID) (*CryptoRefund, error)
 }
 
+var (
+	ErrInsufficientBalance  = errors.New("insufficient wallet balance")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount received")
+	ErrRefundNotAwaiting    = errors.New("refund is not awaiting confirmation")
+)
+
 type service struct {
 	db *sql.DB
 }
@@ -130,8 +188,9 @@ func (s *service) CreatePayment(payment *CryptoPayment) error {
 		INSERT INTO crypto_payments (
 			payment_id, transaction_id, merchant_order_id, merchant_id,
 			amount, currency, status, destination_address,
-			required_confirmations, created_at, expiry_time, is_testnet
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			required_confirmations, created_at, expiry_time, is_testnet,
+			fiat_amount, fiat_currency, policy_fiat_amount, policy_fiat_currency
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING id
 	`
 
@@ -141,35 +200,39 @@ func (s *service) CreatePayment(payment *CryptoPayment) error {
 		payment.MerchantId, payment.Amount, payment.Currency, payment.Status,
 		payment.DestinationAddress, payment.RequiredConfirmations,
 		payment.CreatedAt, payment.ExpiryTime, payment.IsTestnet,
+		payment.FiatAmount, payment.FiatCurrency,
+		payment.PolicyFiatAmount, payment.PolicyFiatCurrency,
 	).Scan(&payment.ID)
 
 	return err
 }
 
-// GetPaymentByPaymentId retrieves a payment by its payment ID
-func (s *service) GetPaymentByPaymentId(paymentId uuid.UUID) (*CryptoPayment, error) {
-	query := `
-		SELECT 
-			id, payment_id, transaction_id, merchant_order_id, merchant_id,
-			amount, currency, status, destination_address, source_address,
-			tx_hash, block_height, confirmations, required_confirmations,
-			created_at, expiry_time, confirmed_at, is_testnet
-		FROM crypto_payments
-		WHERE payment_id = $1
-	`
+// paymentColumns is the column list scanned by scanPayment
+const paymentColumns = `
+	id, payment_id, transaction_id, merchant_order_id, merchant_id,
+	amount, currency, status, destination_address, source_address,
+	tx_hash, block_height, confirmations, required_confirmations,
+	created_at, expiry_time, confirmed_at, is_testnet,
+	received_amount, refund_due_amount, top_up_deadline, settled_at,
+	COALESCE(fiat_amount, 0), COALESCE(fiat_currency, ''), refunded_amount,
+	COALESCE(policy_fiat_amount, 0), COALESCE(policy_fiat_currency, '')`
 
+func scanPayment(row rowScanner) (*CryptoPayment, error) {
 	var payment CryptoPayment
-	var confirmedAt sql.NullTime
+	var confirmedAt, topUpDeadline, settledAt sql.NullTime
 	var sourceAddr, txHash sql.NullString
 	var blockHeight sql.NullInt64
 
-	err := s.db.QueryRow(query, paymentId).Scan(
+	err := row.Scan(
 		&payment.ID, &payment.PaymentId, &payment.TransactionId,
 		&payment.MerchantOrderId, &payment.MerchantId, &payment.Amount,
 		&payment.Currency, &payment.Status, &payment.DestinationAddress,
 		&sourceAddr, &txHash, &blockHeight, &payment.Confirmations,
 		&payment.RequiredConfirmations, &payment.CreatedAt,
 		&payment.ExpiryTime, &confirmedAt, &payment.IsTestnet,
+		&payment.ReceivedAmount, &payment.RefundDueAmount, &topUpDeadline,
+		&settledAt, &payment.FiatAmount, &payment.FiatCurrency, &payment.RefundedAmount,
+		&payment.PolicyFiatAmount, &payment.PolicyFiatCurrency,
 	)
 
 	if err != nil {
@@ -188,124 +251,1194 @@ func (s *service) GetPaymentByPaymentId(paymentId uuid.UUID) (*CryptoPayment, er
 	if confirmedAt.Valid {
 		payment.ConfirmedAt = &confirmedAt.Time
 	}
+	if topUpDeadline.Valid {
+		payment.TopUpDeadline = &topUpDeadline.Time
+	}
+	if settledAt.Valid {
+		payment.SettledAt = &settledAt.Time
+	}
 
 	return &payment, nil
 }
 
+// GetPaymentByPaymentId retrieves a payment by its payment ID
+func (s *service) GetPaymentByPaymentId(paymentId uuid.UUID) (*CryptoPayment, error) {
+	query := `SELECT ` + paymentColumns + `
+		FROM crypto_payments
+		WHERE payment_id = $1
+	`
+
+	return scanPayment(s.db.QueryRow(query, paymentId))
+}
+
+// GetPaymentByTransactionId retrieves the payment made for a PSP
+// transaction. If the customer opened several payments for the same
+// transaction, the one that received the most funds is returned.
+func (s *service) GetPaymentByTransactionId(transactionId uuid.UUID) (*CryptoPayment, error) {
+	query := `SELECT ` + paymentColumns + `
+		FROM crypto_payments
+		WHERE transaction_id = $1
+		ORDER BY received_amount DESC, created_at DESC
+		LIMIT 1
+	`
+
+	return scanPayment(s.db.QueryRow(query, transactionId))
+}
+
+// GetOpenPayments returns a page of the payments in a currency that are
+// still awaiting funds or confirmations, in id order after afterId
+func (s *service) GetOpenPayments(currency string, afterId uint, limit int) ([]CryptoPayment, error) {
+	query := `SELECT ` + paymentColumns + `
+		FROM crypto_payments
+		WHERE currency = $1 AND status IN ($2, $3, $4) AND id > $5
+		ORDER BY id
+		LIMIT $6
+	`
+
+	rows, err := s.db.Query(query, currency, Pending, Confirming, PartiallyPaid, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []CryptoPayment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
 // UpdatePayment updates an existing payment
 func (s *service) UpdatePayment(payment *CryptoPayment) error {
 	query := `
 		UPDATE crypto_payments
 		SET status = $1, source_address = $2, tx_hash = $3,
-			block_height = $4, confirmations = $5, confirmed_at = $6
-		WHERE payment_id = $7
+			block_height = $4, confirmations = $5, confirmed_at = $6,
+			refund_due_amount = $7, top_up_deadline = $8,
+			required_confirmations = $9
+		WHERE payment_id = $10
 	`
 
 	_, err := s.db.Exec(
 		query,
 		payment.Status, payment.SourceAddress, payment.TxHash,
 		payment.BlockHeight, payment.Confirmations, payment.ConfirmedAt,
-		payment.PaymentId,
+		payment.RefundDueAmount, payment.TopUpDeadline,
+		payment.RequiredConfirmations, payment.PaymentId,
 	)
 
 	return err
 }
 
-// GetOrCreateMerchantWallet gets or creates a merchant wallet for a specific currency
-func (s *service) GetOrCreateMerchantWallet(merchantId uint, currency string) (*MerchantWallet, error) {
-	// Try to get existing wallet
+// AddIncomingTransaction records a transaction paying into a payment's
+// destination address and adds its amount to the payment's received total.
+// It reports false if the transaction was already recorded.
+func (s *service) AddIncomingTransaction(transaction *BlockchainTransaction) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
+		INSERT INTO blockchain_transactions (
+			tx_hash, payment_id, from_address, to_address, amount, currency,
+			block_height, confirmations, status, detected_at, confirmed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (tx_hash) DO NOTHING
+		RETURNING id
+	`
+
+	err = tx.QueryRow(
+		insertQuery,
+		transaction.TxHash, transaction.PaymentId, transaction.FromAddress,
+		transaction.ToAddress, transaction.Amount, transaction.Currency,
+		transaction.BlockHeight, transaction.Confirmations, transaction.Status,
+		transaction.DetectedAt, transaction.ConfirmedAt,
+	).Scan(&transaction.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	updateQuery := `
+		UPDATE crypto_payments
+		SET received_amount = received_amount + $1
+		WHERE payment_id = $2
+	`
+
+	if _, err := tx.Exec(updateQuery, transaction.Amount, transaction.PaymentId); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// UpdateTransactionConfirmations records the latest on-chain state of an
+// incoming transaction. The confirmation time is set once, when the status
+// first becomes confirmed.
+func (s *service) UpdateTransactionConfirmations(txHash string, confirmations int, blockHeight int64, status string) error {
 	query := `
-		SELECT id, merchant_id, currency, wallet_address, public_key,
-			balance, is_testnet, created_at, updated_at
-		FROM merchant_wallets
-		WHERE merchant_id = $1 AND currency = $2
+		UPDATE blockchain_transactions
+		SET confirmations = $1,
+			block_height = $2,
+			status = $3,
+			confirmed_at = CASE WHEN $4 THEN COALESCE(confirmed_at, NOW()) ELSE confirmed_at END
+		WHERE tx_hash = $5
 	`
 
-	var wallet MerchantWallet
-	err := s.db.QueryRow(query, merchantId, currency).Scan(
-		&wallet.ID, &wallet.MerchantId, &wallet.Currency,
-		&wallet.WalletAddress, &wallet.PublicKey, &wallet.Balance,
-		&wallet.IsTestnet, &wallet.CreatedAt, &wallet.UpdatedAt,
-	)
+	_, err := s.db.Exec(query, confirmations, blockHeight, status, status == "confirmed", txHash)
+	return err
+}
 
-	if err == nil {
-		return &wallet, nil
+// ReverseIncomingTransaction marks an incoming transaction as double spent
+// and takes its amount off the payment's received total. It reports false
+// if the transaction was already reversed.
+func (s *service) ReverseIncomingTransaction(txHash string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
 	}
+	defer tx.Rollback()
+
+	var paymentId uuid.UUID
+	var amount float64
+	err = tx.QueryRow(`
+		UPDATE blockchain_transactions
+		SET status = 'double_spent'
+		WHERE tx_hash = $1 AND status <> 'double_spent'
+		RETURNING payment_id, amount
+	`, txHash).Scan(&paymentId, &amount)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	updateQuery := `
+		UPDATE crypto_payments
+		SET received_amount = received_amount - $1
+		WHERE payment_id = $2
+	`
+
+	if _, err := tx.Exec(updateQuery, amount, paymentId); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetPaymentTransactions returns the incoming transactions of a payment
+func (s *service) GetPaymentTransactions(paymentId uuid.UUID) ([]BlockchainTransaction, error) {
+	query := `
+		SELECT id, tx_hash, payment_id, from_address, to_address, amount, currency,
+			COALESCE(block_height, 0), confirmations, status, detected_at, confirmed_at
+		FROM blockchain_transactions
+		WHERE payment_id = $1
+		ORDER BY detected_at, id
+	`
 
-	if err != sql.ErrNoRows {
+	rows, err := s.db.Query(query, paymentId)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Create new wallet
-	walletAddress, publicKey := generateTestnetWallet(currency, merchantId)
+	var transactions []BlockchainTransaction
+	for rows.Next() {
+		var transaction BlockchainTransaction
+		var confirmedAt sql.NullTime
+		err := rows.Scan(
+			&transaction.ID, &transaction.TxHash, &transaction.PaymentId,
+			&transaction.FromAddress, &transaction.ToAddress, &transaction.Amount,
+			&transaction.Currency, &transaction.BlockHeight, &transaction.Confirmations,
+			&transaction.Status, &transaction.DetectedAt, &confirmedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if confirmedAt.Valid {
+			transaction.ConfirmedAt = &confirmedAt.Time
+		}
+		transactions = append(transactions, transaction)
+	}
 
-	insertQuery := `
+	return transactions, rows.Err()
+}
+
+// CreateMerchantWallet stores a newly generated merchant wallet. If another
+// request already created a wallet for the same merchant and currency, the
+// existing wallet is loaded into the argument instead.
+func (s *service) CreateMerchantWallet(wallet *MerchantWallet) error {
+	query := `
 		INSERT INTO merchant_wallets (
 			merchant_id, currency, wallet_address, public_key,
+			private_key, wrapped_key, key_version,
 			balance, is_testnet, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (merchant_id, currency) DO NOTHING
 		RETURNING id
 	`
 
 	now := time.Now()
-	err = s.db.QueryRow(
-		insertQuery,
-		merchantId, currency, walletAddress, publicKey,
-		0.0, true, now, now,
+	err := s.db.QueryRow(
+		query,
+		wallet.MerchantId, wallet.Currency, wallet.WalletAddress, wallet.PublicKey,
+		wallet.PrivateKey, wallet.WrappedKey, wallet.KeyVersion,
+		wallet.Balance, wallet.IsTestnet, now, now,
 	).Scan(&wallet.ID)
 
+	if err == sql.ErrNoRows {
+		existing, err := s.GetWallet(wallet.MerchantId, wallet.Currency)
+		if err != nil {
+			return err
+		}
+		*wallet = *existing
+		return nil
+	}
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	wallet.MerchantId = merchantId
-	wallet.Currency = currency
-	wallet.WalletAddress = walletAddress
-	wallet.PublicKey = publicKey
-	wallet.Balance = 0.0
-	wallet.IsTestnet = true
 	wallet.CreatedAt = now
 	wallet.UpdatedAt = now
 
-	return &wallet, nil
+	return nil
+}
+
+// walletColumns is the column list scanned by scanWallet
+const walletColumns = `
+	id, merchant_id, currency, wallet_address, public_key,
+	COALESCE(private_key, ''), COALESCE(wrapped_key, ''), COALESCE(key_version, 0),
+	balance, is_testnet, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-// generateTestnetWallet generates a testnet wallet address for the given currency
-func generateTestnetWallet(currency string, merchantId uint) (address string, publicKey string) {
-	// For testnet simulation, generate deterministic addresses
-	switch currency {
-	case "BTC":
-		address = fmt.Sprintf("tb1q%s%d", uuid.New().String()[:20], merchantId)
-		publicKey = fmt.Sprintf("02%s", uuid.New().String()[:62])
-	case "ETH", "USDT":
-		address = fmt.Sprintf("0x%s%d", uuid.New().String()[:38], merchantId)
-		publicKey = fmt.Sprintf("04%s", uuid.New().String()[:126])
-	default:
-		address = fmt.Sprintf("test_%s_%d", currency, merchantId)
-		publicKey = uuid.New().String()
+func scanWallet(row rowScanner) (*MerchantWallet, error) {
+	var wallet MerchantWallet
+	err := row.Scan(
+		&wallet.ID, &wallet.MerchantId, &wallet.Currency,
+		&wallet.WalletAddress, &wallet.PublicKey,
+		&wallet.PrivateKey, &wallet.WrappedKey, &wallet.KeyVersion,
+		&wallet.Balance, &wallet.IsTestnet, &wallet.CreatedAt, &wallet.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return &wallet, nil
 }
 
 // GetWallet retrieves a merchant wallet
 func (s *service) GetWallet(merchantId uint, currency string) (*MerchantWallet, error) {
-	query := `
-		SELECT id, merchant_id, currency, wallet_address, public_key,
-			balance, is_testnet, created_at, updated_at
+	query := `SELECT ` + walletColumns + `
 		FROM merchant_wallets
 		WHERE merchant_id = $1 AND currency = $2
 	`
 
-	var wallet MerchantWallet
-	err := s.db.QueryRow(query, merchantId, currency).Scan(
-		&wallet.ID, &wallet.MerchantId, &wallet.Currency,
-		&wallet.WalletAddress, &wallet.PublicKey, &wallet.Balance,
-		&wallet.IsTestnet, &wallet.CreatedAt, &wallet.UpdatedAt,
+	return scanWallet(s.db.QueryRow(query, merchantId, currency))
+}
+
+// GetWalletsForRewrap returns wallets with a stored private key whose data
+// key is wrapped with a master key other than the active one
+func (s *service) GetWalletsForRewrap(activeKeyVersion int, afterId uint, limit int) ([]MerchantWallet, error) {
+	query := `SELECT ` + walletColumns + `
+		FROM merchant_wallets
+		WHERE private_key IS NOT NULL AND wrapped_key IS NOT NULL
+			AND key_version <> $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := s.db.Query(query, activeKeyVersion, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []MerchantWallet
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *wallet)
+	}
+
+	return wallets, rows.Err()
+}
+
+// UpdateWalletKeyWrap stores a rewrapped data key. The update only applies
+// if the wallet is still on the previous key version.
+func (s *service) UpdateWalletKeyWrap(walletId uint, wrappedKey string, keyVersion int, previousKeyVersion int) error {
+	query := `
+		UPDATE merchant_wallets
+		SET wrapped_key = $1, key_version = $2, updated_at = $3
+		WHERE id = $4 AND key_version = $5
+	`
+
+	result, err := s.db.Exec(query, wrappedKey, keyVersion, time.Now(), walletId, previousKeyVersion)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("wallet %d was modified concurrently", walletId)
+	}
+
+	return nil
+}
+
+// CreateDepositAddress stores a newly generated deposit address
+func (s *service) CreateDepositAddress(deposit *DepositAddress) error {
+	query := `
+		INSERT INTO deposit_addresses (
+			payment_id, wallet_id, merchant_id, currency, address, public_key,
+			private_key, wrapped_key, key_version, is_testnet, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+
+	deposit.CreatedAt = time.Now()
+	return s.db.QueryRow(
+		query,
+		deposit.PaymentId, deposit.WalletId, deposit.MerchantId, deposit.Currency,
+		deposit.Address, deposit.PublicKey, deposit.PrivateKey, deposit.WrappedKey,
+		deposit.KeyVersion, deposit.IsTestnet, deposit.CreatedAt,
+	).Scan(&deposit.ID)
+}
+
+// depositColumns is the column list scanned by scanDeposit
+const depositColumns = `
+	id, payment_id, wallet_id, merchant_id, currency, address, public_key,
+	private_key, wrapped_key, key_version, is_testnet, created_at`
+
+func scanDeposit(row rowScanner) (*DepositAddress, error) {
+	var deposit DepositAddress
+	err := row.Scan(
+		&deposit.ID, &deposit.PaymentId, &deposit.WalletId, &deposit.MerchantId,
+		&deposit.Currency, &deposit.Address, &deposit.PublicKey,
+		&deposit.PrivateKey, &deposit.WrappedKey, &deposit.KeyVersion,
+		&deposit.IsTestnet, &deposit.CreatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+// GetDepositAddress retrieves the deposit address generated for a payment
+func (s *service) GetDepositAddress(paymentId uuid.UUID) (*DepositAddress, error) {
+	query := `SELECT ` + depositColumns + `
+		FROM deposit_addresses
+		WHERE payment_id = $1
+	`
 
+	return scanDeposit(s.db.QueryRow(query, paymentId))
+}
+
+// GetDepositAddressesForRewrap returns deposit addresses whose data key is
+// wrapped with a master key other than the active one
+func (s *service) GetDepositAddressesForRewrap(activeKeyVersion int, afterId uint, limit int) ([]DepositAddress, error) {
+	query := `SELECT ` + depositColumns + `
+		FROM deposit_addresses
+		WHERE key_version <> $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := s.db.Query(query, activeKeyVersion, afterId, limit)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &wallet, nil
+	var deposits []DepositAddress
+	for rows.Next() {
+		deposit, err := scanDeposit(rows)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, *deposit)
+	}
+
+	return deposits, rows.Err()
+}
+
+// UpdateDepositKeyWrap stores a rewrapped data key. The update only applies
+// if the deposit address is still on the previous key version.
+func (s *service) UpdateDepositKeyWrap(depositId uint, wrappedKey string, keyVersion int, previousKeyVersion int) error {
+	query := `
+		UPDATE deposit_addresses
+		SET wrapped_key = $1, key_version = $2
+		WHERE id = $3 AND key_version = $4
+	`
+
+	result, err := s.db.Exec(query, wrappedKey, keyVersion, depositId, previousKeyVersion)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("deposit address %d was modified concurrently", depositId)
+	}
+
+	return nil
+}
+
+// CreateKeyAuditEntry appends an entry to the key audit log
+func (s *service) CreateKeyAuditEntry(entry *KeyAuditEntry) error {
+	query := `
+		INSERT INTO key_audit_log (
+			wallet_id, merchant_id, currency, address, operation, purpose,
+			key_version, success, error, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	return s.db.QueryRow(
+		query,
+		entry.WalletId, entry.MerchantId, entry.Currency, entry.Address, entry.Operation,
+		entry.Purpose, entry.KeyVersion, entry.Success, entry.Error, entry.CreatedAt,
+	).Scan(&entry.ID)
+}
+
+// GetKeyAuditEntries returns the most recent audit entries for a wallet
+func (s *service) GetKeyAuditEntries(walletId uint, limit int) ([]KeyAuditEntry, error) {
+	query := `
+		SELECT id, wallet_id, merchant_id, currency, COALESCE(address, ''), operation,
+			purpose, key_version, success, COALESCE(error, ''), created_at
+		FROM key_audit_log
+		WHERE wallet_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := s.db.Query(query, walletId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []KeyAuditEntry
+	for rows.Next() {
+		var entry KeyAuditEntry
+		err := rows.Scan(
+			&entry.ID, &entry.WalletId, &entry.MerchantId, &entry.Currency, &entry.Address,
+			&entry.Operation, &entry.Purpose, &entry.KeyVersion, &entry.Success,
+			&entry.Error, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetUnsettledPayments returns confirmed payments that have not been credited
+// to the merchant wallet and have no sweep in flight
+func (s *service) GetUnsettledPayments(limit int) ([]CryptoPayment, error) {
+	query := `SELECT ` + paymentColumns + `
+		FROM crypto_payments p
+		WHERE status = $1 AND settled_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM payouts o
+				WHERE o.payment_id = p.payment_id AND o.kind = $2 AND o.status <> $3
+			)
+		ORDER BY confirmed_at, id
+		LIMIT $4
+	`
+
+	rows, err := s.db.Query(query, Confirmed, PayoutSweep, PayoutFailed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []CryptoPayment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// SettlePayment marks a payment as settled and credits the merchant wallet
+// balance in one transaction. It reports false if the payment was already
+// settled, in which case nothing is credited.
+func (s *service) SettlePayment(paymentId uuid.UUID, credit float64) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var merchantId uint
+	var currency string
+	err = tx.QueryRow(`
+		UPDATE crypto_payments
+		SET settled_at = $1
+		WHERE payment_id = $2 AND settled_at IS NULL
+		RETURNING merchant_id, currency
+	`, time.Now(), paymentId).Scan(&merchantId, &currency)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := creditWallet(tx, merchantId, currency, credit); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func creditWallet(tx *sql.Tx, merchantId uint, currency string, amount float64) error {
+	result, err := tx.Exec(`
+		UPDATE merchant_wallets
+		SET balance = balance + $1, updated_at = $2
+		WHERE merchant_id = $3 AND currency = $4
+	`, amount, time.Now(), merchantId, currency)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("no %s wallet for merchant %d", currency, merchantId)
+	}
+
+	return nil
+}
+
+// SetMerchantApiKey stores the hash of a merchant's API key, replacing the
+// merchant's previous key
+func (s *service) SetMerchantApiKey(merchantId uint, keyHash string) error {
+	query := `
+		INSERT INTO merchant_api_keys (merchant_id, key_hash, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (merchant_id) DO UPDATE SET key_hash = EXCLUDED.key_hash, created_at = EXCLUDED.created_at
+	`
+
+	_, err := s.db.Exec(query, merchantId, keyHash, time.Now())
+	return err
+}
+
+// GetMerchantIdByApiKey returns the merchant whose API key has the hash
+func (s *service) GetMerchantIdByApiKey(keyHash string) (uint, error) {
+	var merchantId uint
+	err := s.db.QueryRow(`SELECT merchant_id FROM merchant_api_keys WHERE key_hash = $1`, keyHash).Scan(&merchantId)
+	return merchantId, err
+}
+
+// CreatePayoutAddress whitelists an external payout address
+func (s *service) CreatePayoutAddress(address *PayoutAddress) error {
+	query := `
+		INSERT INTO payout_addresses (merchant_id, currency, address, label, created_at, active_after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	address.CreatedAt = time.Now()
+	if address.ActiveAfter.IsZero() {
+		address.ActiveAfter = address.CreatedAt
+	}
+	return s.db.QueryRow(
+		query,
+		address.MerchantId, address.Currency, address.Address, address.Label, address.CreatedAt, address.ActiveAfter,
+	).Scan(&address.ID)
+}
+
+// GetPayoutAddress retrieves a whitelisted payout address
+func (s *service) GetPayoutAddress(id uint) (*PayoutAddress, error) {
+	query := `
+		SELECT id, merchant_id, currency, address, label, created_at, active_after
+		FROM payout_addresses
+		WHERE id = $1
+	`
+
+	var address PayoutAddress
+	err := s.db.QueryRow(query, id).Scan(
+		&address.ID, &address.MerchantId, &address.Currency,
+		&address.Address, &address.Label, &address.CreatedAt, &address.ActiveAfter,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &address, nil
+}
+
+// GetPayoutAddresses lists a merchant's whitelisted addresses for a currency
+func (s *service) GetPayoutAddresses(merchantId uint, currency string) ([]PayoutAddress, error) {
+	query := `
+		SELECT id, merchant_id, currency, address, label, created_at, active_after
+		FROM payout_addresses
+		WHERE merchant_id = $1 AND currency = $2
+		ORDER BY id
+	`
+
+	rows, err := s.db.Query(query, merchantId, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []PayoutAddress
+	for rows.Next() {
+		var address PayoutAddress
+		err := rows.Scan(
+			&address.ID, &address.MerchantId, &address.Currency,
+			&address.Address, &address.Label, &address.CreatedAt, &address.ActiveAfter,
+		)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, rows.Err()
+}
+
+// DeletePayoutAddress removes an address from a merchant's whitelist
+func (s *service) DeletePayoutAddress(id uint, merchantId uint) error {
+	result, err := s.db.Exec(`DELETE FROM payout_addresses WHERE id = $1 AND merchant_id = $2`, id, merchantId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CreateWithdrawal reserves the payout's Reserved amount from the merchant
+// wallet balance and stores the payout in one transaction. It returns
+// ErrInsufficientBalance if the balance does not cover the reservation.
+func (s *service) CreateWithdrawal(payout *Payout) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := reserveBalance(tx, payout); err != nil {
+		return err
+	}
+
+	if err := insertPayout(tx, payout); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func reserveBalance(tx *sql.Tx, payout *Payout) error {
+	if payout.Reserved <= 0 {
+		return nil
+	}
+
+	result, err := tx.Exec(`
+		UPDATE merchant_wallets
+		SET balance = balance - $1, updated_at = $2
+		WHERE merchant_id = $3 AND currency = $4 AND balance >= $1
+	`, payout.Reserved, time.Now(), payout.MerchantId, payout.Currency)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInsufficientBalance
+	}
+
+	return nil
+}
+
+// CreatePayout stores a payout that does not draw on the wallet balance
+func (s *service) CreatePayout(payout *Payout) error {
+	return insertPayout(s.db, payout)
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func insertPayout(q queryRower, payout *Payout) error {
+	query := `
+		INSERT INTO payouts (
+			payout_id, merchant_id, currency, kind, payment_id, from_address,
+			to_address, amount, fee, reserved, status, required_confirmations, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+
+	return q.QueryRow(
+		query,
+		payout.PayoutId, payout.MerchantId, payout.Currency, payout.Kind,
+		payout.PaymentId, payout.FromAddress, payout.ToAddress, payout.Amount,
+		payout.Fee, payout.Reserved, payout.Status, payout.RequiredConfirmations,
+		payout.CreatedAt,
+	).Scan(&payout.ID)
+}
+
+// UpdatePayout updates the broadcast and confirmation state of a payout
+func (s *service) UpdatePayout(payout *Payout) error {
+	query := `
+		UPDATE payouts
+		SET status = $1, tx_hash = $2, confirmations = $3, error = $4,
+			broadcast_at = $5, confirmed_at = $6
+		WHERE payout_id = $7
+	`
+
+	_, err := s.db.Exec(
+		query,
+		payout.Status, payout.TxHash, payout.Confirmations, payout.Error,
+		payout.BroadcastAt, payout.ConfirmedAt, payout.PayoutId,
+	)
+
+	return err
+}
+
+// FailPayout marks a payout as failed and, at most once, undoes its
+// bookkeeping: the reserved balance goes back to the merchant wallet and a
+// failed refund gives the payment back its refundable amount.
+func (s *service) FailPayout(payout *Payout, reason string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE payouts
+		SET status = $1, error = $2
+		WHERE payout_id = $3 AND status <> $1
+	`, PayoutFailed, reason, payout.PayoutId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 && payout.Reserved > 0 {
+		if err := creditWallet(tx, payout.MerchantId, payout.Currency, payout.Reserved); err != nil {
+			return err
+		}
+	}
+
+	if affected > 0 && payout.Kind == PayoutRefund {
+		_, err := tx.Exec(`
+			UPDATE crypto_payments
+			SET refunded_amount = refunded_amount - $1,
+				refund_due_amount = refund_due_amount + $2
+			WHERE payment_id = $3
+		`, payout.Amount, payout.Amount+payout.Fee-payout.Reserved, payout.PaymentId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE crypto_refunds
+			SET status = $1, error = $2
+			WHERE payout_id = $3
+		`, RefundFailed, reason, payout.PayoutId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	payout.Status = PayoutFailed
+	payout.Error = reason
+	return nil
+}
+
+// payoutColumns is the column list scanned by scanPayout
+const payoutColumns = `
+	id, payout_id, merchant_id, currency, kind, payment_id, from_address,
+	to_address, amount, fee, reserved, status, COALESCE(tx_hash, ''), confirmations,
+	required_confirmations, COALESCE(error, ''), created_at, broadcast_at, confirmed_at`
+
+func scanPayout(row rowScanner) (*Payout, error) {
+	var payout Payout
+	var paymentId uuid.NullUUID
+	var broadcastAt, confirmedAt sql.NullTime
+
+	err := row.Scan(
+		&payout.ID, &payout.PayoutId, &payout.MerchantId, &payout.Currency,
+		&payout.Kind, &paymentId, &payout.FromAddress, &payout.ToAddress,
+		&payout.Amount, &payout.Fee, &payout.Reserved, &payout.Status, &payout.TxHash,
+		&payout.Confirmations, &payout.RequiredConfirmations, &payout.Error,
+		&payout.CreatedAt, &broadcastAt, &confirmedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if paymentId.Valid {
+		payout.PaymentId = &paymentId.UUID
+	}
+	if broadcastAt.Valid {
+		payout.BroadcastAt = &broadcastAt.Time
+	}
+	if confirmedAt.Valid {
+		payout.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &payout, nil
+}
+
+// GetPayout retrieves a payout by its payout ID
+func (s *service) GetPayout(payoutId uuid.UUID) (*Payout, error) {
+	query := `SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE payout_id = $1
+	`
+
+	return scanPayout(s.db.QueryRow(query, payoutId))
+}
+
+// GetPayouts returns a merchant's most recent payouts and sweeps
+func (s *service) GetPayouts(merchantId uint, limit int) ([]Payout, error) {
+	query := `SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE merchant_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	return s.queryPayouts(query, merchantId, limit)
+}
+
+// GetPayoutsByStatus returns the oldest payouts in the given status
+func (s *service) GetPayoutsByStatus(status PayoutStatus, limit int) ([]Payout, error) {
+	query := `SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE status = $1
+		ORDER BY created_at, id
+		LIMIT $2
+	`
+
+	return s.queryPayouts(query, status, limit)
+}
+
+func (s *service) queryPayouts(query string, args ...any) ([]Payout, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payouts []Payout
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, *payout)
+	}
+
+	return payouts, rows.Err()
+}
+
+// CreateRefund stores a refund awaiting the customer's confirmation
+func (s *service) CreateRefund(refund *CryptoRefund) error {
+	query := `
+		INSERT INTO crypto_refunds (
+			refund_id, payment_id, transaction_id, merchant_id, currency,
+			requested_amount, requested_currency, rate_source, rate, amount,
+			suggested_address, confirmation_token, status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`
+
+	return s.db.QueryRow(
+		query,
+		refund.RefundId, refund.PaymentId, refund.TransactionId, refund.MerchantId,
+		refund.Currency, refund.RequestedAmount, refund.RequestedCurrency,
+		refund.RateSource, refund.Rate, refund.Amount, refund.SuggestedAddress,
+		refund.ConfirmationToken, refund.Status, refund.CreatedAt,
+	).Scan(&refund.ID)
+}
+
+// refundColumns is the column list scanned by scanRefund. The transaction
+// hash comes from the refund's payout.
+const refundColumns = `
+	r.id, r.refund_id, r.payment_id, r.transaction_id, r.merchant_id, r.currency,
+	r.requested_amount, r.requested_currency, r.rate_source, r.rate, r.amount,
+	r.suggested_address, COALESCE(r.refund_address, ''), r.confirmation_token,
+	r.status, r.payout_id, COALESCE(o.tx_hash, ''), COALESCE(r.error, ''),
+	r.created_at, r.confirmed_at, r.completed_at`
+
+const refundFrom = `
+	FROM crypto_refunds r
+	LEFT JOIN payouts o ON o.payout_id = r.payout_id`
+
+func scanRefund(row rowScanner) (*CryptoRefund, error) {
+	var refund CryptoRefund
+	var payoutId uuid.NullUUID
+	var confirmedAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&refund.ID, &refund.RefundId, &refund.PaymentId, &refund.TransactionId,
+		&refund.MerchantId, &refund.Currency, &refund.RequestedAmount,
+		&refund.RequestedCurrency, &refund.RateSource, &refund.Rate, &refund.Amount,
+		&refund.SuggestedAddress, &refund.RefundAddress, &refund.ConfirmationToken,
+		&refund.Status, &payoutId, &refund.TxHash, &refund.Error,
+		&refund.CreatedAt, &confirmedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if payoutId.Valid {
+		refund.PayoutId = &payoutId.UUID
+	}
+	if confirmedAt.Valid {
+		refund.ConfirmedAt = &confirmedAt.Time
+	}
+	if completedAt.Valid {
+		refund.CompletedAt = &completedAt.Time
+	}
+
+	return &refund, nil
+}
+
+// GetRefund retrieves a refund by its refund ID
+func (s *service) GetRefund(refundId uuid.UUID) (*CryptoRefund, error) {
+	query := `SELECT ` + refundColumns + refundFrom + `
+		WHERE r.refund_id = $1
+	`
+
+	return scanRefund(s.db.QueryRow(query, refundId))
+}
+
+// GetRefundByPayoutId retrieves the refund sent by a payout
+func (s *service) GetRefundByPayoutId(payoutId uuid.UUID) (*CryptoRefund, error) {
+	query := `SELECT ` + refundColumns + refundFrom + `
+		WHERE r.payout_id = $1
+	`
+
+	return scanRefund(s.db.QueryRow(query, payoutId))
+}
+
+// StartRefund records the customer's confirmation and the payout that sends
+// the refund in one transaction. It reserves the payout's balance share,
+// counts the amount against what the payment received and uses up
+// refundDueCovered of the payment's refund due amount. It returns
+// ErrRefundExceedsPayment if earlier refunds already used up the payment
+// and ErrRefundNotAwaiting if the refund was confirmed concurrently.
+func (s *service) StartRefund(refund *CryptoRefund, payout *Payout, refundDueCovered float64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE crypto_payments
+		SET refunded_amount = refunded_amount + $1,
+			refund_due_amount = GREATEST(refund_due_amount - $2, 0)
+		WHERE payment_id = $3 AND refunded_amount + $1 <= received_amount
+	`, payout.Amount, refundDueCovered, refund.PaymentId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRefundExceedsPayment
+	}
+
+	if err := reserveBalance(tx, payout); err != nil {
+		return err
+	}
+
+	if err := insertPayout(tx, payout); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err = tx.Exec(`
+		UPDATE crypto_refunds
+		SET status = $1, refund_address = $2, payout_id = $3, confirmed_at = $4
+		WHERE refund_id = $5 AND status = $6
+	`, RefundProcessing, payout.ToAddress, payout.PayoutId, now, refund.RefundId, RefundAwaitingConfirmation)
+	if err != nil {
+		return err
+	}
+
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRefundNotAwaiting
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	refund.Status = RefundProcessing
+	refund.RefundAddress = payout.ToAddress
+	refund.PayoutId = &payout.PayoutId
+	refund.ConfirmedAt = &now
+	return nil
+}
+
+// CompleteRefund marks the refund sent by a payout as completed. It returns
+// the refund if its status changed and nil if it was already completed.
+func (s *service) CompleteRefund(payoutId uuid.UUID) (*CryptoRefund, error) {
+	result, err := s.db.Exec(`
+		UPDATE crypto_refunds
+		SET status = $1, completed_at = $2
+		WHERE payout_id = $3 AND status = $4
+	`, RefundCompleted, time.Now(), payoutId, RefundProcessing)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return s.GetRefundByPayoutId(payoutId)
+}
+
+// GetConfirmationPolicy returns a merchant's confirmation policy for a
+// currency, or sql.ErrNoRows if the merchant uses the default policy
+func (s *service) GetConfirmationPolicy(merchantId uint, currency string) (*ConfirmationPolicy, error) {
+	query := `
+		SELECT fiat_currency, COALESCE(max_fiat_amount, 0), confirmations, updated_at
+		FROM confirmation_tiers
+		WHERE merchant_id = $1 AND currency = $2
+		ORDER BY max_fiat_amount ASC NULLS LAST
+	`
+
+	rows, err := s.db.Query(query, merchantId, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policy := ConfirmationPolicy{MerchantId: merchantId, Currency: currency}
+	for rows.Next() {
+		var tier ConfirmationTier
+		if err := rows.Scan(&policy.FiatCurrency, &tier.MaxFiatAmount, &tier.Confirmations, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policy.Tiers = append(policy.Tiers, tier)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(policy.Tiers) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &policy, nil
+}
+
+// SetConfirmationPolicy replaces a merchant's confirmation policy for a
+// currency
+func (s *service) SetConfirmationPolicy(policy *ConfirmationPolicy) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteQuery := `DELETE FROM confirmation_tiers WHERE merchant_id = $1 AND currency = $2`
+	if _, err := tx.Exec(deleteQuery, policy.MerchantId, policy.Currency); err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO confirmation_tiers (
+			merchant_id, currency, fiat_currency, max_fiat_amount, confirmations, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	policy.UpdatedAt = time.Now()
+	for _, tier := range policy.Tiers {
+		// The unlimited last tier is stored without a limit
+		var maxFiatAmount sql.NullFloat64
+		if tier.MaxFiatAmount > 0 {
+			maxFiatAmount = sql.NullFloat64{Float64: tier.MaxFiatAmount, Valid: true}
+		}
+
+		_, err := tx.Exec(insertQuery,
+			policy.MerchantId, policy.Currency, policy.FiatCurrency,
+			maxFiatAmount, tier.Confirmations, policy.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteConfirmationPolicy reverts a merchant to the default confirmation
+// policy for a currency
+func (s *service) DeleteConfirmationPolicy(merchantId uint, currency string) error {
+	query := `DELETE FROM confirmation_tiers WHERE merchant_id = $1 AND currency = $2`
+	_, err := s.db.Exec(query, merchantId, currency)
+	return err
 }
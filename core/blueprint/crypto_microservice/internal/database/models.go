@@ -1,6 +1,7 @@
 package database
 
 import (
+	"os"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +20,16 @@ type CryptoPayment struct {
 	Currency string        `json:"currency"` // BTC, ETH, USDT
 	Status   PaymentStatus `json:"status"`
 
+	// Fiat amount the crypto amount was quoted from, if known
+	FiatAmount   float64 `json:"fiatAmount,omitempty"`
+	FiatCurrency string  `json:"fiatCurrency,omitempty"`
+
+	// Received funds, summed over all incoming transactions
+	ReceivedAmount  float64    `json:"receivedAmount"`
+	RefundDueAmount float64    `json:"refundDueAmount"`         // Overpaid or unusable underpaid funds owed to the customer
+	TopUpDeadline   *time.Time `json:"topUpDeadline,omitempty"` // Set once a payment is partially paid
+	RefundedAmount  float64    `json:"refundedAmount"`          // Sent back to the customer by refunds
+
 	// Wallet addresses
 	DestinationAddress string `json:"destinationAddress"` // Merchant wallet
 	SourceAddress      string `json:"sourceAddress"`      // Customer wallet (once detected)
@@ -27,12 +38,17 @@ type CryptoPayment struct {
 	TxHash                string `json:"txHash"`
 	BlockHeight           int64  `json:"blockHeight"`
 	Confirmations         int    `json:"confirmations"`
-	RequiredConfirmations int    `json:"requiredConfirmations"`
+	RequiredConfirmations int    `json:"requiredConfirmations"` // Chosen by the confirmation policy; 0 accepts unconfirmed payments
+
+	// Fiat value the confirmation policy was applied to
+	PolicyFiatAmount   float64 `json:"policyFiatAmount,omitempty"`
+	PolicyFiatCurrency string  `json:"policyFiatCurrency,omitempty"`
 
 	// Timestamps
 	CreatedAt   time.Time  `json:"createdAt"`
 	ExpiryTime  time.Time  `json:"expiryTime"`
 	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+	SettledAt   *time.Time `json:"settledAt,omitempty"` // Credited to the merchant wallet balance
 
 	// Testnet flag
 	IsTestnet bool `json:"isTestnet"`
@@ -46,12 +62,164 @@ type MerchantWallet struct {
 	WalletAddress string    `json:"walletAddress"`
 	PublicKey     string    `json:"publicKey"`
 	PrivateKey    string    `json:"-"` // Encrypted, never expose in JSON
-	Balance       float64   `json:"balance"`
+	WrappedKey    string    `json:"-"` // Data key wrapped with the master key
+	KeyVersion    int       `json:"keyVersion"`
+	Balance       float64   `json:"balance"` // Settled funds available for payouts
 	IsTestnet     bool      `json:"isTestnet"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 }
 
+// DepositAddress is a single-use address generated for one payment. Funds
+// received there are swept into the merchant wallet once the payment is
+// confirmed.
+type DepositAddress struct {
+	ID         uint      `gorm:"primaryKey"`
+	PaymentId  uuid.UUID `gorm:"uniqueIndex" json:"paymentId"`
+	WalletId   uint      `json:"walletId"` // Merchant wallet the deposit is swept into
+	MerchantId uint      `json:"merchantId"`
+	Currency   string    `json:"currency"`
+	Address    string    `gorm:"uniqueIndex" json:"address"`
+	PublicKey  string    `json:"publicKey"`
+	PrivateKey string    `json:"-"`
+	WrappedKey string    `json:"-"`
+	KeyVersion int       `json:"keyVersion"`
+	IsTestnet  bool      `json:"isTestnet"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// PayoutAddress is an external address a merchant whitelisted for payouts
+type PayoutAddress struct {
+	ID         uint      `gorm:"primaryKey"`
+	MerchantId uint      `json:"merchantId"`
+	Currency   string    `json:"currency"`
+	Address    string    `json:"address"`
+	Label      string    `json:"label"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Payouts to the address are refused until then, so a stolen merchant
+	// key cannot whitelist an address and drain the wallet to it at once
+	ActiveAfter time.Time `json:"activeAfter"`
+}
+
+// Payout is an outgoing transaction from a merchant-controlled key: either a
+// withdrawal to a whitelisted address or a sweep of a deposit address into
+// the merchant wallet
+type Payout struct {
+	ID                    uint         `gorm:"primaryKey"`
+	PayoutId              uuid.UUID    `gorm:"uniqueIndex" json:"payoutId"`
+	MerchantId            uint         `json:"merchantId"`
+	Currency              string       `json:"currency"`
+	Kind                  string       `json:"kind"`                // PayoutWithdrawal, PayoutSweep or PayoutRefund
+	PaymentId             *uuid.UUID   `json:"paymentId,omitempty"` // Swept or refunded payment
+	FromAddress           string       `json:"fromAddress"`
+	ToAddress             string       `json:"toAddress"`
+	Amount                float64      `json:"amount"`   // Delivered to ToAddress
+	Fee                   float64      `json:"fee"`      // Network fee paid on top of Amount
+	Reserved              float64      `json:"reserved"` // Taken from the merchant wallet balance, released on failure
+	Status                PayoutStatus `json:"status"`
+	TxHash                string       `json:"txHash,omitempty"`
+	Confirmations         int          `json:"confirmations"`
+	RequiredConfirmations int          `json:"requiredConfirmations"`
+	Error                 string       `json:"error,omitempty"`
+	CreatedAt             time.Time    `json:"createdAt"`
+	BroadcastAt           *time.Time   `json:"broadcastAt,omitempty"`
+	ConfirmedAt           *time.Time   `json:"confirmedAt,omitempty"`
+}
+
+// Payout kinds
+const (
+	PayoutWithdrawal = "withdrawal"
+	PayoutSweep      = "sweep"
+	PayoutRefund     = "refund"
+)
+
+// PayoutStatus enum
+type PayoutStatus int
+
+const (
+	PayoutPending PayoutStatus = iota
+	PayoutBroadcast
+	PayoutConfirmed
+	PayoutFailed
+)
+
+func (s PayoutStatus) String() string {
+	return [...]string{"pending", "broadcast", "confirmed", "failed"}[s]
+}
+
+func (s PayoutStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// KeyAuditEntry records every use of a merchant wallet private key
+type KeyAuditEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	WalletId   uint      `json:"walletId"`
+	MerchantId uint      `json:"merchantId"`
+	Currency   string    `json:"currency"`
+	Address    string    `json:"address"`   // Wallet or deposit address whose key was used
+	Operation  string    `json:"operation"` // "create", "sign", "rewrap"
+	Purpose    string    `json:"purpose"`
+	KeyVersion int       `json:"keyVersion"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// CryptoRefund sends part or all of a payment back to the customer. The
+// customer has to confirm the refund address before anything is sent.
+type CryptoRefund struct {
+	ID            uint      `gorm:"primaryKey"`
+	RefundId      uuid.UUID `gorm:"uniqueIndex" json:"refundId"`
+	PaymentId     uuid.UUID `json:"paymentId"`
+	TransactionId uuid.UUID `json:"transactionId"` // From PSP
+	MerchantId    uint      `json:"merchantId"`
+	Currency      string    `json:"currency"`
+
+	// Requested amount, in fiat or in the payment's cryptocurrency
+	RequestedAmount   float64 `json:"requestedAmount"`
+	RequestedCurrency string  `json:"requestedCurrency"`
+	RateSource        string  `json:"rateSource,omitempty"` // RateQuote or RateCurrent for fiat requests
+	Rate              float64 `json:"rate,omitempty"`       // Crypto units per fiat unit
+	Amount            float64 `json:"amount"`               // Sent to the customer
+
+	SuggestedAddress  string       `json:"suggestedAddress"` // Address the payment came from
+	RefundAddress     string       `json:"refundAddress,omitempty"`
+	ConfirmationToken string       `json:"-"`
+	Status            RefundStatus `json:"status"`
+	PayoutId          *uuid.UUID   `json:"payoutId,omitempty"`
+	TxHash            string       `json:"txHash,omitempty"`
+	Error             string       `json:"error,omitempty"`
+
+	CreatedAt   time.Time  `json:"createdAt"`
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// Refund rate sources
+const (
+	RateQuote   = "quote"   // Rate the payment was originally quoted at
+	RateCurrent = "current" // Current market rate
+)
+
+// RefundStatus enum
+type RefundStatus int
+
+const (
+	RefundAwaitingConfirmation RefundStatus = iota
+	RefundProcessing
+	RefundCompleted
+	RefundFailed
+)
+
+func (s RefundStatus) String() string {
+	return [...]string{"awaiting_confirmation", "processing", "completed", "failed"}[s]
+}
+
+func (s RefundStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
 // BlockchainTransaction tracks all blockchain transactions
 type BlockchainTransaction struct {
 	ID            uint       `gorm:"primaryKey"`
@@ -77,19 +245,59 @@ const (
 	Confirmed
 	Expired
 	PaymentFailed
+	PartiallyPaid
 )
 
 func (s PaymentStatus) String() string {
-	return [...]string{"pending", "confirming", "confirmed", "expired", "failed"}[s]
+	return [...]string{"pending", "confirming", "confirmed", "expired", "failed", "partially_paid"}[s]
 }
 
 // Currency configuration
 type CryptoConfig struct {
 	Currency              string
-	RequiredConfirmations int
+	RequiredConfirmations int           // Confirmations outgoing payouts wait for
 	PaymentWindow         time.Duration // How long to wait for payment
 	TestnetRPC            string
 	MainnetRPC            string
+	NetworkFee            float64 // Fee charged per outgoing transaction, in units of the currency
+
+	// Confirmation policy applied when the merchant has not set their own.
+	// Tiers are valued in DefaultPolicyFiatCurrency.
+	ConfirmationTiers []ConfirmationTier
+	ZeroConfDelay     time.Duration // How long an unconfirmed transaction is watched for double spends before it is accepted
+
+	// Monitoring
+	PollInterval time.Duration // How often open payments are checked, a fraction of the block time
+
+	// ERC-20 tokens
+	TokenContract string // Token contract address; empty for native coins
+	TokenDecimals int    // Decimal places of the token's on-chain integer amounts
+
+	// Amount matching
+	AmountTolerance     float64       // Accepted relative shortfall or excess, e.g. 0.005 = 0.5%
+	TopUpWindow         time.Duration // How long a partially paid payment waits for the rest
+	RefundUnderpayments bool          // Refund underpayments immediately instead of waiting for a top-up
+}
+
+// DefaultPolicyFiatCurrency values payments for the default confirmation
+// policies
+const DefaultPolicyFiatCurrency = "EUR"
+
+// ConfirmationTier sets the confirmations required for payments worth up to
+// MaxFiatAmount. The last tier of a policy has no limit and MaxFiatAmount 0.
+type ConfirmationTier struct {
+	MaxFiatAmount float64 `json:"maxFiatAmount"`
+	Confirmations int     `json:"confirmations"`
+}
+
+// ConfirmationPolicy is a merchant's confirmation policy for one currency,
+// with tiers in ascending order of MaxFiatAmount
+type ConfirmationPolicy struct {
+	MerchantId   uint               `json:"merchantId"`
+	Currency     string             `json:"currency"`
+	FiatCurrency string             `json:"fiatCurrency"`
+	Tiers        []ConfirmationTier `json:"tiers"`
+	UpdatedAt    time.Time          `json:"updatedAt"`
 }
 
 var SupportedCurrencies = map[string]CryptoConfig{
@@ -98,18 +306,53 @@ var SupportedCurrencies = map[string]CryptoConfig{
 		RequiredConfirmations: 3,
 		PaymentWindow:         30 * time.Minute,
 		TestnetRPC:            "https://blockstream.info/testnet/api",
+		NetworkFee:            0.00002,
+		ConfirmationTiers: []ConfirmationTier{
+			{MaxFiatAmount: 50, Confirmations: 0},
+			{MaxFiatAmount: 1000, Confirmations: 1},
+			{MaxFiatAmount: 10000, Confirmations: 3},
+			{Confirmations: 6},
+		},
+		ZeroConfDelay:   30 * time.Second,
+		PollInterval:    30 * time.Second, // ~10 minute blocks
+		AmountTolerance: 0.005,
+		TopUpWindow:     30 * time.Minute,
 	},
 	"ETH": {
 		Currency:              "ETH",
 		RequiredConfirmations: 12,
 		PaymentWindow:         30 * time.Minute,
-		TestnetRPC:            "https://sepolia.infura.io/v3/YOUR_KEY",
+		TestnetRPC:            os.Getenv("SEPOLIA_RPC_URL"),
+		NetworkFee:            0.0005,
+		ConfirmationTiers: []ConfirmationTier{
+			{MaxFiatAmount: 50, Confirmations: 0},
+			{MaxFiatAmount: 1000, Confirmations: 6},
+			{MaxFiatAmount: 10000, Confirmations: 12},
+			{Confirmations: 32},
+		},
+		ZeroConfDelay:   30 * time.Second,
+		PollInterval:    12 * time.Second, // 12 second slots
+		AmountTolerance: 0.005,
+		TopUpWindow:     30 * time.Minute,
 	},
 	"USDT": {
 		Currency:              "USDT",
 		RequiredConfirmations: 12,
 		PaymentWindow:         30 * time.Minute,
-		TestnetRPC:            "https://sepolia.infura.io/v3/YOUR_KEY", // ERC-20
+		TestnetRPC:            os.Getenv("SEPOLIA_RPC_URL"), // ERC-20
+		NetworkFee:            1.5,                          // Gas cost in USDT equivalent
+		ConfirmationTiers: []ConfirmationTier{
+			{MaxFiatAmount: 50, Confirmations: 0},
+			{MaxFiatAmount: 1000, Confirmations: 6},
+			{MaxFiatAmount: 10000, Confirmations: 12},
+			{Confirmations: 32},
+		},
+		ZeroConfDelay:   30 * time.Second,
+		PollInterval:    12 * time.Second,
+		TokenContract:   os.Getenv("USDT_CONTRACT_ADDRESS"),
+		TokenDecimals:   6,
+		AmountTolerance: 0.001,
+		TopUpWindow:     30 * time.Minute,
 	},
 }
 
@@ -123,6 +366,8 @@ type CryptoPaymentRequest struct {
 	Currency        string    `json:"currency" binding:"required"` // "BTC", "ETH", "USDT"
 	Timestamp       time.Time `json:"timestamp" binding:"required"`
 	MerchantId      uint      `json:"merchantId" binding:"required"`
+	FiatAmount      float64   `json:"fiatAmount"`
+	FiatCurrency    string    `json:"fiatCurrency"`
 }
 
 // CryptoPaymentResponse is the response sent back to PSP
@@ -139,12 +384,17 @@ type CryptoPaymentResponse struct {
 
 // PaymentStatusResponse is the response for payment status queries
 type PaymentStatusResponse struct {
-	PaymentId     uuid.UUID `json:"paymentId"`
-	Status        string    `json:"status"`
-	Confirmations int       `json:"confirmations"`
-	TxHash        string    `json:"txHash,omitempty"`
-	BlockHeight   int64     `json:"blockHeight,omitempty"`
-	ConfirmedAt   time.Time `json:"confirmedAt,omitempty"`
+	PaymentId       uuid.UUID  `json:"paymentId"`
+	Status          string     `json:"status"`
+	Confirmations   int        `json:"confirmations"`
+	TxHash          string     `json:"txHash,omitempty"`
+	BlockHeight     int64      `json:"blockHeight,omitempty"`
+	ConfirmedAt     time.Time  `json:"confirmedAt,omitempty"`
+	Amount          float64    `json:"amount"`
+	ReceivedAmount  float64    `json:"receivedAmount"`
+	RemainingAmount float64    `json:"remainingAmount"`
+	RefundDueAmount float64    `json:"refundDueAmount,omitempty"`
+	TopUpDeadline   *time.Time `json:"topUpDeadline,omitempty"`
 }
 
 // WalletGenerateRequest is the request to generate a new wallet
@@ -160,6 +410,73 @@ type WalletGenerateResponse struct {
 	PublicKey     string `json:"publicKey,omitempty"`
 }
 
+// PayoutAddressRequest whitelists an external address for payouts
+type PayoutAddressRequest struct {
+	MerchantId uint   `json:"merchantId" binding:"required"`
+	Currency   string `json:"currency" binding:"required"`
+	Address    string `json:"address" binding:"required"`
+	Label      string `json:"label"`
+}
+
+// PayoutRequest is a merchant withdrawal to a whitelisted address
+type PayoutRequest struct {
+	MerchantId      uint    `json:"merchantId" binding:"required"`
+	Currency        string  `json:"currency" binding:"required"`
+	PayoutAddressId uint    `json:"payoutAddressId" binding:"required"`
+	Amount          float64 `json:"amount" binding:"required"`
+}
+
+// PayoutFeeResponse is the fee quote for a withdrawal
+type PayoutFeeResponse struct {
+	Currency         string  `json:"currency"`
+	Fee              float64 `json:"fee"`
+	AvailableBalance float64 `json:"availableBalance"`
+	MaxPayout        float64 `json:"maxPayout"`
+}
+
+// RefundRequest asks for a refund of a PSP transaction paid in crypto.
+// Currency is either the payment's cryptocurrency or a fiat currency, in
+// which case RateSource selects the conversion rate.
+type RefundRequest struct {
+	TransactionId uuid.UUID `json:"transactionId" binding:"required"`
+	Amount        float64   `json:"amount" binding:"required"`
+	Currency      string    `json:"currency" binding:"required"`
+	RateSource    string    `json:"rateSource"`
+}
+
+// RefundResponse returns a new refund and where the customer confirms it
+type RefundResponse struct {
+	Refund            *CryptoRefund `json:"refund"`
+	ConfirmationToken string        `json:"confirmationToken"`
+	ConfirmationURL   string        `json:"confirmationURL"`
+}
+
+// RefundConfirmRequest is the customer's confirmation of the refund address
+type RefundConfirmRequest struct {
+	Token         string `json:"token" binding:"required"`
+	RefundAddress string `json:"refundAddress" binding:"required"`
+}
+
+// CryptoRefundCallback is sent to PSP when a refund changes status
+type CryptoRefundCallback struct {
+	TransactionId     uuid.UUID `json:"transactionId"`
+	RefundId          uuid.UUID `json:"refundId"`
+	Status            string    `json:"status"`
+	Amount            float64   `json:"amount"`
+	Currency          string    `json:"currency"`
+	RequestedAmount   float64   `json:"requestedAmount"`
+	RequestedCurrency string    `json:"requestedCurrency"`
+	RefundAddress     string    `json:"refundAddress"`
+	TxHash            string    `json:"txHash"`
+	CryptoTimestamp   time.Time `json:"cryptoTimestamp"`
+}
+
+// ConfirmationPolicyRequest sets a merchant's confirmation policy
+type ConfirmationPolicyRequest struct {
+	FiatCurrency string             `json:"fiatCurrency" binding:"required"`
+	Tiers        []ConfirmationTier `json:"tiers" binding:"required"`
+}
+
 // TransactionVerifyRequest is for verifying a transaction
 type TransactionVerifyRequest struct {
 	PaymentId uuid.UUID `json:"paymentId" binding:"required"`
@@ -169,8 +486,10 @@ type TransactionVerifyRequest struct {
 // TransactionVerifyResponse is the verification result
 type TransactionVerifyResponse struct {
 	Valid         bool      `json:"valid"`
-	Amount        float64   `json:"amount"`
+	Amount        float64   `json:"amount"` // Paid to the payment address by this transaction
 	Confirmations int       `json:"confirmations"`
+	Status        string    `json:"status"` // Payment status after recording the transaction
+	Error         string    `json:"error,omitempty"`
 	Timestamp     time.Time `json:"timestamp"`
 }
 
@@ -181,6 +500,8 @@ type CryptoPaymentCallback struct {
 	Status          TransactionStatus `json:"status" binding:"required"`
 	TxHash          string            `json:"txHash"`
 	Amount          float64           `json:"amount"`
+	ReceivedAmount  float64           `json:"receivedAmount"`
+	RefundDueAmount float64           `json:"refundDueAmount"`
 	Currency        string            `json:"currency"`
 	Confirmations   int               `json:"confirmations"`
 	CryptoTimestamp time.Time         `json:"cryptoTimestamp" binding:"required"`
@@ -0,0 +1,593 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/joho/godotenv/autoload"
+
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+)
+
+// Keystore holds merchant wallet private keys using envelope encryption.
+//
+// Every private key is encrypted with its own random data encryption key
+// (DEK). The DEK is in turn encrypted ("wrapped") with a versioned master key
+// loaded from configuration. Rotating the master key only rewraps DEKs; the
+// private key ciphertexts never change. Plaintext keys exist only inside the
+// keystore for the duration of a single operation, and every operation is
+// written to the key audit log.
+type Keystore struct {
+	db            database.Service
+	masterKeys    map[int][]byte
+	activeVersion int
+	mu            sync.RWMutex
+}
+
+// Audit operations
+const (
+	OpCreate = "create"
+	OpSign   = "sign"
+	OpRewrap = "rewrap"
+)
+
+var (
+	ErrNoPrivateKey   = errors.New("wallet has no stored private key")
+	ErrUnknownVersion = errors.New("master key version is not loaded")
+)
+
+var (
+	masterKeysConfig    = os.Getenv("KEYSTORE_MASTER_KEYS")
+	activeVersionConfig = os.Getenv("KEYSTORE_ACTIVE_KEY_VERSION")
+)
+
+// New creates a keystore from configuration.
+//
+// KEYSTORE_MASTER_KEYS lists the loaded master keys as comma separated
+// "version:base64key" pairs, each key being 32 bytes. KEYSTORE_ACTIVE_KEY_VERSION
+// selects the version used for new wallets and rotation; it defaults to the
+// highest loaded version.
+func New(db database.Service) (*Keystore, error) {
+	keys, err := parseMasterKeys(masterKeysConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	active := 0
+	if activeVersionConfig != "" {
+		active, err = strconv.Atoi(activeVersionConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KEYSTORE_ACTIVE_KEY_VERSION: %w", err)
+		}
+	}
+
+	return NewWithKeys(db, keys, active)
+}
+
+// NewWithKeys creates a keystore from already loaded master keys. An active
+// version of 0 selects the highest version.
+func NewWithKeys(db database.Service, masterKeys map[int][]byte, activeVersion int) (*Keystore, error) {
+	if len(masterKeys) == 0 {
+		return nil, fmt.Errorf("no master keys configured")
+	}
+
+	highest := 0
+	for version, key := range masterKeys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key version %d must be 32 bytes", version)
+		}
+		if version > highest {
+			highest = version
+		}
+	}
+
+	if activeVersion == 0 {
+		activeVersion = highest
+	}
+
+	if _, ok := masterKeys[activeVersion]; !ok {
+		return nil, fmt.Errorf("active master key version %d: %w", activeVersion, ErrUnknownVersion)
+	}
+
+	return &Keystore{
+		db:            db,
+		masterKeys:    masterKeys,
+		activeVersion: activeVersion,
+	}, nil
+}
+
+func parseMasterKeys(config string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionStr, encoded, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid KEYSTORE_MASTER_KEYS entry, expected version:key")
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid master key version %q", versionStr)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key version %d: %w", version, err)
+		}
+
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("duplicate master key version %d", version)
+		}
+		keys[version] = key
+	}
+
+	return keys, nil
+}
+
+// ActiveVersion returns the master key version used for new wallets
+func (k *Keystore) ActiveVersion() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeVersion
+}
+
+// LoadedVersions returns the loaded master key versions in ascending order
+func (k *Keystore) LoadedVersions() []int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	versions := make([]int, 0, len(k.masterKeys))
+	for version := range k.masterKeys {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// keyRecord is the key material of a merchant wallet or deposit address
+type keyRecord struct {
+	walletId     uint
+	merchantId   uint
+	currency     string
+	address      string
+	encryptedKey string
+	wrappedKey   string
+	keyVersion   int
+	testnet      bool
+}
+
+func walletKey(wallet *database.MerchantWallet) keyRecord {
+	return keyRecord{
+		walletId:     wallet.ID,
+		merchantId:   wallet.MerchantId,
+		currency:     wallet.Currency,
+		address:      wallet.WalletAddress,
+		encryptedKey: wallet.PrivateKey,
+		wrappedKey:   wallet.WrappedKey,
+		keyVersion:   wallet.KeyVersion,
+		testnet:      wallet.IsTestnet,
+	}
+}
+
+func depositKey(deposit *database.DepositAddress) keyRecord {
+	return keyRecord{
+		walletId:     deposit.WalletId,
+		merchantId:   deposit.MerchantId,
+		currency:     deposit.Currency,
+		address:      deposit.Address,
+		encryptedKey: deposit.PrivateKey,
+		wrappedKey:   deposit.WrappedKey,
+		keyVersion:   deposit.KeyVersion,
+		testnet:      deposit.IsTestnet,
+	}
+}
+
+// generate creates a new key pair and encrypts its private key under the
+// active master key
+func (k *Keystore) generate(currency string, testnet bool) (*blockchain.Wallet, keyRecord, error) {
+	generated, err := blockchain.GenerateWallet(currency, testnet)
+	if err != nil {
+		return nil, keyRecord{}, err
+	}
+
+	privateKey := generated.PrivateKey.Serialize()
+	defer zero(privateKey)
+
+	k.mu.RLock()
+	version := k.activeVersion
+	masterKey := k.masterKeys[version]
+	k.mu.RUnlock()
+
+	encryptedKey, wrappedKey, err := seal(masterKey, version, generated.Address, privateKey)
+	if err != nil {
+		return nil, keyRecord{}, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	return generated, keyRecord{
+		currency:     currency,
+		address:      generated.Address,
+		encryptedKey: encryptedKey,
+		wrappedKey:   wrappedKey,
+		keyVersion:   version,
+		testnet:      testnet,
+	}, nil
+}
+
+// CreateMerchantWallet generates a new wallet for the merchant, stores its
+// encrypted private key and returns the stored record. If a concurrent
+// request already created the wallet, that wallet is returned instead.
+func (k *Keystore) CreateMerchantWallet(merchantId uint, currency string, testnet bool) (*database.MerchantWallet, error) {
+	generated, key, err := k.generate(currency, testnet)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := &database.MerchantWallet{
+		MerchantId:    merchantId,
+		Currency:      currency,
+		WalletAddress: generated.Address,
+		PublicKey:     generated.GetPublicKeyHex(),
+		PrivateKey:    key.encryptedKey,
+		WrappedKey:    key.wrappedKey,
+		KeyVersion:    key.keyVersion,
+		Balance:       0.0,
+		IsTestnet:     testnet,
+	}
+
+	if err := k.db.CreateMerchantWallet(wallet); err != nil {
+		return nil, err
+	}
+
+	// Another request won the race; our key was never stored
+	if wallet.WalletAddress != generated.Address {
+		return wallet, nil
+	}
+
+	k.audit(walletKey(wallet), OpCreate, "wallet generation", key.keyVersion, nil)
+
+	return wallet, nil
+}
+
+// CreateDepositAddress generates a single-use deposit address for a payment
+// to the merchant wallet and stores its encrypted private key
+func (k *Keystore) CreateDepositAddress(wallet *database.MerchantWallet, paymentId uuid.UUID) (*database.DepositAddress, error) {
+	generated, key, err := k.generate(wallet.Currency, wallet.IsTestnet)
+	if err != nil {
+		return nil, err
+	}
+
+	deposit := &database.DepositAddress{
+		PaymentId:  paymentId,
+		WalletId:   wallet.ID,
+		MerchantId: wallet.MerchantId,
+		Currency:   wallet.Currency,
+		Address:    generated.Address,
+		PublicKey:  generated.GetPublicKeyHex(),
+		PrivateKey: key.encryptedKey,
+		WrappedKey: key.wrappedKey,
+		KeyVersion: key.keyVersion,
+		IsTestnet:  wallet.IsTestnet,
+	}
+
+	if err := k.db.CreateDepositAddress(deposit); err != nil {
+		return nil, err
+	}
+
+	k.audit(depositKey(deposit), OpCreate, fmt.Sprintf("deposit address for payment %s", paymentId), key.keyVersion, nil)
+
+	return deposit, nil
+}
+
+// SignTransaction signs a transaction with the wallet's private key
+func (k *Keystore) SignTransaction(wallet *database.MerchantWallet, tb *blockchain.TransactionBuilder, purpose string) error {
+	return k.withKey(walletKey(wallet), purpose, func(w *blockchain.Wallet) error {
+		return tb.Sign(w)
+	})
+}
+
+// SignDepositTransaction signs a transaction with a deposit address's
+// private key
+func (k *Keystore) SignDepositTransaction(deposit *database.DepositAddress, tb *blockchain.TransactionBuilder, purpose string) error {
+	return k.withKey(depositKey(deposit), purpose, func(w *blockchain.Wallet) error {
+		return tb.Sign(w)
+	})
+}
+
+// SignMessage signs an arbitrary message with the wallet's private key
+func (k *Keystore) SignMessage(wallet *database.MerchantWallet, message []byte, purpose string) (string, error) {
+	var signature string
+	err := k.withKey(walletKey(wallet), purpose, func(w *blockchain.Wallet) error {
+		var err error
+		signature, err = w.SignMessage(message)
+		return err
+	})
+	return signature, err
+}
+
+// withKey decrypts a private key, runs fn with it and audits the use. The
+// decrypted key never leaves this function.
+func (k *Keystore) withKey(key keyRecord, purpose string, fn func(w *blockchain.Wallet) error) (err error) {
+	defer func() {
+		k.audit(key, OpSign, purpose, key.keyVersion, err)
+	}()
+
+	if key.encryptedKey == "" || key.wrappedKey == "" {
+		return ErrNoPrivateKey
+	}
+
+	masterKey, err := k.masterKey(key.keyVersion)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := open(masterKey, key.keyVersion, key.address, key.encryptedKey, key.wrappedKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	defer zero(privateKey)
+
+	w, err := blockchain.WalletFromPrivateKeyBytes(privateKey, key.currency, key.testnet)
+	if err != nil {
+		return err
+	}
+
+	if w.Address != key.address {
+		return fmt.Errorf("decrypted key does not match wallet address")
+	}
+
+	return fn(w)
+}
+
+// RotationResult summarises a master key rotation run
+type RotationResult struct {
+	ActiveVersion int `json:"activeVersion"`
+	Rewrapped     int `json:"rewrapped"`
+	Failed        int `json:"failed"`
+}
+
+// Rotate rewraps the data keys of every wallet and deposit address that is
+// not yet on the active master key version. It processes keys in batches and
+// can be rerun safely; once it reports no failures, older master keys can be
+// removed from configuration.
+func (k *Keystore) Rotate() (*RotationResult, error) {
+	const batchSize = 100
+
+	active := k.ActiveVersion()
+	activeKey, err := k.masterKey(active)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RotationResult{ActiveVersion: active}
+	record := func(err error, kind string, id uint) {
+		if err != nil {
+			fmt.Printf("Failed to rewrap key for %s %d: %v\n", kind, id, err)
+			result.Failed++
+			return
+		}
+		result.Rewrapped++
+	}
+
+	afterId := uint(0)
+	for {
+		wallets, err := k.db.GetWalletsForRewrap(active, afterId, batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(wallets) == 0 {
+			break
+		}
+
+		for i := range wallets {
+			wallet := &wallets[i]
+			afterId = wallet.ID
+
+			err := k.rewrap(walletKey(wallet), active, activeKey, func(wrapped string) error {
+				return k.db.UpdateWalletKeyWrap(wallet.ID, wrapped, active, wallet.KeyVersion)
+			})
+			record(err, "wallet", wallet.ID)
+		}
+	}
+
+	afterId = 0
+	for {
+		deposits, err := k.db.GetDepositAddressesForRewrap(active, afterId, batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(deposits) == 0 {
+			return result, nil
+		}
+
+		for i := range deposits {
+			deposit := &deposits[i]
+			afterId = deposit.ID
+
+			err := k.rewrap(depositKey(deposit), active, activeKey, func(wrapped string) error {
+				return k.db.UpdateDepositKeyWrap(deposit.ID, wrapped, active, deposit.KeyVersion)
+			})
+			record(err, "deposit address", deposit.ID)
+		}
+	}
+}
+
+// rewrap unwraps a data key with its current master key, wraps it with the
+// new one and hands the result to store
+func (k *Keystore) rewrap(key keyRecord, newVersion int, newKey []byte, store func(wrapped string) error) (err error) {
+	oldVersion := key.keyVersion
+	defer func() {
+		k.audit(key, OpRewrap, fmt.Sprintf("master key rotation v%d -> v%d", oldVersion, newVersion), newVersion, err)
+	}()
+
+	oldKey, err := k.masterKey(oldVersion)
+	if err != nil {
+		return err
+	}
+
+	dek, err := unwrapKey(oldKey, oldVersion, key.address, key.wrappedKey)
+	if err != nil {
+		return err
+	}
+	defer zero(dek)
+
+	wrapped, err := wrapKey(newKey, newVersion, key.address, dek)
+	if err != nil {
+		return err
+	}
+
+	return store(wrapped)
+}
+
+func (k *Keystore) masterKey(version int) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.masterKeys[version]
+	if !ok {
+		return nil, fmt.Errorf("master key version %d: %w", version, ErrUnknownVersion)
+	}
+	return key, nil
+}
+
+func (k *Keystore) audit(key keyRecord, operation, purpose string, keyVersion int, opErr error) {
+	entry := database.KeyAuditEntry{
+		WalletId:   key.walletId,
+		MerchantId: key.merchantId,
+		Currency:   key.currency,
+		Address:    key.address,
+		Operation:  operation,
+		Purpose:    purpose,
+		KeyVersion: keyVersion,
+		Success:    opErr == nil,
+		CreatedAt:  time.Now(),
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+
+	if err := k.db.CreateKeyAuditEntry(&entry); err != nil {
+		fmt.Printf("Failed to write key audit entry for %s: %v\n", key.address, err)
+	}
+}
+
+// seal encrypts a private key under a fresh DEK and wraps the DEK with the
+// master key. Both ciphertexts are bound to the wallet address.
+func seal(masterKey []byte, version int, address string, privateKey []byte) (encryptedKey string, wrappedKey string, err error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", "", err
+	}
+	defer zero(dek)
+
+	ciphertext, err := gcmSeal(dek, privateKey, []byte("key:"+address))
+	if err != nil {
+		return "", "", err
+	}
+
+	wrappedKey, err = wrapKey(masterKey, version, address, dek)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), wrappedKey, nil
+}
+
+// open reverses seal and returns the plaintext private key
+func open(masterKey []byte, version int, address string, encryptedKey string, wrappedKey string) ([]byte, error) {
+	dek, err := unwrapKey(masterKey, version, address, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dek)
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcmOpen(dek, ciphertext, []byte("key:"+address))
+}
+
+func wrapKey(masterKey []byte, version int, address string, dek []byte) (string, error) {
+	ciphertext, err := gcmSeal(masterKey, dek, wrapAAD(version, address))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func unwrapKey(masterKey []byte, version int, address string, wrappedKey string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return gcmOpen(masterKey, ciphertext, wrapAAD(version, address))
+}
+
+func wrapAAD(version int, address string) []byte {
+	return []byte(fmt.Sprintf("dek:v%d:%s", version, address))
+}
+
+// gcmSeal encrypts with AES-256-GCM and prepends the nonce
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// gcmOpen decrypts a nonce-prefixed AES-256-GCM ciphertext
+func gcmOpen(key, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,87 @@
+package keystore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{1}, 32)
+	privateKey := bytes.Repeat([]byte{7}, 32)
+	address := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+
+	encrypted, wrapped, err := seal(masterKey, 1, address, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := open(masterKey, 1, address, encrypted, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, privateKey) {
+		t.Fatal("decrypted key does not match")
+	}
+
+	// Ciphertexts are bound to the wallet address and key version
+	if _, err := open(masterKey, 1, "tb1qother", encrypted, wrapped); err == nil {
+		t.Error("expected failure for a different address")
+	}
+	if _, err := open(masterKey, 2, address, encrypted, wrapped); err == nil {
+		t.Error("expected failure for a different key version")
+	}
+}
+
+func TestRewrapKeepsPrivateKeyCiphertext(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	privateKey := bytes.Repeat([]byte{9}, 32)
+	address := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	encrypted, wrapped, err := seal(oldKey, 1, address, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dek, err := unwrapKey(oldKey, 1, address, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, err := wrapKey(newKey, 2, address, dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := open(newKey, 2, address, encrypted, rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, privateKey) {
+		t.Fatal("decrypted key does not match after rewrap")
+	}
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	keys, err := parseMasterKeys("1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=, 2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewWithKeys(nil, keys, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.ActiveVersion() != 2 {
+		t.Errorf("active version = %d, want 2", ks.ActiveVersion())
+	}
+
+	if _, err := NewWithKeys(nil, keys, 3); err == nil {
+		t.Error("expected error for unknown active version")
+	}
+	if _, err := NewWithKeys(nil, map[int][]byte{1: {1}}, 0); err == nil {
+		t.Error("expected error for short master key")
+	}
+	if _, err := parseMasterKeys("1:AQ==,1:AQ=="); err == nil {
+		t.Error("expected error for duplicate version")
+	}
+}
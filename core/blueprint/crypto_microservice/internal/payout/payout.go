@@ -0,0 +1,403 @@
+package payout
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/keystore"
+	"crypto_microservice/internal/rates"
+)
+
+// Service moves funds out of merchant-controlled keys. It sends merchant
+// withdrawals to whitelisted addresses, refunds to customers and sweeps of
+// confirmed per-payment deposit addresses into the merchant wallet, and
+// tracks outgoing transactions until they are confirmed.
+//
+// The merchant wallet balance only counts settled funds. A payment is
+// settled when its deposit sweep confirms, or straight away if it was paid
+// to the merchant wallet directly. Withdrawals reserve their amount and fee
+// from the balance up front and release it again if they fail.
+//
+// A newly whitelisted address only receives withdrawals once its activation
+// delay has passed, which leaves the merchant time to notice and remove an
+// address they did not add.
+type Service struct {
+	db           database.Service
+	keys         *keystore.Keystore
+	providers    map[string]blockchain.ChainProvider
+	rates        rates.Source
+	notifier     RefundNotifier
+	addressDelay time.Duration
+	stopChan     chan struct{}
+}
+
+// DefaultAddressDelay is how long a new payout address waits before it can
+// receive withdrawals
+const DefaultAddressDelay = 24 * time.Hour
+
+var (
+	ErrAddressNotWhitelisted = errors.New("payout address is not whitelisted for this merchant and currency")
+	ErrAddressNotActive      = errors.New("payout address is not active yet")
+	ErrNoProvider            = errors.New("no chain provider for currency")
+)
+
+func NewService(db database.Service, keys *keystore.Keystore, providers map[string]blockchain.ChainProvider, rateSource rates.Source) *Service {
+	return &Service{
+		db:           db,
+		keys:         keys,
+		providers:    providers,
+		rates:        rateSource,
+		addressDelay: DefaultAddressDelay,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// SetAddressDelay sets the activation delay of new payout addresses. It
+// must be called before Start.
+func (s *Service) SetAddressDelay(delay time.Duration) {
+	if delay >= 0 {
+		s.addressDelay = delay
+	}
+}
+
+func (s *Service) Start() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkBroadcastPayouts()
+			s.sweepDeposits()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	close(s.stopChan)
+}
+
+func (s *Service) provider(currency string) (blockchain.ChainProvider, error) {
+	provider, ok := s.providers[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoProvider, currency)
+	}
+	return provider, nil
+}
+
+// EstimateFee returns the current network fee for a payout in the currency
+func (s *Service) EstimateFee(currency string) (float64, error) {
+	provider, err := s.provider(currency)
+	if err != nil {
+		return 0, err
+	}
+	return provider.EstimateFee()
+}
+
+// WhitelistAddress stores a merchant's new payout address, active once the
+// activation delay has passed
+func (s *Service) WhitelistAddress(address *database.PayoutAddress) error {
+	address.ActiveAfter = time.Now().Add(s.addressDelay)
+	return s.db.CreatePayoutAddress(address)
+}
+
+// RequestWithdrawal reserves the amount plus the network fee from the
+// merchant wallet balance and sends it to an active whitelisted address. The
+// payout is returned even if broadcasting failed, in which case it is marked
+// failed and the reservation released.
+func (s *Service) RequestWithdrawal(req database.PayoutRequest) (*database.Payout, error) {
+	config, exists := database.SupportedCurrencies[req.Currency]
+	if !exists {
+		return nil, fmt.Errorf("unsupported currency: %s", req.Currency)
+	}
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	provider, err := s.provider(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := s.db.GetPayoutAddress(req.PayoutAddressId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAddressNotWhitelisted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if address.MerchantId != req.MerchantId || address.Currency != req.Currency {
+		return nil, ErrAddressNotWhitelisted
+	}
+	if time.Now().Before(address.ActiveAfter) {
+		return nil, fmt.Errorf("%w: active after %s", ErrAddressNotActive, address.ActiveAfter.Format(time.RFC3339))
+	}
+
+	wallet, err := s.db.GetWallet(req.MerchantId, req.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("merchant wallet not found: %w", err)
+	}
+
+	fee, err := provider.EstimateFee()
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate fee: %w", err)
+	}
+
+	payout := &database.Payout{
+		PayoutId:              uuid.New(),
+		MerchantId:            req.MerchantId,
+		Currency:              req.Currency,
+		Kind:                  database.PayoutWithdrawal,
+		FromAddress:           wallet.WalletAddress,
+		ToAddress:             address.Address,
+		Amount:                blockchain.RoundAmount(req.Amount),
+		Fee:                   fee,
+		Reserved:              blockchain.RoundAmount(req.Amount + fee),
+		Status:                database.PayoutPending,
+		RequiredConfirmations: config.RequiredConfirmations,
+		CreatedAt:             time.Now(),
+	}
+
+	if err := s.db.CreateWithdrawal(payout); err != nil {
+		return nil, err
+	}
+
+	purpose := fmt.Sprintf("withdrawal %s", payout.PayoutId)
+	s.send(payout, provider, func(tb *blockchain.TransactionBuilder) error {
+		return s.keys.SignTransaction(wallet, tb, purpose)
+	})
+
+	return payout, nil
+}
+
+// send builds, signs and broadcasts a stored payout. Any failure before the
+// broadcast marks the payout failed.
+func (s *Service) send(payout *database.Payout, provider blockchain.ChainProvider, sign func(tb *blockchain.TransactionBuilder) error) {
+	tb := blockchain.NewTransactionBuilder().
+		SetFromAddress(payout.FromAddress).
+		SetToAddress(payout.ToAddress).
+		SetAmount(payout.Amount).
+		SetCurrency(payout.Currency)
+
+	fail := func(err error) {
+		fmt.Printf("Payout %s failed: %v\n", payout.PayoutId, err)
+		if err := s.db.FailPayout(payout, err.Error()); err != nil {
+			fmt.Printf("Failed to mark payout %s as failed: %v\n", payout.PayoutId, err)
+		}
+	}
+
+	tx, err := tb.Build()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := sign(tb); err != nil {
+		fail(fmt.Errorf("failed to sign transaction: %w", err))
+		return
+	}
+
+	txHash, err := provider.BroadcastTransaction(tx)
+	if err != nil {
+		fail(fmt.Errorf("failed to broadcast transaction: %w", err))
+		return
+	}
+
+	now := time.Now()
+	payout.Status = database.PayoutBroadcast
+	payout.TxHash = txHash
+	payout.BroadcastAt = &now
+
+	// The transaction is on its way; a failed update must not release funds
+	if err := s.db.UpdatePayout(payout); err != nil {
+		fmt.Printf("Failed to record broadcast of payout %s (tx %s): %v\n", payout.PayoutId, txHash, err)
+		return
+	}
+
+	fmt.Printf("Payout %s broadcast: %.8f %s to %s (tx %s)\n",
+		payout.PayoutId, payout.Amount, payout.Currency, payout.ToAddress, txHash)
+}
+
+// checkBroadcastPayouts updates the confirmation count of every broadcast
+// payout and completes those that reached their required confirmations
+func (s *Service) checkBroadcastPayouts() {
+	payouts, err := s.db.GetPayoutsByStatus(database.PayoutBroadcast, 100)
+	if err != nil {
+		fmt.Printf("Failed to load broadcast payouts: %v\n", err)
+		return
+	}
+
+	for i := range payouts {
+		payout := &payouts[i]
+
+		provider, err := s.provider(payout.Currency)
+		if err != nil {
+			fmt.Printf("Payout %s: %v\n", payout.PayoutId, err)
+			continue
+		}
+
+		confirmations, err := provider.GetConfirmations(payout.TxHash)
+		if err != nil {
+			fmt.Printf("Failed to check payout %s: %v\n", payout.PayoutId, err)
+			continue
+		}
+		payout.Confirmations = confirmations
+
+		if confirmations >= payout.RequiredConfirmations {
+			// Settle before confirming so a crash in between is retried
+			switch payout.Kind {
+			case database.PayoutSweep:
+				if err := s.settleSweep(payout); err != nil {
+					fmt.Printf("Failed to settle sweep %s: %v\n", payout.PayoutId, err)
+					continue
+				}
+			case database.PayoutRefund:
+				refund, err := s.db.CompleteRefund(payout.PayoutId)
+				if err != nil {
+					fmt.Printf("Failed to complete refund for payout %s: %v\n", payout.PayoutId, err)
+					continue
+				}
+				if refund != nil {
+					s.notifyRefund(refund)
+				}
+			}
+
+			now := time.Now()
+			payout.Status = database.PayoutConfirmed
+			payout.ConfirmedAt = &now
+		}
+
+		if err := s.db.UpdatePayout(payout); err != nil {
+			fmt.Printf("Failed to update payout %s: %v\n", payout.PayoutId, err)
+		}
+	}
+}
+
+// sweepDeposits settles confirmed payments. Payments with a deposit address
+// are swept into the merchant wallet and settle once the sweep confirms;
+// payments made to the merchant wallet directly are settled immediately.
+func (s *Service) sweepDeposits() {
+	payments, err := s.db.GetUnsettledPayments(100)
+	if err != nil {
+		fmt.Printf("Failed to load unsettled payments: %v\n", err)
+		return
+	}
+
+	for i := range payments {
+		payment := &payments[i]
+
+		deposit, err := s.db.GetDepositAddress(payment.PaymentId)
+		if errors.Is(err, sql.ErrNoRows) {
+			s.settle(payment, 0)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Failed to load deposit address for payment %s: %v\n", payment.PaymentId, err)
+			continue
+		}
+
+		if err := s.sweep(payment, deposit); err != nil {
+			fmt.Printf("Failed to sweep payment %s: %v\n", payment.PaymentId, err)
+		}
+	}
+}
+
+func (s *Service) sweep(payment *database.CryptoPayment, deposit *database.DepositAddress) error {
+	config, exists := database.SupportedCurrencies[payment.Currency]
+	if !exists {
+		return fmt.Errorf("unsupported currency: %s", payment.Currency)
+	}
+
+	provider, err := s.provider(payment.Currency)
+	if err != nil {
+		return err
+	}
+
+	fee, err := provider.EstimateFee()
+	if err != nil {
+		return fmt.Errorf("failed to estimate fee: %w", err)
+	}
+
+	// Not worth moving; leave the dust on the deposit address and credit
+	// nothing
+	amount := blockchain.RoundAmount(payment.ReceivedAmount - fee)
+	if amount <= 0 {
+		fmt.Printf("Payment %s deposit is below the network fee, settling without sweep\n", payment.PaymentId)
+		s.settle(payment, payment.ReceivedAmount)
+		return nil
+	}
+
+	wallet, err := s.db.GetWallet(deposit.MerchantId, deposit.Currency)
+	if err != nil {
+		return fmt.Errorf("merchant wallet not found: %w", err)
+	}
+
+	payout := &database.Payout{
+		PayoutId:              uuid.New(),
+		MerchantId:            deposit.MerchantId,
+		Currency:              deposit.Currency,
+		Kind:                  database.PayoutSweep,
+		PaymentId:             &payment.PaymentId,
+		FromAddress:           deposit.Address,
+		ToAddress:             wallet.WalletAddress,
+		Amount:                amount,
+		Fee:                   fee,
+		Status:                database.PayoutPending,
+		RequiredConfirmations: config.RequiredConfirmations,
+		CreatedAt:             time.Now(),
+	}
+
+	if err := s.db.CreatePayout(payout); err != nil {
+		return err
+	}
+
+	purpose := fmt.Sprintf("sweep of payment %s", payment.PaymentId)
+	s.send(payout, provider, func(tb *blockchain.TransactionBuilder) error {
+		return s.keys.SignDepositTransaction(deposit, tb, purpose)
+	})
+
+	return nil
+}
+
+func (s *Service) settleSweep(payout *database.Payout) error {
+	payment, err := s.db.GetPaymentByPaymentId(*payout.PaymentId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.SettlePayment(payment.PaymentId, SettlementCredit(payment, payout.Fee))
+	return err
+}
+
+func (s *Service) settle(payment *database.CryptoPayment, fee float64) {
+	credit := SettlementCredit(payment, fee)
+
+	settled, err := s.db.SettlePayment(payment.PaymentId, credit)
+	if err != nil {
+		fmt.Printf("Failed to settle payment %s: %v\n", payment.PaymentId, err)
+		return
+	}
+	if settled {
+		fmt.Printf("Payment %s settled: credited %.8f %s to merchant %d\n",
+			payment.PaymentId, credit, payment.Currency, payment.MerchantId)
+	}
+}
+
+// SettlementCredit returns what a confirmed payment adds to the merchant
+// wallet balance: everything received, less funds owed back to the customer
+// and the fee for moving the funds into the merchant wallet
+func SettlementCredit(payment *database.CryptoPayment, fee float64) float64 {
+	credit := blockchain.RoundAmount(payment.ReceivedAmount - payment.RefundDueAmount - fee)
+	if credit < 0 {
+		return 0
+	}
+	return credit
+}
@@ -0,0 +1,31 @@
+package payout
+
+import (
+	"testing"
+
+	"crypto_microservice/internal/database"
+)
+
+func TestSettlementCredit(t *testing.T) {
+	tests := []struct {
+		name      string
+		received  float64
+		refundDue float64
+		fee       float64
+		want      float64
+	}{
+		{"direct payment", 1.0, 0, 0, 1.0},
+		{"swept payment", 1.0, 0, 0.0001, 0.9999},
+		{"overpayment keeps refund", 1.5, 0.5, 0.0001, 0.9999},
+		{"dust", 0.00001, 0, 0.0001, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &database.CryptoPayment{ReceivedAmount: tt.received, RefundDueAmount: tt.refundDue}
+			if got := SettlementCredit(payment, tt.fee); got != tt.want {
+				t.Errorf("credit = %.8f, want %.8f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package payout
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/rates"
+)
+
+// RefundNotifier reports refund status changes to the PSP
+type RefundNotifier interface {
+	SendRefundCallbackToPSP(refund *database.CryptoRefund)
+}
+
+var (
+	ErrNotRefundable = errors.New("payment cannot be refunded in its current state")
+	ErrNotSettled    = errors.New("payment is not settled yet, retry once it has been swept")
+	ErrInvalidToken  = errors.New("invalid refund confirmation token")
+)
+
+// SetRefundNotifier sets the notifier for refund status changes
+func (s *Service) SetRefundNotifier(notifier RefundNotifier) {
+	s.notifier = notifier
+}
+
+// RequestRefund creates a refund for the crypto payment of a PSP
+// transaction. Nothing is sent until the customer confirms the refund
+// address with ConfirmRefund.
+func (s *Service) RequestRefund(req database.RefundRequest) (*database.CryptoRefund, error) {
+	payment, err := s.db.GetPaymentByTransactionId(req.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := refundFromDeposit(payment); err != nil {
+		return nil, err
+	}
+
+	amount, rate, err := quoteRefund(payment, req.Amount, req.Currency, req.RateSource, s.rates)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount > blockchain.RoundAmount(payment.ReceivedAmount-payment.RefundedAmount) {
+		return nil, database.ErrRefundExceedsPayment
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	refund := &database.CryptoRefund{
+		RefundId:          uuid.New(),
+		PaymentId:         payment.PaymentId,
+		TransactionId:     payment.TransactionId,
+		MerchantId:        payment.MerchantId,
+		Currency:          payment.Currency,
+		RequestedAmount:   req.Amount,
+		RequestedCurrency: req.Currency,
+		Rate:              rate,
+		Amount:            amount,
+		SuggestedAddress:  payment.SourceAddress,
+		ConfirmationToken: hex.EncodeToString(token),
+		Status:            database.RefundAwaitingConfirmation,
+		CreatedAt:         time.Now(),
+	}
+	if req.Currency != payment.Currency {
+		refund.RateSource = req.RateSource
+	}
+
+	if err := s.db.CreateRefund(refund); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// ConfirmRefund records the customer's refund address and sends the refund.
+// Refunds of settled payments are paid from the merchant wallet; payments
+// that never settled are refunded from their deposit address. The merchant
+// balance only covers the part of a refund that is not already owed to the
+// customer as refund due, plus the network fee.
+func (s *Service) ConfirmRefund(refundId uuid.UUID, req database.RefundConfirmRequest) (*database.CryptoRefund, error) {
+	refund, err := s.db.GetRefund(refundId)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(refund.ConfirmationToken)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	if refund.Status != database.RefundAwaitingConfirmation {
+		return nil, database.ErrRefundNotAwaiting
+	}
+
+	payment, err := s.db.GetPaymentByPaymentId(refund.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := blockchain.ValidateAddress(req.RefundAddress, payment.Currency, payment.IsTestnet); err != nil {
+		return nil, err
+	}
+
+	config, exists := database.SupportedCurrencies[payment.Currency]
+	if !exists {
+		return nil, fmt.Errorf("unsupported currency: %s", payment.Currency)
+	}
+
+	provider, err := s.provider(payment.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := provider.EstimateFee()
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate fee: %w", err)
+	}
+
+	var deposit *database.DepositAddress
+	fromDeposit, err := refundFromDeposit(payment)
+	if err != nil {
+		return nil, err
+	}
+	if fromDeposit {
+		deposit, err = s.db.GetDepositAddress(payment.PaymentId)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Paid straight to the merchant wallet before deposit addresses
+			fromDeposit = false
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	covered, reserved, err := splitRefundCost(payment, fromDeposit, refund.Amount, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &database.Payout{
+		PayoutId:              uuid.New(),
+		MerchantId:            payment.MerchantId,
+		Currency:              payment.Currency,
+		Kind:                  database.PayoutRefund,
+		PaymentId:             &payment.PaymentId,
+		ToAddress:             blockchain.NormalizeAddress(req.RefundAddress, payment.Currency),
+		Amount:                refund.Amount,
+		Fee:                   fee,
+		Reserved:              reserved,
+		Status:                database.PayoutPending,
+		RequiredConfirmations: config.RequiredConfirmations,
+		CreatedAt:             time.Now(),
+	}
+
+	purpose := fmt.Sprintf("refund %s", refund.RefundId)
+	var sign func(tb *blockchain.TransactionBuilder) error
+
+	if fromDeposit {
+		payout.FromAddress = deposit.Address
+		sign = func(tb *blockchain.TransactionBuilder) error {
+			return s.keys.SignDepositTransaction(deposit, tb, purpose)
+		}
+	} else {
+		wallet, err := s.db.GetWallet(payment.MerchantId, payment.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("merchant wallet not found: %w", err)
+		}
+		payout.FromAddress = wallet.WalletAddress
+		sign = func(tb *blockchain.TransactionBuilder) error {
+			return s.keys.SignTransaction(wallet, tb, purpose)
+		}
+	}
+
+	if err := s.db.StartRefund(refund, payout, covered); err != nil {
+		return nil, err
+	}
+	s.notifyRefund(refund)
+
+	s.send(payout, provider, sign)
+
+	if payout.Status == database.PayoutFailed {
+		refund, err = s.db.GetRefund(refundId)
+		if err != nil {
+			return nil, err
+		}
+		s.notifyRefund(refund)
+	}
+
+	return refund, nil
+}
+
+func (s *Service) notifyRefund(refund *database.CryptoRefund) {
+	fmt.Printf("Refund %s status changed to: %s\n", refund.RefundId, refund.Status.String())
+
+	if s.notifier != nil {
+		s.notifier.SendRefundCallbackToPSP(refund)
+	}
+}
+
+// refundFromDeposit reports whether a refund of the payment is paid from its
+// deposit address rather than the merchant wallet, or why the payment cannot
+// be refunded. Confirmed payments are refundable once settled; expired and
+// failed payments are refundable if they received anything.
+func refundFromDeposit(payment *database.CryptoPayment) (bool, error) {
+	switch payment.Status {
+	case database.Confirmed:
+		if payment.SettledAt == nil {
+			return false, ErrNotSettled
+		}
+		return false, nil
+	case database.Expired, database.PaymentFailed:
+		if payment.ReceivedAmount <= 0 {
+			return false, ErrNotRefundable
+		}
+		return true, nil
+	default:
+		return false, ErrNotRefundable
+	}
+}
+
+// quoteRefund converts a requested refund amount into the payment's
+// cryptocurrency. Fiat amounts are converted at the rate the payment was
+// quoted at or at the current rate; crypto amounts are taken as is.
+func quoteRefund(payment *database.CryptoPayment, amount float64, currency, rateSource string, source rates.Source) (float64, float64, error) {
+	if amount <= 0 {
+		return 0, 0, fmt.Errorf("amount must be positive")
+	}
+
+	if currency == payment.Currency {
+		return blockchain.RoundAmount(amount), 0, nil
+	}
+
+	var rate float64
+	switch rateSource {
+	case database.RateQuote:
+		if payment.FiatCurrency != currency || payment.FiatAmount <= 0 {
+			return 0, 0, fmt.Errorf("payment was not quoted in %s", currency)
+		}
+		rate = payment.Amount / payment.FiatAmount
+	case database.RateCurrent:
+		var err error
+		rate, err = source.Rate(currency, payment.Currency)
+		if err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("rate source must be %q or %q for fiat refunds", database.RateQuote, database.RateCurrent)
+	}
+
+	return blockchain.RoundAmount(amount * rate), rate, nil
+}
+
+// splitRefundCost works out how much of a refund is paid out of the
+// payment's refund due amount and how much is reserved from the merchant
+// wallet balance. A deposit address refund spends only the customer's own
+// funds, so the amount and fee must fit in the refund due amount.
+func splitRefundCost(payment *database.CryptoPayment, fromDeposit bool, amount, fee float64) (covered float64, reserved float64, err error) {
+	if fromDeposit {
+		covered = blockchain.RoundAmount(amount + fee)
+		if covered > payment.RefundDueAmount {
+			return 0, 0, fmt.Errorf("refund plus network fee exceeds the %.8f %s left on the deposit address",
+				payment.RefundDueAmount, payment.Currency)
+		}
+		return covered, 0, nil
+	}
+
+	covered = min(amount, payment.RefundDueAmount)
+	reserved = blockchain.RoundAmount(amount + fee - covered)
+	return covered, reserved, nil
+}
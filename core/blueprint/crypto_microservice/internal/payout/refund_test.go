@@ -0,0 +1,91 @@
+package payout
+
+import (
+	"testing"
+	"time"
+
+	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/rates"
+)
+
+func TestQuoteRefund(t *testing.T) {
+	payment := &database.CryptoPayment{
+		Amount:       0.001,
+		Currency:     "BTC",
+		FiatAmount:   10000,
+		FiatCurrency: "RSD",
+	}
+	source := rates.NewStaticSource()
+
+	amount, rate, err := quoteRefund(payment, 0.0005, "BTC", "", source)
+	if err != nil || amount != 0.0005 || rate != 0 {
+		t.Errorf("crypto refund: got %.8f at %g (%v)", amount, rate, err)
+	}
+
+	amount, _, err = quoteRefund(payment, 5000, "RSD", database.RateQuote, source)
+	if err != nil || amount != 0.0005 {
+		t.Errorf("quoted refund: got %.8f (%v)", amount, err)
+	}
+
+	amount, _, err = quoteRefund(payment, 5000, "RSD", database.RateCurrent, source)
+	if err != nil || amount != 0.000475 {
+		t.Errorf("current rate refund: got %.8f (%v)", amount, err)
+	}
+
+	if _, _, err := quoteRefund(payment, 50, "EUR", database.RateQuote, source); err == nil {
+		t.Error("expected error for a currency the payment was not quoted in")
+	}
+	if _, _, err := quoteRefund(payment, 5000, "RSD", "", source); err == nil {
+		t.Error("expected error for a fiat refund without rate source")
+	}
+}
+
+func TestRefundSource(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		payment     database.CryptoPayment
+		fromDeposit bool
+		wantErr     error
+	}{
+		{"settled", database.CryptoPayment{Status: database.Confirmed, ReceivedAmount: 1, SettledAt: &now}, false, nil},
+		{"unsettled", database.CryptoPayment{Status: database.Confirmed, ReceivedAmount: 1}, false, ErrNotSettled},
+		{"expired partial", database.CryptoPayment{Status: database.Expired, ReceivedAmount: 0.4}, true, nil},
+		{"expired empty", database.CryptoPayment{Status: database.Expired}, false, ErrNotRefundable},
+		{"in progress", database.CryptoPayment{Status: database.Confirming, ReceivedAmount: 1}, false, ErrNotRefundable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromDeposit, err := refundFromDeposit(&tt.payment)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if fromDeposit != tt.fromDeposit {
+				t.Errorf("fromDeposit = %v, want %v", fromDeposit, tt.fromDeposit)
+			}
+		})
+	}
+}
+
+func TestSplitRefundCost(t *testing.T) {
+	// Settled overpayment: the refund due part is the customer's money, the
+	// rest and the fee come out of the merchant balance
+	payment := &database.CryptoPayment{ReceivedAmount: 1.5, RefundDueAmount: 0.5}
+	covered, reserved, err := splitRefundCost(payment, false, 0.8, 0.01)
+	if err != nil || covered != 0.5 || reserved != 0.31 {
+		t.Errorf("merchant wallet refund: covered %.8f reserved %.8f (%v)", covered, reserved, err)
+	}
+
+	// Expired partial payment refunded from its deposit address
+	payment = &database.CryptoPayment{ReceivedAmount: 0.4, RefundDueAmount: 0.4}
+	covered, reserved, err = splitRefundCost(payment, true, 0.39, 0.01)
+	if err != nil || covered != 0.4 || reserved != 0 {
+		t.Errorf("deposit refund: covered %.8f reserved %.8f (%v)", covered, reserved, err)
+	}
+
+	if _, _, err := splitRefundCost(payment, true, 0.4, 0.01); err == nil {
+		t.Error("expected error when the fee does not fit on the deposit address")
+	}
+}
@@ -0,0 +1,50 @@
+package payout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+)
+
+type payoutAddressDB struct {
+	database.Service
+
+	address *database.PayoutAddress
+}
+
+func (db *payoutAddressDB) CreatePayoutAddress(address *database.PayoutAddress) error {
+	address.ID = 1
+	db.address = address
+	return nil
+}
+
+func (db *payoutAddressDB) GetPayoutAddress(id uint) (*database.PayoutAddress, error) {
+	return db.address, nil
+}
+
+func TestWithdrawalWaitsForAddressActivation(t *testing.T) {
+	db := &payoutAddressDB{}
+	s := NewService(db, nil, blockchain.NewSimulatedProviders(), nil)
+	s.SetAddressDelay(time.Hour)
+
+	address := &database.PayoutAddress{MerchantId: 7, Currency: "BTC", Address: "tb1qexample"}
+	if err := s.WhitelistAddress(address); err != nil {
+		t.Fatal(err)
+	}
+	if wait := time.Until(address.ActiveAfter); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("address active after %v, want in an hour", address.ActiveAfter)
+	}
+
+	_, err := s.RequestWithdrawal(database.PayoutRequest{MerchantId: 7, Currency: "BTC", PayoutAddressId: address.ID, Amount: 0.1})
+	if !errors.Is(err, ErrAddressNotActive) {
+		t.Errorf("expected ErrAddressNotActive, got %v", err)
+	}
+
+	_, err = s.RequestWithdrawal(database.PayoutRequest{MerchantId: 8, Currency: "BTC", PayoutAddressId: address.ID, Amount: 0.1})
+	if !errors.Is(err, ErrAddressNotWhitelisted) {
+		t.Errorf("expected ErrAddressNotWhitelisted for another merchant, got %v", err)
+	}
+}
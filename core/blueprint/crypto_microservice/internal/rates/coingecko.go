@@ -0,0 +1,111 @@
+package rates
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCoinGeckoURL is the public CoinGecko API
+const DefaultCoinGeckoURL = "https://api.coingecko.com/api/v3"
+
+// coinGeckoIDs maps the supported cryptocurrencies to CoinGecko coin ids
+var coinGeckoIDs = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"USDT": "tether",
+}
+
+// CoinGeckoSource fetches market prices from the CoinGecko simple price API.
+// Prices are cached for the refresh interval; if a refresh fails, the last
+// prices keep being served until they are older than the max age, after which
+// Rate fails rather than quote a stale rate.
+type CoinGeckoSource struct {
+	apiURL  string
+	fiats   []string
+	refresh time.Duration
+	maxAge  time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	prices    map[string]map[string]float64 // coin id -> fiat -> price
+	fetchedAt time.Time
+}
+
+// NewCoinGeckoSource creates a source that quotes the given fiat currencies
+func NewCoinGeckoSource(apiURL string, fiatCurrencies []string) *CoinGeckoSource {
+	if apiURL == "" {
+		apiURL = DefaultCoinGeckoURL
+	}
+	return &CoinGeckoSource{
+		apiURL:  strings.TrimRight(apiURL, "/"),
+		fiats:   fiatCurrencies,
+		refresh: time.Minute,
+		maxAge:  10 * time.Minute,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (s *CoinGeckoSource) Rate(fiatCurrency, cryptoCurrency string) (float64, error) {
+	id, ok := coinGeckoIDs[cryptoCurrency]
+	if !ok {
+		return 0, fmt.Errorf("no rate for %s/%s", fiatCurrency, cryptoCurrency)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.fetchedAt) > s.refresh {
+		prices, err := s.fetch()
+		if err != nil {
+			if s.prices == nil || time.Since(s.fetchedAt) > s.maxAge {
+				return 0, fmt.Errorf("failed to fetch rates: %w", err)
+			}
+			fmt.Printf("Serving cached rates, refresh failed: %v\n", err)
+		} else {
+			s.prices = prices
+			s.fetchedAt = time.Now()
+		}
+	}
+
+	price := s.prices[id][strings.ToLower(fiatCurrency)]
+	if price <= 0 {
+		return 0, fmt.Errorf("no rate for %s/%s", fiatCurrency, cryptoCurrency)
+	}
+	return 1 / price, nil
+}
+
+func (s *CoinGeckoSource) fetch() (map[string]map[string]float64, error) {
+	ids := make([]string, 0, len(coinGeckoIDs))
+	for _, id := range coinGeckoIDs {
+		ids = append(ids, id)
+	}
+
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", strings.ToLower(strings.Join(s.fiats, ",")))
+
+	resp, err := s.client.Get(s.apiURL + "/simple/price?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rate API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rate API error: %s", string(body))
+	}
+
+	var prices map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return prices, nil
+}
@@ -0,0 +1,60 @@
+package rates
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCoinGeckoSource(t *testing.T) {
+	fail := false
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		if got := r.URL.Query().Get("vs_currencies"); got != "eur,rsd" {
+			t.Errorf("unexpected vs_currencies %q", got)
+		}
+		fmt.Fprint(w, `{"bitcoin": {"eur": 50000, "rsd": 5000000}, "tether": {"eur": 0.5}}`)
+	}))
+	defer server.Close()
+
+	source := NewCoinGeckoSource(server.URL, []string{"EUR", "RSD"})
+
+	rate, err := source.Rate("EUR", "BTC")
+	if err != nil || rate != 0.00002 {
+		t.Errorf("EUR/BTC: got %g (%v)", rate, err)
+	}
+	rate, err = source.Rate("RSD", "BTC")
+	if err != nil || rate != 0.0000002 {
+		t.Errorf("RSD/BTC: got %g (%v)", rate, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected cached prices, got %d requests", requests)
+	}
+
+	if _, err := source.Rate("RSD", "USDT"); err == nil {
+		t.Error("expected error for a missing price")
+	}
+	if _, err := source.Rate("EUR", "DOGE"); err == nil {
+		t.Error("expected error for an unsupported currency")
+	}
+
+	// A failed refresh keeps serving recent prices
+	fail = true
+	source.fetchedAt = time.Now().Add(-2 * time.Minute)
+	if rate, err := source.Rate("EUR", "USDT"); err != nil || rate != 2 {
+		t.Errorf("cached EUR/USDT: got %g (%v)", rate, err)
+	}
+
+	// but not prices older than the max age
+	source.fetchedAt = time.Now().Add(-time.Hour)
+	if _, err := source.Rate("EUR", "USDT"); err == nil {
+		t.Error("expected error for stale rates")
+	}
+}
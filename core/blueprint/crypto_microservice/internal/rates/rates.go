@@ -0,0 +1,50 @@
+package rates
+
+import (
+	"fmt"
+)
+
+// Source provides exchange rates between fiat currencies and
+// cryptocurrencies
+type Source interface {
+	// Rate returns how many units of the cryptocurrency one unit of the fiat
+	// currency buys
+	Rate(fiatCurrency, cryptoCurrency string) (float64, error)
+}
+
+// StaticSource serves a fixed rate table with the same example rates the PSP
+// converts checkout amounts with. It is meant for tests and local development
+// only; use CoinGeckoSource for market rates.
+type StaticSource struct {
+	rates map[string]map[string]float64
+}
+
+func NewStaticSource() *StaticSource {
+	return &StaticSource{
+		rates: map[string]map[string]float64{
+			"RSD": { // Serbian Dinar
+				"BTC":  0.000000095,
+				"ETH":  0.0000015,
+				"USDT": 0.0093,
+			},
+			"USD": {
+				"BTC":  0.000010,
+				"ETH":  0.00017,
+				"USDT": 1.0,
+			},
+			"EUR": {
+				"BTC":  0.000011,
+				"ETH":  0.00019,
+				"USDT": 1.08,
+			},
+		},
+	}
+}
+
+func (s *StaticSource) Rate(fiatCurrency, cryptoCurrency string) (float64, error) {
+	rate, exists := s.rates[fiatCurrency][cryptoCurrency]
+	if !exists {
+		return 0, fmt.Errorf("no rate for %s/%s", fiatCurrency, cryptoCurrency)
+	}
+	return rate, nil
+}
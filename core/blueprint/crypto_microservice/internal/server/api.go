@@ -1,7 +1,9 @@
 package server
 
 import (
+	"crypto_microservice/internal/blockchain"
 	"crypto_microservice/internal/database"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -22,7 +24,7 @@ func (s *Server) InitiatePaymentHandler(c *gin.Context) {
 	fmt.Printf("Received payment request: MerchantId=%d, Amount=%f, Currency=%s\n", req.MerchantId, req.Amount, req.Currency)
 
 	// Get or create merchant wallet for this currency
-	wallet, err := s.db.GetOrCreateMerchantWallet(req.MerchantId, req.Currency)
+	wallet, err := s.getOrCreateMerchantWallet(req.MerchantId, req.Currency)
 	if err != nil {
 		fmt.Printf("Error getting/creating merchant wallet: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get merchant wallet: %v", err)})
@@ -39,20 +41,42 @@ func (s *Server) InitiatePaymentHandler(c *gin.Context) {
 		return
 	}
 
+	// The confirmations required depend on what the payment is worth
+	requiredConfirmations, fiatValue, fiatCurrency, err := s.requiredConfirmations(req.MerchantId, req.Currency, req.Amount)
+	if err != nil {
+		fmt.Printf("Error applying confirmation policy: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply confirmation policy"})
+		return
+	}
+
+	// Each payment gets its own deposit address, swept into the merchant
+	// wallet once the payment is confirmed
+	paymentId := uuid.New()
+	deposit, err := s.keys.CreateDepositAddress(wallet, paymentId)
+	if err != nil {
+		fmt.Printf("Error creating deposit address: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create deposit address"})
+		return
+	}
+
 	// Create payment record
 	payment := database.CryptoPayment{
-		PaymentId:             uuid.New(),
+		PaymentId:             paymentId,
 		TransactionId:         req.TransactionId,
 		MerchantOrderId:       req.MerchantOrderId,
 		MerchantId:            req.MerchantId,
 		Amount:                req.Amount,
 		Currency:              req.Currency,
 		Status:                database.Pending,
-		DestinationAddress:    wallet.WalletAddress,
-		RequiredConfirmations: config.RequiredConfirmations,
+		DestinationAddress:    deposit.Address,
+		RequiredConfirmations: requiredConfirmations,
+		PolicyFiatAmount:      fiatValue,
+		PolicyFiatCurrency:    fiatCurrency,
 		CreatedAt:             time.Now(),
 		ExpiryTime:            time.Now().Add(config.PaymentWindow),
-		IsTestnet:             true,
+		IsTestnet:             s.testnet,
+		FiatAmount:            req.FiatAmount,
+		FiatCurrency:          req.FiatCurrency,
 	}
 
 	if err := s.db.CreatePayment(&payment); err != nil {
@@ -63,20 +87,19 @@ func (s *Server) InitiatePaymentHandler(c *gin.Context) {
 
 	fmt.Printf("Payment created successfully: %s\n", payment.PaymentId)
 
-	// Start monitoring for this payment
-	go s.monitor.MonitorPayment(payment.PaymentId)
-
 	// Generate payment URI for QR code
-	paymentURI := fmt.Sprintf("%s:%s?amount=%.8f&label=Payment_%s",
-		req.Currency, wallet.WalletAddress, req.Amount, payment.PaymentId.String()[:8])
+	paymentURI, err := paymentURIFor(&payment, payment.Amount)
+	if err != nil {
+		fmt.Printf("Error building payment URI: %v\n", err)
+	}
 
 	response := database.CryptoPaymentResponse{
 		PaymentId:             payment.PaymentId,
-		DestinationAddress:    wallet.WalletAddress,
+		DestinationAddress:    deposit.Address,
 		Amount:                req.Amount,
 		Currency:              req.Currency,
 		ExpiryTime:            payment.ExpiryTime,
-		RequiredConfirmations: config.RequiredConfirmations,
+		RequiredConfirmations: payment.RequiredConfirmations,
 		Status:                payment.Status.String(),
 		QRCode:                paymentURI,
 	}
@@ -105,11 +128,16 @@ func (s *Server) GetPaymentStatusHandler(c *gin.Context) {
 	}
 
 	response := database.PaymentStatusResponse{
-		PaymentId:     payment.PaymentId,
-		Status:        payment.Status.String(),
-		Confirmations: payment.Confirmations,
-		TxHash:        payment.TxHash,
-		BlockHeight:   payment.BlockHeight,
+		PaymentId:       payment.PaymentId,
+		Status:          payment.Status.String(),
+		Confirmations:   payment.Confirmations,
+		TxHash:          payment.TxHash,
+		BlockHeight:     payment.BlockHeight,
+		Amount:          payment.Amount,
+		ReceivedAmount:  payment.ReceivedAmount,
+		RemainingAmount: blockchain.RemainingAmount(payment),
+		RefundDueAmount: payment.RefundDueAmount,
+		TopUpDeadline:   payment.TopUpDeadline,
 	}
 
 	if payment.ConfirmedAt != nil {
@@ -119,11 +147,14 @@ func (s *Server) GetPaymentStatusHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// Simulate payment for testing (testnet only)
+// Simulate payment for testing (testnet only). Amount defaults to the
+// outstanding amount; a smaller or larger amount simulates under- and
+// overpayment, and repeated calls simulate top-ups.
 func (s *Server) SimulatePaymentHandler(c *gin.Context) {
 	type SimulateRequest struct {
 		PaymentId     uuid.UUID `json:"paymentId" binding:"required"`
 		SourceAddress string    `json:"sourceAddress" binding:"required"`
+		Amount        float64   `json:"amount"`
 	}
 
 	var req SimulateRequest
@@ -138,27 +169,41 @@ func (s *Server) SimulatePaymentHandler(c *gin.Context) {
 		return
 	}
 
+	if err := blockchain.ValidateAddress(req.SourceAddress, payment.Currency, payment.IsTestnet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amount := req.Amount
+	if amount <= 0 {
+		amount = blockchain.RemainingAmount(payment)
+	}
+
 	// Simulate blockchain transaction
 	// Generate a 64-character hex hash (like Bitcoin/Ethereum)
-	txHash := fmt.Sprintf("0x%s%s", 
-		uuid.New().String()[:32], 
+	txHash := fmt.Sprintf("0x%s%s",
+		uuid.New().String()[:32],
 		uuid.New().String()[:32])
 
-	payment.Status = database.Confirming
-	payment.SourceAddress = req.SourceAddress
-	payment.TxHash = txHash
-	payment.Confirmations = 1
-	payment.BlockHeight = time.Now().Unix()
-
-	if err := s.db.UpdatePayment(payment); err != nil {
+	payment, err = s.monitor.RecordIncomingTransaction(payment.PaymentId, blockchain.IncomingTransaction{
+		TxHash:        txHash,
+		FromAddress:   blockchain.NormalizeAddress(req.SourceAddress, payment.Currency),
+		Amount:        amount,
+		Confirmations: 1,
+		BlockHeight:   time.Now().Unix(),
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Payment simulated",
-		"txHash":  txHash,
-		"status":  "confirming",
+		"message":         "Payment simulated",
+		"txHash":          txHash,
+		"status":          payment.Status.String(),
+		"receivedAmount":  payment.ReceivedAmount,
+		"remainingAmount": blockchain.RemainingAmount(payment),
+		"refundDueAmount": payment.RefundDueAmount,
 	})
 }
 
@@ -221,14 +266,74 @@ func (s *Server) VerifyTransactionHandler(c *gin.Context) {
 		return
 	}
 
-	// For testnet simulation, we just verify the txHash matches
-	valid := payment.TxHash == req.TxHash
+	updated, incoming, err := s.monitor.VerifyTransaction(payment.PaymentId, req.TxHash)
+	switch {
+	case errors.Is(err, blockchain.ErrNoTransactionSource):
+		// Simulation: only transactions already recorded for the payment
+		s.verifyRecordedTransaction(c, payment, req.TxHash)
+		return
+	case errors.Is(err, blockchain.ErrTransactionNotFound):
+		c.JSON(http.StatusOK, database.TransactionVerifyResponse{
+			Valid:     false,
+			Status:    payment.Status.String(),
+			Error:     "Transaction not found on chain",
+			Timestamp: time.Now(),
+		})
+		return
+	case errors.Is(err, blockchain.ErrWrongDestination):
+		c.JSON(http.StatusOK, database.TransactionVerifyResponse{
+			Valid:         false,
+			Confirmations: incoming.Confirmations,
+			Status:        payment.Status.String(),
+			Error:         err.Error(),
+			Timestamp:     time.Now(),
+		})
+		return
+	case err != nil:
+		fmt.Printf("Error verifying transaction %s: %v\n", req.TxHash, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify transaction"})
+		return
+	}
+
+	// Valid once the payment has received enough; a smaller transaction is
+	// still recorded and counts towards a top-up
+	valid := updated.Status == database.Confirming || updated.Status == database.Confirmed
 
 	response := database.TransactionVerifyResponse{
 		Valid:         valid,
-		Amount:        payment.Amount,
-		Confirmations: payment.Confirmations,
-		Timestamp:     payment.CreatedAt,
+		Amount:        incoming.Amount,
+		Confirmations: incoming.Confirmations,
+		Status:        updated.Status.String(),
+		Timestamp:     time.Now(),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyRecordedTransaction verifies a transaction against the incoming
+// transactions already recorded for a payment, for currencies without a
+// chain connection
+func (s *Server) verifyRecordedTransaction(c *gin.Context, payment *database.CryptoPayment, txHash string) {
+	transactions, err := s.db.GetPaymentTransactions(payment.PaymentId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payment transactions"})
+		return
+	}
+
+	response := database.TransactionVerifyResponse{
+		Status:    payment.Status.String(),
+		Error:     "Transaction not found on chain",
+		Timestamp: time.Now(),
+	}
+
+	for _, transaction := range transactions {
+		if transaction.TxHash == txHash {
+			response.Valid = payment.Status == database.Confirming || payment.Status == database.Confirmed
+			response.Amount = transaction.Amount
+			response.Confirmations = payment.Confirmations
+			response.Error = ""
+			break
+		}
 	}
 
 	c.JSON(http.StatusOK, response)
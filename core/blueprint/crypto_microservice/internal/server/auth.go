@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminToken is the bearer token of the service's operators, who rotate the
+// keystore master key, read the key audit log and issue merchant API keys
+var adminToken = os.Getenv("CRYPTO_ADMIN_TOKEN")
+
+// bearerToken returns the request's bearer token, empty if it has none
+func bearerToken(c *gin.Context) string {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token
+}
+
+// AdminAuthMiddleware admits requests bearing the operators' admin token;
+// with no token configured every request is refused
+func (s *Server) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hashApiKey is how merchant API keys are stored and looked up
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// MerchantAuthMiddleware admits requests bearing a merchant's API key and
+// sets the merchant's ID on the context
+func (s *Server) MerchantAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing merchant API key"})
+			return
+		}
+
+		merchantId, err := s.db.GetMerchantIdByApiKey(hashApiKey(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing merchant API key"})
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error checking merchant API key: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check credentials"})
+			return
+		}
+
+		c.Set("merchantId", merchantId)
+		c.Next()
+	}
+}
+
+// isMerchant answers 403 unless the request's API key is that of merchantId
+func isMerchant(c *gin.Context, merchantId uint) bool {
+	if c.GetUint("merchantId") != merchantId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key does not belong to this merchant"})
+		return false
+	}
+	return true
+}
+
+// IssueMerchantApiKeyHandler issues a merchant a new API key, revoking the
+// previous one. The key is only shown in this response.
+func (s *Server) IssueMerchantApiKeyHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil || merchantId == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	key := hex.EncodeToString(secret)
+
+	if err := s.db.SetMerchantApiKey(uint(merchantId), hashApiKey(key)); err != nil {
+		fmt.Printf("Error storing merchant API key: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"merchantId": merchantId, "apiKey": key})
+}
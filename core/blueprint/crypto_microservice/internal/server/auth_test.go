@@ -0,0 +1,117 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"crypto_microservice/internal/database"
+)
+
+// merchantKeysDB knows the hashed API keys of merchants
+type merchantKeysDB struct {
+	database.Service
+
+	keys map[string]uint
+}
+
+func (db merchantKeysDB) GetMerchantIdByApiKey(keyHash string) (uint, error) {
+	merchantId, ok := db.keys[keyHash]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return merchantId, nil
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	s := &Server{}
+	r := gin.New()
+	r.GET("/admin", s.AdminAuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		configured    string
+		authorization string
+		want          int
+	}{
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		adminToken = tt.configured
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d", tt.name, rr.Code, tt.want)
+		}
+	}
+	adminToken = ""
+}
+
+func TestRoutesRequireCredentials(t *testing.T) {
+	adminToken = "admin"
+	defer func() { adminToken = "" }()
+
+	s := &Server{db: merchantKeysDB{keys: map[string]uint{hashApiKey("merchant"): 1}}}
+	r := s.RegisterRoutes()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/keystore/rotate"},
+		{http.MethodGet, "/keystore/audit/1/BTC"},
+		{http.MethodPost, "/admin/merchants/1/api-key"},
+		{http.MethodPost, "/payout-addresses"},
+		{http.MethodDelete, "/payout-addresses/1/1"},
+		{http.MethodPost, "/payouts"},
+	}
+	for _, route := range routes {
+		for _, authorization := range []string{"", "Bearer other"} {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %q: got status %d want %d", route.method, route.path, authorization, rr.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestMerchantKeyOnlyManagesItsMerchant(t *testing.T) {
+	s := &Server{db: merchantKeysDB{keys: map[string]uint{hashApiKey("merchant-one"): 1}}}
+	r := s.RegisterRoutes()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/payout-addresses", `{"merchantId": 2, "currency": "BTC", "address": "tb1qexample"}`},
+		{http.MethodDelete, "/payout-addresses/2/1", ""},
+		{http.MethodPost, "/payouts", `{"merchantId": 2, "currency": "BTC", "payoutAddressId": 1, "amount": 0.1}`},
+	}
+	for _, request := range requests {
+		req := httptest.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		req.Header.Set("Authorization", "Bearer merchant-one")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s %s for another merchant: got status %d want %d", request.method, request.path, rr.Code, http.StatusForbidden)
+		}
+	}
+}
@@ -19,6 +19,8 @@ func (s *Server) SendCallbackToPSP(payment *database.CryptoPayment) {
 			Status:          mapCryptoStatusToPSPStatus(payment.Status),
 			TxHash:          payment.TxHash,
 			Amount:          payment.Amount,
+			ReceivedAmount:  payment.ReceivedAmount,
+			RefundDueAmount: payment.RefundDueAmount,
 			Currency:        payment.Currency,
 			Confirmations:   payment.Confirmations,
 			CryptoTimestamp: time.Now(),
@@ -53,6 +55,52 @@ func (s *Server) SendCallbackToPSP(payment *database.CryptoPayment) {
 	}()
 }
 
+func (s *Server) SendRefundCallbackToPSP(refund *database.CryptoRefund) {
+	go func() {
+		pspURL := "http://psp_service:8080/refund-callback"
+
+		callback := database.CryptoRefundCallback{
+			TransactionId:     refund.TransactionId,
+			RefundId:          refund.RefundId,
+			Status:            refund.Status.String(),
+			Amount:            refund.Amount,
+			Currency:          refund.Currency,
+			RequestedAmount:   refund.RequestedAmount,
+			RequestedCurrency: refund.RequestedCurrency,
+			RefundAddress:     refund.RefundAddress,
+			TxHash:            refund.TxHash,
+			CryptoTimestamp:   time.Now(),
+		}
+
+		reqBody, err := json.Marshal(callback)
+		if err != nil {
+			fmt.Printf("Error marshaling refund callback: %v\n", err)
+			return
+		}
+
+		req, err := http.NewRequest("PUT", pspURL, bytes.NewBuffer(reqBody))
+		if err != nil {
+			fmt.Printf("Error creating request: %v\n", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Printf("Error sending refund callback to PSP: %v\n", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			fmt.Printf("PSP refund callback failed with status: %d\n", resp.StatusCode)
+		} else {
+			fmt.Printf("PSP refund callback successful for refund: %s\n", refund.RefundId)
+		}
+	}()
+}
+
 func mapCryptoStatusToPSPStatus(status database.PaymentStatus) database.TransactionStatus {
 	switch status {
 	case database.Confirmed:
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/payout"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AddPayoutAddressHandler whitelists an external address for payouts. The
+// address can receive payouts once its activation delay has passed.
+func (s *Server) AddPayoutAddressHandler(c *gin.Context) {
+	var req database.PayoutAddressRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMerchant(c, req.MerchantId) {
+		return
+	}
+
+	if _, exists := database.SupportedCurrencies[req.Currency]; !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	if err := blockchain.ValidateAddress(req.Address, req.Currency, s.testnet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address := database.PayoutAddress{
+		MerchantId: req.MerchantId,
+		Currency:   req.Currency,
+		Address:    blockchain.NormalizeAddress(req.Address, req.Currency),
+		Label:      req.Label,
+	}
+
+	if err := s.payouts.WhitelistAddress(&address); err != nil {
+		fmt.Printf("Error whitelisting payout address: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to whitelist address"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+// GetPayoutAddressesHandler lists a merchant's whitelisted payout addresses
+func (s *Server) GetPayoutAddressesHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	addresses, err := s.db.GetPayoutAddresses(uint(merchantId), c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payout addresses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+// DeletePayoutAddressHandler removes an address from a merchant's whitelist
+func (s *Server) DeletePayoutAddressHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+	if !isMerchant(c, uint(merchantId)) {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout address ID"})
+		return
+	}
+
+	if err := s.db.DeletePayoutAddress(uint(id), uint(merchantId)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout address not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payout address removed"})
+}
+
+// GetPayoutFeeHandler quotes the network fee and the largest possible payout
+func (s *Server) GetPayoutFeeHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+	currency := c.Param("currency")
+
+	fee, err := s.payouts.EstimateFee(currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := s.db.GetWallet(uint(merchantId), currency)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	maxPayout := blockchain.RoundAmount(wallet.Balance - fee)
+	if maxPayout < 0 {
+		maxPayout = 0
+	}
+
+	c.JSON(http.StatusOK, database.PayoutFeeResponse{
+		Currency:         currency,
+		Fee:              fee,
+		AvailableBalance: wallet.Balance,
+		MaxPayout:        maxPayout,
+	})
+}
+
+// RequestPayoutHandler withdraws merchant funds to a whitelisted address
+func (s *Server) RequestPayoutHandler(c *gin.Context) {
+	var req database.PayoutRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMerchant(c, req.MerchantId) {
+		return
+	}
+
+	result, err := s.payouts.RequestWithdrawal(req)
+	switch {
+	case errors.Is(err, payout.ErrAddressNotWhitelisted), errors.Is(err, payout.ErrAddressNotActive):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrInsufficientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance for amount and network fee"})
+		return
+	case err != nil:
+		fmt.Printf("Error requesting payout: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.Status == database.PayoutFailed {
+		c.JSON(http.StatusBadGateway, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPayoutsHandler lists a merchant's recent payouts and sweeps
+func (s *Server) GetPayoutsHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	payouts, err := s.db.GetPayouts(uint(merchantId), 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payouts)
+}
+
+// GetPayoutHandler returns a single payout
+func (s *Server) GetPayoutHandler(c *gin.Context) {
+	payoutId, err := uuid.Parse(c.Param("payoutId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout ID"})
+		return
+	}
+
+	result, err := s.db.GetPayout(payoutId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// confirmationPolicy returns the merchant's confirmation policy for the
+// currency, or the currency's default policy
+func (s *Server) confirmationPolicy(merchantId uint, currency string) (*database.ConfirmationPolicy, error) {
+	config, exists := database.SupportedCurrencies[currency]
+	if !exists {
+		return nil, fmt.Errorf("unsupported currency: %s", currency)
+	}
+
+	policy, err := s.db.GetConfirmationPolicy(merchantId, currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &database.ConfirmationPolicy{
+			MerchantId:   merchantId,
+			Currency:     currency,
+			FiatCurrency: database.DefaultPolicyFiatCurrency,
+			Tiers:        config.ConfirmationTiers,
+		}, nil
+	}
+	return policy, err
+}
+
+// requiredConfirmations values a payment in the fiat currency of the
+// merchant's confirmation policy and returns the confirmations it requires,
+// with the value used. Without an exchange rate the payment gets the
+// policy's highest tier.
+func (s *Server) requiredConfirmations(merchantId uint, currency string, amount float64) (int, float64, string, error) {
+	policy, err := s.confirmationPolicy(merchantId, currency)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	rate, err := s.rates.Rate(policy.FiatCurrency, currency)
+	if err != nil || rate <= 0 {
+		fmt.Printf("No %s/%s rate for the confirmation policy, requiring the highest tier\n", policy.FiatCurrency, currency)
+		return blockchain.ConfirmationsFor(policy.Tiers, math.Inf(1)), 0, "", nil
+	}
+
+	fiatValue := math.Round(amount/rate*100) / 100
+	return blockchain.ConfirmationsFor(policy.Tiers, fiatValue), fiatValue, policy.FiatCurrency, nil
+}
+
+// GetConfirmationPolicyHandler returns the confirmation policy applied to a
+// merchant's payments in a currency
+func (s *Server) GetConfirmationPolicyHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	policy, err := s.confirmationPolicy(uint(merchantId), c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetConfirmationPolicyHandler sets a merchant's confirmation policy for a
+// currency. It applies to payments created afterwards.
+func (s *Server) SetConfirmationPolicyHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+	currency := c.Param("currency")
+
+	var req database.ConfirmationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, exists := database.SupportedCurrencies[currency]; !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	if _, err := s.rates.Rate(req.FiatCurrency, currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported fiat currency: %s", req.FiatCurrency)})
+		return
+	}
+	if err := blockchain.ValidateTiers(req.Tiers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := database.ConfirmationPolicy{
+		MerchantId:   uint(merchantId),
+		Currency:     currency,
+		FiatCurrency: req.FiatCurrency,
+		Tiers:        req.Tiers,
+	}
+
+	if err := s.db.SetConfirmationPolicy(&policy); err != nil {
+		fmt.Printf("Error saving confirmation policy: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save confirmation policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteConfirmationPolicyHandler reverts a merchant to the default
+// confirmation policy for a currency
+func (s *Server) DeleteConfirmationPolicyHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	if err := s.db.DeleteConfirmationPolicy(uint(merchantId), c.Param("currency")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete confirmation policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Default confirmation policy restored"})
+}
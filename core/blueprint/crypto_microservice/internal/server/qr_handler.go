@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto_microservice/internal/blockchain"
+	"crypto_microservice/internal/database"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	maxQRSize     = 1024
+)
+
+// paymentURIFor returns the wallet payment URI requesting amount for a payment
+func paymentURIFor(payment *database.CryptoPayment, amount float64) (string, error) {
+	label := "Payment " + payment.PaymentId.String()[:8]
+	return blockchain.PaymentURI(payment.Currency, payment.DestinationAddress, amount, label, payment.IsTestnet)
+}
+
+// GetPaymentQRHandler renders the payment URI of a payment as a QR code
+// image. The format query parameter selects png (default) or svg and size
+// the image width in pixels. Partially paid payments request the remaining
+// amount.
+func (s *Server) GetPaymentQRHandler(c *gin.Context) {
+	paymentId, err := uuid.Parse(c.Param("paymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	size := defaultQRSize
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || size <= 0 || size > maxQRSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("size must be between 1 and %d", maxQRSize)})
+			return
+		}
+	}
+
+	payment, err := s.db.GetPaymentByPaymentId(paymentId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	amount := payment.Amount
+	if payment.Status == database.PartiallyPaid {
+		amount = blockchain.RemainingAmount(payment)
+	}
+
+	paymentURI, err := paymentURIFor(payment, amount)
+	if err != nil {
+		fmt.Printf("Error building payment URI: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build payment URI"})
+		return
+	}
+
+	qr, err := qrcode.New(paymentURI, qrcode.Medium)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "png") {
+	case "png":
+		png, err := qr.PNG(size)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+	case "svg":
+		c.Data(http.StatusOK, "image/svg+xml", []byte(qrSVG(qr.Bitmap(), size)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
+	}
+}
+
+// qrSVG renders a QR bitmap, including its quiet zone, as an SVG of the
+// given width. Each row of dark modules becomes runs of a single path.
+func qrSVG(bitmap [][]bool, size int) string {
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	modules := len(bitmap)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, modules, modules, path.String())
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/payout"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestRefundHandler creates a refund for a PSP transaction paid in crypto.
+// The response carries the link the customer uses to confirm the refund
+// address.
+func (s *Server) RequestRefundHandler(c *gin.Context) {
+	var req database.RefundRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := s.payouts.RequestRefund(req)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	case errors.Is(err, payout.ErrNotSettled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		fmt.Printf("Error requesting refund: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, database.RefundResponse{
+		Refund:            refund,
+		ConfirmationToken: refund.ConfirmationToken,
+		ConfirmationURL: fmt.Sprintf("http://localhost:3002/refund?refundId=%s&token=%s",
+			refund.RefundId, refund.ConfirmationToken),
+	})
+}
+
+// GetRefundHandler returns a refund, including the suggested refund address
+// shown to the customer for confirmation
+func (s *Server) GetRefundHandler(c *gin.Context) {
+	refundId, err := uuid.Parse(c.Param("refundId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+
+	refund, err := s.db.GetRefund(refundId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, refund)
+}
+
+// ConfirmRefundHandler takes the customer's refund address and sends the
+// refund
+func (s *Server) ConfirmRefundHandler(c *gin.Context) {
+	refundId, err := uuid.Parse(c.Param("refundId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+
+	var req database.RefundConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := s.payouts.ConfirmRefund(refundId, req)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	case errors.Is(err, payout.ErrInvalidToken):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrRefundNotAwaiting):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrInsufficientBalance):
+		c.JSON(http.StatusConflict, gin.H{"error": "Merchant balance does not cover the refund"})
+		return
+	case err != nil:
+		fmt.Printf("Error confirming refund: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if refund.Status == database.RefundFailed {
+		c.JSON(http.StatusBadGateway, refund)
+		return
+	}
+
+	c.JSON(http.StatusOK, refund)
+}
@@ -19,16 +19,45 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.GET("/", s.HelloWorldHandler)
 	r.GET("/health", s.healthHandler)
+	r.GET("/monitor/metrics", s.monitorMetricsHandler)
 
 	// Payment endpoints
 	r.POST("/payment", s.InitiatePaymentHandler)
 	r.GET("/payment-status/:paymentId", s.GetPaymentStatusHandler)
+	r.GET("/payment-qr/:paymentId", s.GetPaymentQRHandler)
 	r.POST("/verify-transaction", s.VerifyTransactionHandler)
 
 	// Wallet management
 	r.POST("/wallet/generate", s.GenerateWalletHandler)
 	r.GET("/wallet/:merchantId/:currency", s.GetWalletHandler)
 
+	// Confirmation policies
+	r.GET("/confirmation-policy/:merchantId/:currency", s.GetConfirmationPolicyHandler)
+	r.PUT("/confirmation-policy/:merchantId/:currency", s.SetConfirmationPolicyHandler)
+	r.DELETE("/confirmation-policy/:merchantId/:currency", s.DeleteConfirmationPolicyHandler)
+
+	// Merchant payouts; changing the whitelist and withdrawing take the
+	// merchant's API key
+	merchantAuth := s.MerchantAuthMiddleware()
+	r.POST("/payout-addresses", merchantAuth, s.AddPayoutAddressHandler)
+	r.GET("/payout-addresses/:merchantId/:currency", s.GetPayoutAddressesHandler)
+	r.DELETE("/payout-addresses/:merchantId/:id", merchantAuth, s.DeletePayoutAddressHandler)
+	r.GET("/payout-fee/:merchantId/:currency", s.GetPayoutFeeHandler)
+	r.POST("/payouts", merchantAuth, s.RequestPayoutHandler)
+	r.GET("/payouts/:merchantId", s.GetPayoutsHandler)
+	r.GET("/payout/:payoutId", s.GetPayoutHandler)
+
+	// Refunds
+	r.POST("/refunds", s.RequestRefundHandler)
+	r.GET("/refunds/:refundId", s.GetRefundHandler)
+	r.POST("/refunds/:refundId/confirm", s.ConfirmRefundHandler)
+
+	// Keystore and merchant credential administration
+	adminAuth := s.AdminAuthMiddleware()
+	r.POST("/keystore/rotate", adminAuth, s.RotateKeysHandler)
+	r.GET("/keystore/audit/:merchantId/:currency", adminAuth, s.GetKeyAuditHandler)
+	r.POST("/admin/merchants/:merchantId/api-key", adminAuth, s.IssueMerchantApiKeyHandler)
+
 	// Admin/testing endpoints
 	r.POST("/simulate-payment", s.SimulatePaymentHandler) // For testnet simulation
 	r.POST("/simulate-confirmation", s.SimulateConfirmationHandler)
@@ -46,3 +75,7 @@ func (s *Server) HelloWorldHandler(c *gin.Context) {
 func (s *Server) healthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, s.db.Health())
 }
+
+func (s *Server) monitorMetricsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.monitor.Metrics())
+}
@@ -11,24 +11,39 @@ import (
 
 	"crypto_microservice/internal/blockchain"
 	"crypto_microservice/internal/database"
+	"crypto_microservice/internal/keystore"
+	"crypto_microservice/internal/payout"
+	"crypto_microservice/internal/rates"
 )
 
 type Server struct {
 	port    int
 	db      database.Service
 	monitor *blockchain.Monitor
+	keys    *keystore.Keystore
+	payouts *payout.Service
+	rates   rates.Source
+
+	// testnet selects the network used for wallet addresses and validation.
+	// The service currently runs against testnets only.
+	testnet bool
 }
 
-func NewServer(db database.Service, monitor *blockchain.Monitor) *http.Server {
+func NewServer(db database.Service, monitor *blockchain.Monitor, keys *keystore.Keystore, payouts *payout.Service, rateSource rates.Source) *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	newServer := &Server{
 		port:    port,
 		db:      db,
 		monitor: monitor,
+		keys:    keys,
+		payouts: payouts,
+		rates:   rateSource,
+		testnet: true,
 	}
 
 	// Set the callback sender so monitor can send callbacks
 	monitor.SetCallbackSender(newServer)
+	payouts.SetRefundNotifier(newServer)
 
 	// Declare Server config
 	server := &http.Server{
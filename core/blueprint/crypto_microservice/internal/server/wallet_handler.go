@@ -2,6 +2,9 @@ package server
 
 import (
 	"crypto_microservice/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -24,7 +27,7 @@ func (s *Server) GenerateWalletHandler(c *gin.Context) {
 	}
 
 	// Generate or get existing wallet
-	wallet, err := s.db.GetOrCreateMerchantWallet(req.MerchantId, req.Currency)
+	wallet, err := s.getOrCreateMerchantWallet(req.MerchantId, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate wallet"})
 		return
@@ -64,3 +67,55 @@ func (s *Server) GetWalletHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, wallet)
 }
+
+// getOrCreateMerchantWallet returns the merchant's wallet for the currency,
+// generating a new key pair in the keystore on first use
+func (s *Server) getOrCreateMerchantWallet(merchantId uint, currency string) (*database.MerchantWallet, error) {
+	wallet, err := s.db.GetWallet(merchantId, currency)
+	if err == nil {
+		return wallet, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return s.keys.CreateMerchantWallet(merchantId, currency, s.testnet)
+}
+
+// RotateKeysHandler rewraps all wallet data keys with the active master key
+func (s *Server) RotateKeysHandler(c *gin.Context) {
+	result, err := s.keys.Rotate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Key rotation failed: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetKeyAuditHandler returns the key audit log for a merchant wallet
+func (s *Server) GetKeyAuditHandler(c *gin.Context) {
+	merchantId, err := strconv.ParseUint(c.Param("merchantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	wallet, err := s.db.GetWallet(uint(merchantId), c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	entries, err := s.db.GetKeyAuditEntries(wallet.ID, 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"walletAddress": wallet.WalletAddress,
+		"keyVersion":    wallet.KeyVersion,
+		"entries":       entries,
+	})
+}
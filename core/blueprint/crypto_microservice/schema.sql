@@ -19,15 +19,43 @@ CREATE TABLE IF NOT EXISTS crypto_payments (
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     expiry_time TIMESTAMP NOT NULL,
     confirmed_at TIMESTAMP,
-    is_testnet BOOLEAN DEFAULT true
+    is_testnet BOOLEAN DEFAULT true,
+    received_amount DECIMAL(18, 8) NOT NULL DEFAULT 0,
+    refund_due_amount DECIMAL(18, 8) NOT NULL DEFAULT 0,
+    top_up_deadline TIMESTAMP,
+    settled_at TIMESTAMP,
+    fiat_amount DECIMAL(18, 2),
+    fiat_currency VARCHAR(10),
+    refunded_amount DECIMAL(18, 8) NOT NULL DEFAULT 0,
+    policy_fiat_amount DECIMAL(18, 2),
+    policy_fiat_currency VARCHAR(10)
 );
 
+-- Amount tracking columns for databases created before partial payments
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS received_amount DECIMAL(18, 8) NOT NULL DEFAULT 0;
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS refund_due_amount DECIMAL(18, 8) NOT NULL DEFAULT 0;
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS top_up_deadline TIMESTAMP;
+
+-- Settlement column for databases created before payouts
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS settled_at TIMESTAMP;
+
+-- Refund columns for databases created before refunds
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS fiat_amount DECIMAL(18, 2);
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS fiat_currency VARCHAR(10);
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(18, 8) NOT NULL DEFAULT 0;
+
+-- Confirmation policy columns for databases created before risk-based confirmations
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS policy_fiat_amount DECIMAL(18, 2);
+ALTER TABLE crypto_payments ADD COLUMN IF NOT EXISTS policy_fiat_currency VARCHAR(10);
+
 -- Create indexes for crypto_payments
 CREATE INDEX IF NOT EXISTS idx_crypto_payments_payment_id ON crypto_payments(payment_id);
 CREATE INDEX IF NOT EXISTS idx_crypto_payments_transaction_id ON crypto_payments(transaction_id);
 CREATE INDEX IF NOT EXISTS idx_crypto_payments_merchant_order_id ON crypto_payments(merchant_order_id);
 CREATE INDEX IF NOT EXISTS idx_crypto_payments_status ON crypto_payments(status);
 CREATE INDEX IF NOT EXISTS idx_crypto_payments_tx_hash ON crypto_payments(tx_hash);
+-- Open payments (pending, confirming, partially paid) polled by the monitor
+CREATE INDEX IF NOT EXISTS idx_crypto_payments_open ON crypto_payments(currency, id) WHERE status IN (0, 1, 5);
 
 -- Create merchant_wallets table
 CREATE TABLE IF NOT EXISTS merchant_wallets (
@@ -37,6 +65,8 @@ CREATE TABLE IF NOT EXISTS merchant_wallets (
     wallet_address VARCHAR(255) NOT NULL UNIQUE,
     public_key TEXT NOT NULL,
     private_key TEXT,
+    wrapped_key TEXT,
+    key_version INTEGER,
     balance DECIMAL(18, 8) DEFAULT 0,
     is_testnet BOOLEAN DEFAULT true,
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
@@ -49,6 +79,126 @@ CREATE INDEX IF NOT EXISTS idx_merchant_wallets_merchant_id ON merchant_wallets(
 CREATE INDEX IF NOT EXISTS idx_merchant_wallets_currency ON merchant_wallets(currency);
 CREATE INDEX IF NOT EXISTS idx_merchant_wallets_address ON merchant_wallets(wallet_address);
 
+-- Envelope encryption columns for databases created before the keystore
+ALTER TABLE merchant_wallets ADD COLUMN IF NOT EXISTS wrapped_key TEXT;
+ALTER TABLE merchant_wallets ADD COLUMN IF NOT EXISTS key_version INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_merchant_wallets_key_version ON merchant_wallets(key_version);
+
+-- Create deposit_addresses table
+CREATE TABLE IF NOT EXISTS deposit_addresses (
+    id SERIAL PRIMARY KEY,
+    payment_id UUID UNIQUE NOT NULL,
+    wallet_id INTEGER NOT NULL REFERENCES merchant_wallets(id),
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    address VARCHAR(255) UNIQUE NOT NULL,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    key_version INTEGER NOT NULL,
+    is_testnet BOOLEAN DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_addresses_key_version ON deposit_addresses(key_version);
+
+-- Create key_audit_log table
+CREATE TABLE IF NOT EXISTS key_audit_log (
+    id SERIAL PRIMARY KEY,
+    wallet_id INTEGER NOT NULL REFERENCES merchant_wallets(id),
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    address VARCHAR(255),
+    operation VARCHAR(20) NOT NULL,
+    purpose VARCHAR(255) NOT NULL,
+    key_version INTEGER NOT NULL,
+    success BOOLEAN NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_audit_log_wallet_id ON key_audit_log(wallet_id);
+
+ALTER TABLE key_audit_log ADD COLUMN IF NOT EXISTS address VARCHAR(255);
+
+-- Create payout_addresses table
+CREATE TABLE IF NOT EXISTS payout_addresses (
+    id SERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(merchant_id, currency, address)
+);
+
+-- New addresses can receive payouts once their activation delay has passed
+ALTER TABLE payout_addresses ADD COLUMN IF NOT EXISTS active_after TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Create merchant_api_keys table
+CREATE TABLE IF NOT EXISTS merchant_api_keys (
+    merchant_id INTEGER PRIMARY KEY,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create payouts table
+CREATE TABLE IF NOT EXISTS payouts (
+    id SERIAL PRIMARY KEY,
+    payout_id UUID UNIQUE NOT NULL,
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    payment_id UUID,
+    from_address VARCHAR(255) NOT NULL,
+    to_address VARCHAR(255) NOT NULL,
+    amount DECIMAL(18, 8) NOT NULL,
+    fee DECIMAL(18, 8) NOT NULL DEFAULT 0,
+    reserved DECIMAL(18, 8) NOT NULL DEFAULT 0,
+    status INTEGER NOT NULL DEFAULT 0,
+    tx_hash VARCHAR(255),
+    confirmations INTEGER NOT NULL DEFAULT 0,
+    required_confirmations INTEGER NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    broadcast_at TIMESTAMP,
+    confirmed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_merchant_id ON payouts(merchant_id);
+CREATE INDEX IF NOT EXISTS idx_payouts_status ON payouts(status);
+CREATE INDEX IF NOT EXISTS idx_payouts_payment_id ON payouts(payment_id);
+
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS reserved DECIMAL(18, 8) NOT NULL DEFAULT 0;
+
+-- Create crypto_refunds table
+CREATE TABLE IF NOT EXISTS crypto_refunds (
+    id SERIAL PRIMARY KEY,
+    refund_id UUID UNIQUE NOT NULL,
+    payment_id UUID NOT NULL REFERENCES crypto_payments(payment_id),
+    transaction_id UUID NOT NULL,
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    requested_amount DECIMAL(18, 8) NOT NULL,
+    requested_currency VARCHAR(10) NOT NULL,
+    rate_source VARCHAR(20) NOT NULL DEFAULT '',
+    rate DECIMAL(24, 12) NOT NULL DEFAULT 0,
+    amount DECIMAL(18, 8) NOT NULL,
+    suggested_address VARCHAR(255) NOT NULL DEFAULT '',
+    refund_address VARCHAR(255),
+    confirmation_token VARCHAR(64) NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    payout_id UUID REFERENCES payouts(payout_id),
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_crypto_refunds_payment_id ON crypto_refunds(payment_id);
+CREATE INDEX IF NOT EXISTS idx_crypto_refunds_payout_id ON crypto_refunds(payout_id);
+
 -- Create blockchain_transactions table (optional, for detailed tracking)
 CREATE TABLE IF NOT EXISTS blockchain_transactions (
     id SERIAL PRIMARY KEY,
@@ -71,23 +221,49 @@ CREATE INDEX IF NOT EXISTS idx_blockchain_tx_hash ON blockchain_transactions(tx_
 CREATE INDEX IF NOT EXISTS idx_blockchain_payment_id ON blockchain_transactions(payment_id);
 CREATE INDEX IF NOT EXISTS idx_blockchain_status ON blockchain_transactions(status);
 
--- Insert some test data for development
--- Test merchant wallets
-INSERT INTO merchant_wallets (merchant_id, currency, wallet_address, public_key, balance, is_testnet, created_at, updated_at)
-VALUES 
-    (12345, 'BTC', 'tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx', '02e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855', 0, true, NOW(), NOW()),
-    (12345, 'ETH', '0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb', '04e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855', 0, true, NOW(), NOW()),
-    (12345, 'USDT', '0x742d35Cc6634C0532925a3b844Bc9e7595f0bEc', '04e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b856', 0, true, NOW(), NOW())
-ON CONFLICT (merchant_id, currency) DO NOTHING;
+-- Create confirmation_tiers table, one row per tier of a merchant's policy
+CREATE TABLE IF NOT EXISTS confirmation_tiers (
+    id SERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    fiat_currency VARCHAR(10) NOT NULL,
+    max_fiat_amount DECIMAL(18, 2),
+    confirmations INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_confirmation_tiers_merchant ON confirmation_tiers(merchant_id, currency);
+
+-- Merchant wallets are not seeded: a wallet is only usable with its private
+-- key in the keystore, so create development wallets with POST /wallet/generate.
 
 -- Add comments for documentation
 COMMENT ON TABLE crypto_payments IS 'Stores all cryptocurrency payment transactions';
 COMMENT ON TABLE merchant_wallets IS 'Stores merchant cryptocurrency wallet addresses';
 COMMENT ON TABLE blockchain_transactions IS 'Detailed tracking of blockchain transactions';
+COMMENT ON TABLE key_audit_log IS 'Audit trail of every merchant private key use';
+COMMENT ON TABLE deposit_addresses IS 'Single-use payment addresses, swept into the merchant wallet after confirmation';
+COMMENT ON TABLE payout_addresses IS 'External addresses merchants whitelisted for payouts';
+COMMENT ON TABLE merchant_api_keys IS 'Credentials merchants manage their payouts with';
+COMMENT ON TABLE payouts IS 'Outgoing withdrawals, refunds and deposit sweeps';
+COMMENT ON TABLE crypto_refunds IS 'Refunds of crypto payments back to a customer-confirmed address';
+COMMENT ON TABLE confirmation_tiers IS 'Merchant confirmation policies by payment fiat value';
 
-COMMENT ON COLUMN crypto_payments.status IS '0=Pending, 1=Confirming, 2=Confirmed, 3=Expired, 4=Failed';
+COMMENT ON COLUMN crypto_payments.status IS '0=Pending, 1=Confirming, 2=Confirmed, 3=Expired, 4=Failed, 5=PartiallyPaid';
 COMMENT ON COLUMN crypto_payments.amount IS 'Amount in cryptocurrency (8 decimal places)';
-COMMENT ON COLUMN crypto_payments.required_confirmations IS 'Number of confirmations needed (3 for BTC, 12 for ETH)';
+COMMENT ON COLUMN crypto_payments.received_amount IS 'Sum of all incoming transactions to the destination address';
+COMMENT ON COLUMN crypto_payments.refund_due_amount IS 'Overpaid or unusable underpaid amount owed back to the customer';
+COMMENT ON COLUMN crypto_payments.settled_at IS 'When the payment was credited to the merchant wallet balance';
+COMMENT ON COLUMN crypto_payments.required_confirmations IS 'Confirmations needed, chosen by the confirmation policy for policy_fiat_amount; 0 accepts unconfirmed payments';
+
+COMMENT ON COLUMN merchant_wallets.private_key IS 'Private key encrypted with a per-wallet data key (should never be exposed)';
+COMMENT ON COLUMN merchant_wallets.wrapped_key IS 'Data key encrypted with the master key of key_version';
+COMMENT ON COLUMN merchant_wallets.balance IS 'Settled funds available for payouts';
+COMMENT ON COLUMN merchant_api_keys.key_hash IS 'SHA-256 of the API key, hex encoded (the key itself is never stored)';
+COMMENT ON COLUMN payout_addresses.active_after IS 'When the address can start receiving payouts';
 
-COMMENT ON COLUMN merchant_wallets.private_key IS 'Encrypted private key (should never be exposed)';
-COMMENT ON COLUMN merchant_wallets.balance IS 'Current wallet balance (for tracking only)';
+COMMENT ON COLUMN payouts.status IS '0=Pending, 1=Broadcast, 2=Confirmed, 3=Failed';
+COMMENT ON COLUMN payouts.reserved IS 'Amount taken from the merchant wallet balance, released if the payout fails';
+COMMENT ON COLUMN confirmation_tiers.max_fiat_amount IS 'Upper bound of the tier in fiat_currency; NULL for the unlimited last tier';
+COMMENT ON COLUMN crypto_refunds.status IS '0=AwaitingConfirmation, 1=Processing, 2=Completed, 3=Failed';
+COMMENT ON COLUMN payouts.amount IS 'Amount delivered to to_address, excluding the network fee';
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeclineNoSuchIssuer is the ISO 8583 response code for a card no member bank issued
+const DeclineNoSuchIssuer = "15"
+
+// AuthorizationRequest is a card payment an acquiring bank sends to the
+// card's issuing bank
+type AuthorizationRequest struct {
+	PccTransactionId     uuid.UUID `json:"pccTransactionId"`
+	AcquirerId           uint      `json:"acquirerId"` // Set from the acquirer's credentials
+	AcquirerOrderId      uuid.UUID `json:"acquirerOrderId" binding:"required"`
+	AcquirerTimestamp    time.Time `json:"acquirerTimestamp" binding:"required"`
+	CardNumber           string    `json:"cardNumber" binding:"required,numeric,min=12,max=19"`
+	ExpiryDate           time.Time `json:"expiryDate" binding:"required"`
+	CardVerificationCode string    `json:"cardVerificationCode" binding:"required"`
+	Amount               float32   `json:"amount" binding:"required,gt=0"`
+	Currency             string    `json:"currency" binding:"required"`
+}
+
+// AuthorizationResponse is the issuing bank's answer to an authorization
+type AuthorizationResponse struct {
+	AcquirerOrderId  uuid.UUID `json:"acquirerOrderId"`
+	PccTransactionId uuid.UUID `json:"pccTransactionId"`
+	IssuerId         uint      `json:"issuerId"`
+	Status           Status    `json:"status"`
+	DeclineCode      string    `json:"declineCode,omitempty"`
+	DeclineReason    string    `json:"declineReason,omitempty"`
+	StandIn          bool      `json:"standIn,omitempty"` // Decided by PCC, the issuer did not answer
+}
+
+// RecordAuthorization stores an authorization routed to an issuer
+func (s *service) RecordAuthorization(transaction Transaction) error {
+	query := `INSERT INTO transactions (transaction_id, status, acquirer_timestamp, timestamp, acquirer_id, issuer_id,
+	                                    acquirer_order_id, amount, currency, partial_card_number)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := s.db.Exec(query, transaction.TransactionId, transaction.Status, transaction.AcquirerTimestamp, transaction.Timestamp,
+		transaction.AcquirerId, transaction.IssuerId, transaction.AcquirerOrderId, transaction.Amount, transaction.Currency,
+		transaction.PartialCardNumber)
+	return err
+}
+
+// CompleteAuthorization records the issuer's answer to an authorization
+func (s *service) CompleteAuthorization(transactionId uuid.UUID, status Status, declineCode string) error {
+	_, err := s.db.Exec(`UPDATE transactions SET status = $1, decline_code = $2 WHERE transaction_id = $3`, status, declineCode, transactionId)
+	return err
+}
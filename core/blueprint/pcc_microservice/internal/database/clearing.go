@@ -0,0 +1,266 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCycleExists   = errors.New("business day is already cleared")
+	ErrCycleNotFound = errors.New("clearing cycle not found")
+)
+
+// Roles of a bank in a cleared transaction
+const (
+	RoleAcquirer = "acquirer"
+	RoleIssuer   = "issuer"
+)
+
+// SettlementItem is a cleared transaction as listed in a bank's settlement file
+type SettlementItem struct {
+	PccTransactionId uuid.UUID `json:"pccTransactionId"`
+	AcquirerOrderId  uuid.UUID `json:"acquirerOrderId"`
+	Role             string    `json:"role"`
+	CounterpartyId   uint      `json:"counterpartyId"`
+	Amount           float64   `json:"amount"`
+	Currency         string    `json:"currency"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// SettlementFile tells a member bank what it settles in a clearing cycle:
+// its net position per currency and the transactions behind them
+type SettlementFile struct {
+	CycleId      uuid.UUID            `json:"cycleId"`
+	BusinessDate string               `json:"businessDate"`
+	BankId       uint                 `json:"bankId"`
+	Positions    []SettlementPosition `json:"positions"`
+	Transactions []SettlementItem     `json:"transactions"`
+}
+
+// toCents converts an amount to whole cents, so positions add up exactly
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// RunClearing closes a business day: every successful transaction up to its
+// end that no earlier cycle cleared goes into a new cycle, and each member
+// bank's net position per currency is computed. The acquirer is owed the
+// amount it paid out to its merchant, the issuer owes what it took from its
+// cardholder.
+func (s *service) RunClearing(businessDate time.Time) (*ClearingCycle, error) {
+	year, month, day := businessDate.Date()
+	cycle := ClearingCycle{
+		CycleId:      uuid.New(),
+		BusinessDate: businessDate.Format(time.DateOnly),
+		CutOff:       time.Date(year, month, day+1, 0, 0, 0, 0, businessDate.Location()),
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM clearing_cycles WHERE business_date = $1)`, cycle.BusinessDate).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrCycleExists
+	}
+
+	// Only authorizations routed between banks carry an acquirer order ID
+	query := `SELECT transaction_id, acquirer_id, issuer_id, amount, currency FROM transactions
+	          WHERE status = $1 AND clearing_cycle_id IS NULL AND timestamp < $2 AND acquirer_order_id IS NOT NULL
+	          FOR UPDATE`
+	rows, err := tx.Query(query, Successful, cycle.CutOff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select transactions to clear: %w", err)
+	}
+
+	type positionKey struct {
+		bankId   uint
+		currency string
+	}
+	receivable := map[positionKey]int64{}
+	payable := map[positionKey]int64{}
+	var transactionIds []uuid.UUID
+	for rows.Next() {
+		var transactionId uuid.UUID
+		var acquirerId, issuerId uint
+		var amount float64
+		var currency string
+		if err := rows.Scan(&transactionId, &acquirerId, &issuerId, &amount, &currency); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		transactionIds = append(transactionIds, transactionId)
+		receivable[positionKey{acquirerId, currency}] += toCents(amount)
+		payable[positionKey{issuerId, currency}] += toCents(amount)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := map[positionKey]bool{}
+	for key := range receivable {
+		keys[key] = true
+	}
+	for key := range payable {
+		keys[key] = true
+	}
+	positions := make([]SettlementPosition, 0, len(keys))
+	for key := range keys {
+		positions = append(positions, SettlementPosition{
+			CycleId:    cycle.CycleId,
+			BankId:     key.bankId,
+			Currency:   key.currency,
+			Receivable: float64(receivable[key]) / 100,
+			Payable:    float64(payable[key]) / 100,
+			Net:        float64(receivable[key]-payable[key]) / 100,
+		})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].BankId != positions[j].BankId {
+			return positions[i].BankId < positions[j].BankId
+		}
+		return positions[i].Currency < positions[j].Currency
+	})
+
+	cycle.TransactionCount = len(transactionIds)
+	cycle.ClosedAt = time.Now()
+	_, err = tx.Exec(`INSERT INTO clearing_cycles (cycle_id, business_date, cut_off, transaction_count, closed_at) VALUES ($1, $2, $3, $4, $5)`,
+		cycle.CycleId, cycle.BusinessDate, cycle.CutOff, cycle.TransactionCount, cycle.ClosedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clearing cycle: %w", err)
+	}
+
+	for _, position := range positions {
+		_, err := tx.Exec(`INSERT INTO settlement_positions (cycle_id, bank_id, currency, receivable, payable, net) VALUES ($1, $2, $3, $4, $5, $6)`,
+			position.CycleId, position.BankId, position.Currency, position.Receivable, position.Payable, position.Net)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store position of bank %d: %w", position.BankId, err)
+		}
+	}
+
+	for _, transactionId := range transactionIds {
+		if _, err := tx.Exec(`UPDATE transactions SET clearing_cycle_id = $1 WHERE transaction_id = $2`, cycle.CycleId, transactionId); err != nil {
+			return nil, fmt.Errorf("failed to mark transaction %s cleared: %w", transactionId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &cycle, nil
+}
+
+func (s *service) GetClearingCycles() ([]ClearingCycle, error) {
+	rows, err := s.db.Query(`SELECT cycle_id, business_date, cut_off, transaction_count, closed_at FROM clearing_cycles ORDER BY business_date DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cycles := []ClearingCycle{}
+	for rows.Next() {
+		var cycle ClearingCycle
+		if err := rows.Scan(&cycle.CycleId, &cycle.BusinessDate, &cycle.CutOff, &cycle.TransactionCount, &cycle.ClosedAt); err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles, rows.Err()
+}
+
+func (s *service) getClearingCycle(cycleId uuid.UUID) (*ClearingCycle, error) {
+	var cycle ClearingCycle
+	err := s.db.QueryRow(`SELECT cycle_id, business_date, cut_off, transaction_count, closed_at FROM clearing_cycles WHERE cycle_id = $1`, cycleId).
+		Scan(&cycle.CycleId, &cycle.BusinessDate, &cycle.CutOff, &cycle.TransactionCount, &cycle.ClosedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCycleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cycle, nil
+}
+
+// GetSettlementPositions returns the net positions of every bank in a cycle
+func (s *service) GetSettlementPositions(cycleId uuid.UUID) ([]SettlementPosition, error) {
+	if _, err := s.getClearingCycle(cycleId); err != nil {
+		return nil, err
+	}
+	return s.settlementPositions(`SELECT cycle_id, bank_id, currency, receivable, payable, net FROM settlement_positions
+	                               WHERE cycle_id = $1 ORDER BY bank_id, currency`, cycleId)
+}
+
+func (s *service) settlementPositions(query string, args ...any) ([]SettlementPosition, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := []SettlementPosition{}
+	for rows.Next() {
+		var position SettlementPosition
+		if err := rows.Scan(&position.CycleId, &position.BankId, &position.Currency, &position.Receivable, &position.Payable, &position.Net); err != nil {
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+	return positions, rows.Err()
+}
+
+// GetSettlementFile returns the settlement file of a member bank for a cycle
+func (s *service) GetSettlementFile(cycleId uuid.UUID, bankId uint) (*SettlementFile, error) {
+	cycle, err := s.getClearingCycle(cycleId)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &SettlementFile{
+		CycleId:      cycle.CycleId,
+		BusinessDate: cycle.BusinessDate,
+		BankId:       bankId,
+		Transactions: []SettlementItem{},
+	}
+	file.Positions, err = s.settlementPositions(`SELECT cycle_id, bank_id, currency, receivable, payable, net FROM settlement_positions
+	                                             WHERE cycle_id = $1 AND bank_id = $2 ORDER BY currency`, cycleId, bankId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT transaction_id, acquirer_order_id, acquirer_id, issuer_id, amount, currency, timestamp FROM transactions
+	          WHERE clearing_cycle_id = $1 AND (acquirer_id = $2 OR issuer_id = $2)
+	          ORDER BY timestamp`
+	rows, err := s.db.Query(query, cycleId, bankId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item SettlementItem
+		var acquirerId, issuerId uint
+		if err := rows.Scan(&item.PccTransactionId, &item.AcquirerOrderId, &acquirerId, &issuerId, &item.Amount, &item.Currency, &item.Timestamp); err != nil {
+			return nil, err
+		}
+		item.Amount = float64(toCents(item.Amount)) / 100
+		if acquirerId == bankId {
+			item.Role, item.CounterpartyId = RoleAcquirer, issuerId
+		} else {
+			item.Role, item.CounterpartyId = RoleIssuer, acquirerId
+		}
+		file.Transactions = append(file.Transactions, item)
+	}
+	return file, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/joho/godotenv/autoload"
 	"gorm.io/driver/postgres"
@@ -24,6 +25,35 @@ type Service interface {
 	// It returns an error if the connection cannot be closed.
 	Close() error
 	WriteTransaction(transaction Transaction) error
+
+	// Member banks and the BIN ranges their cards are routed by
+	GetBanks() ([]Bank, error)
+	GetBank(bankId uint) (*Bank, error)
+	SaveBank(bank Bank) (*Bank, error)
+	DeleteBank(bankId uint) error
+	GetBinRanges() ([]BinRange, error)
+	CreateBinRange(binRange BinRange) (*BinRange, error)
+	UpdateBinRange(binRange BinRange) (*BinRange, error)
+	DeleteBinRange(id uint) error
+
+	// Authorizations routed to issuing banks
+	RecordAuthorization(transaction Transaction) error
+	CompleteAuthorization(transactionId uuid.UUID, status Status, declineCode string) error
+
+	// End-of-day clearing and net settlement between member banks
+	RunClearing(businessDate time.Time) (*ClearingCycle, error)
+	GetClearingCycles() ([]ClearingCycle, error)
+	GetSettlementPositions(cycleId uuid.UUID) ([]SettlementPosition, error)
+	GetSettlementFile(cycleId uuid.UUID, bankId uint) (*SettlementFile, error)
+
+	// Stand-in processing for issuers that do not answer
+	GetStandInRules() ([]StandInRule, error)
+	SaveStandInRule(rule StandInRule) (*StandInRule, error)
+	DeleteStandInRule(bankId uint) error
+	StandIn(req AuthorizationRequest, issuerId uint, cardType string) (*StandInDecision, error)
+	GetAdvices(pending bool) ([]Advice, error)
+	GetAdviceMessages(issuerId uint, limit int) ([]AdviceMessage, error)
+	CompleteAdvice(transactionId uuid.UUID, deliveryErr error) error
 }
 
 type service struct {
@@ -139,8 +169,16 @@ func Connect() {
 
 	err = db.AutoMigrate(&Transaction{})
 	err = db.AutoMigrate(&Bank{})
+	err = db.AutoMigrate(&BinRange{})
+	err = db.AutoMigrate(&ClearingCycle{})
+	err = db.AutoMigrate(&SettlementPosition{})
+	err = db.AutoMigrate(&StandInRule{})
+	err = db.AutoMigrate(&Advice{})
 	if err != nil {
 		return
 	}
+	if err := migrateBankBINs(db); err != nil {
+		log.Printf("failed to copy bank BINs to BIN ranges: %v", err)
+	}
 	//DB = db
 }
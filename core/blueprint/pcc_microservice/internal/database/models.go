@@ -13,13 +13,102 @@ type Transaction struct {
 	Timestamp         time.Time `json:"timestamp"`
 	AcquirerId        uint      `json:"acquirerId"`
 	IssuerId          uint      `json:"issuerId"`
+	AcquirerOrderId   uuid.UUID `json:"acquirerOrderId"`
+	Amount            float32   `json:"amount"`
+	Currency          string    `json:"currency"`
+	PartialCardNumber string    `json:"partialCardNumber"`
+	DeclineCode       string    `json:"declineCode,omitempty"`
+	// Clearing cycle that settled the transaction, nil until it is cleared
+	ClearingCycleId *uuid.UUID `gorm:"index" json:"clearingCycleId,omitempty"`
+	// Approved by PCC on behalf of an unavailable issuer
+	StandIn  bool   `json:"standIn"`
+	CardHash string `gorm:"index" json:"-"` // Keyed hash of the PAN, set on stand-in
 }
 
+// Bank is a member bank of the card network
 type Bank struct {
-	ID                       uint
-	BankId                   uint
-	Name                     string
-	BankIdentificationNumber string
+	ID     uint   `json:"id"`
+	BankId uint   `json:"bankId"`
+	Name   string `json:"name"`
+	// Single BIN of the bank from before BIN ranges; copied to BinRange on startup
+	BankIdentificationNumber string `json:"bankIdentificationNumber,omitempty"`
+	// Issuer endpoint authorizations for the bank's cards are sent to,
+	// e.g. http://unicredit_service:8080/authorizations
+	AuthorizationURL string `json:"authorizationUrl"`
+	// Issuer endpoint stand-in approvals are advised to once it is back
+	AdviceURL string `json:"adviceUrl"`
+	// Secret shared with the bank that PCC authenticates its calls to the
+	// bank with; never returned by the API
+	SharedSecret string `json:"-"`
+}
+
+// BinRange assigns the cards whose BIN lies between Low and High to an
+// issuing bank. Low and High are 6 or 8 digits long, both the same length.
+type BinRange struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	BankId uint   `gorm:"index" json:"bankId" binding:"required"`
+	Low    string `json:"low" binding:"required,numeric"`
+	High   string `json:"high" binding:"required,numeric"`
+	Brand  string `json:"brand" binding:"required"` // e.g. VISA, MASTERCARD
+	// DEBIT, CREDIT or PREPAID; empty when the issuer did not tell
+	CardType string `json:"cardType" binding:"omitempty,oneof=DEBIT CREDIT PREPAID"`
+}
+
+// StandInRule is what PCC may approve on behalf of an issuer that does not
+// answer. Without an enabled rule such authorizations fail.
+type StandInRule struct {
+	ID            uint    `gorm:"primaryKey" json:"-"`
+	BankId        uint    `gorm:"uniqueIndex" json:"bankId"`
+	Enabled       bool    `json:"enabled"`
+	AmountCeiling float64 `gorm:"type:numeric(18,2)" json:"amountCeiling" binding:"min=0"` // Largest single payment; 0 for none
+	DailyCardCap  float64 `gorm:"type:numeric(18,2)" json:"dailyCardCap" binding:"min=0"`  // Stand-in total per card and day; 0 for none
+	// Comma-separated card types of the BIN range, e.g. DEBIT,CREDIT; empty
+	// allows every card
+	CardTypes string `json:"cardTypes"`
+}
+
+// Advice tells an issuer about a payment PCC approved for it in stand-in,
+// so the issuer can post it to the cardholder's account
+type Advice struct {
+	ID                uint       `gorm:"primaryKey" json:"-"`
+	TransactionId     uuid.UUID  `gorm:"uniqueIndex" json:"transactionId"`
+	IssuerId          uint       `gorm:"index" json:"issuerId"`
+	AcquirerId        uint       `json:"acquirerId"`
+	AcquirerOrderId   uuid.UUID  `json:"acquirerOrderId"`
+	AcquirerTimestamp time.Time  `json:"acquirerTimestamp"`
+	EncryptedPAN      string     `json:"-"` // Until the advice is delivered
+	PartialCardNumber string     `json:"partialCardNumber"`
+	ExpiryDate        time.Time  `json:"-"`
+	Amount            float32    `json:"amount"`
+	Currency          string     `json:"currency"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	Attempts          int        `json:"attempts"`
+	LastError         string     `json:"lastError,omitempty"`
+	DeliveredAt       *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// ClearingCycle batches the successful transactions of a business day that
+// were not cleared yet for settlement between the member banks
+type ClearingCycle struct {
+	ID               uint      `gorm:"primaryKey" json:"-"`
+	CycleId          uuid.UUID `gorm:"uniqueIndex" json:"cycleId"`
+	BusinessDate     string    `gorm:"uniqueIndex" json:"businessDate"` // 2006-01-02
+	CutOff           time.Time `json:"cutOff"`
+	TransactionCount int       `json:"transactionCount"`
+	ClosedAt         time.Time `json:"closedAt"`
+}
+
+// SettlementPosition is what a member bank is owed for the payments it
+// acquired and owes for the payments of its cards in one cycle and currency.
+// A positive Net is paid to the bank, a negative one by it.
+type SettlementPosition struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	CycleId    uuid.UUID `gorm:"index" json:"cycleId"`
+	BankId     uint      `json:"bankId"`
+	Currency   string    `json:"currency"`
+	Receivable float64   `gorm:"type:numeric(18,2)" json:"receivable"`
+	Payable    float64   `gorm:"type:numeric(18,2)" json:"payable"`
+	Net        float64   `gorm:"type:numeric(18,2)" json:"net"`
 }
 
 type Status int
@@ -28,4 +117,5 @@ const (
 	Successful Status = iota
 	InProgress
 	Failed
+	Error
 )
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBankNotFound     = errors.New("member bank not found")
+	ErrBankExists       = errors.New("member bank already exists")
+	ErrBinRangeNotFound = errors.New("BIN range not found")
+	ErrInvalidBinRange  = errors.New("invalid BIN range")
+	ErrBinRangeOverlap  = errors.New("BIN range overlaps another range of the same length")
+)
+
+// ValidateBinRange checks that a range's ends are 6 or 8 digit BINs of the
+// same length with Low not above High
+func ValidateBinRange(binRange BinRange) error {
+	if len(binRange.Low) != len(binRange.High) || (len(binRange.Low) != 6 && len(binRange.Low) != 8) {
+		return fmt.Errorf("%w: low and high must both be 6 or 8 digit BINs", ErrInvalidBinRange)
+	}
+	if _, err := strconv.ParseUint(binRange.Low+binRange.High, 10, 64); err != nil {
+		return fmt.Errorf("%w: BINs must be numeric", ErrInvalidBinRange)
+	}
+	if binRange.Low > binRange.High {
+		return fmt.Errorf("%w: low must not be above high", ErrInvalidBinRange)
+	}
+	return nil
+}
+
+func (s *service) GetBanks() ([]Bank, error) {
+	rows, err := s.db.Query(`SELECT id, bank_id, name, COALESCE(bank_identification_number, ''), COALESCE(authorization_url, ''), COALESCE(advice_url, ''), COALESCE(shared_secret, '') FROM banks ORDER BY bank_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	banks := []Bank{}
+	for rows.Next() {
+		var bank Bank
+		if err := rows.Scan(&bank.ID, &bank.BankId, &bank.Name, &bank.BankIdentificationNumber, &bank.AuthorizationURL, &bank.AdviceURL, &bank.SharedSecret); err != nil {
+			return nil, err
+		}
+		banks = append(banks, bank)
+	}
+	return banks, rows.Err()
+}
+
+func (s *service) GetBank(bankId uint) (*Bank, error) {
+	var bank Bank
+	err := s.db.QueryRow(`SELECT id, bank_id, name, COALESCE(bank_identification_number, ''), COALESCE(authorization_url, ''), COALESCE(advice_url, ''), COALESCE(shared_secret, '') FROM banks WHERE bank_id = $1`, bankId).
+		Scan(&bank.ID, &bank.BankId, &bank.Name, &bank.BankIdentificationNumber, &bank.AuthorizationURL, &bank.AdviceURL, &bank.SharedSecret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBankNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bank, nil
+}
+
+// SaveBank adds a member bank, or updates the name and URLs of the member
+// with the same bank ID. The shared secret is only replaced if one is given.
+func (s *service) SaveBank(bank Bank) (*Bank, error) {
+	query := `UPDATE banks SET name = $1, authorization_url = $2, advice_url = $3, shared_secret = COALESCE(NULLIF($4, ''), shared_secret)
+	          WHERE bank_id = $5 RETURNING id`
+	err := s.db.QueryRow(query, bank.Name, bank.AuthorizationURL, bank.AdviceURL, bank.SharedSecret, bank.BankId).Scan(&bank.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		query = `INSERT INTO banks (bank_id, name, bank_identification_number, authorization_url, advice_url, shared_secret) VALUES ($1, $2, '', $3, $4, $5) RETURNING id`
+		err = s.db.QueryRow(query, bank.BankId, bank.Name, bank.AuthorizationURL, bank.AdviceURL, bank.SharedSecret).Scan(&bank.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save bank: %w", err)
+	}
+	return s.GetBank(bank.BankId)
+}
+
+// DeleteBank removes a member bank with its BIN ranges
+func (s *service) DeleteBank(bankId uint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM bin_ranges WHERE bank_id = $1`, bankId); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM banks WHERE bank_id = $1`, bankId)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrBankNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *service) GetBinRanges() ([]BinRange, error) {
+	rows, err := s.db.Query(`SELECT id, bank_id, low, high, brand, COALESCE(card_type, '') FROM bin_ranges ORDER BY low, high`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranges := []BinRange{}
+	for rows.Next() {
+		var binRange BinRange
+		if err := rows.Scan(&binRange.ID, &binRange.BankId, &binRange.Low, &binRange.High, &binRange.Brand, &binRange.CardType); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, binRange)
+	}
+	return ranges, rows.Err()
+}
+
+// checkBinRange validates a range, that its bank is a member and that it does
+// not overlap another range of the same length, which would make routing
+// ambiguous
+func (s *service) checkBinRange(binRange BinRange) error {
+	if err := ValidateBinRange(binRange); err != nil {
+		return err
+	}
+	if _, err := s.GetBank(binRange.BankId); err != nil {
+		return err
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM bin_ranges
+	          WHERE length(low) = $1 AND low <= $3 AND high >= $2 AND id <> $4)`
+	var overlaps bool
+	if err := s.db.QueryRow(query, len(binRange.Low), binRange.Low, binRange.High, binRange.ID).Scan(&overlaps); err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrBinRangeOverlap
+	}
+	return nil
+}
+
+func (s *service) CreateBinRange(binRange BinRange) (*BinRange, error) {
+	binRange.ID = 0
+	if err := s.checkBinRange(binRange); err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO bin_ranges (bank_id, low, high, brand, card_type) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err := s.db.QueryRow(query, binRange.BankId, binRange.Low, binRange.High, binRange.Brand, binRange.CardType).Scan(&binRange.ID); err != nil {
+		return nil, fmt.Errorf("failed to create BIN range: %w", err)
+	}
+	return &binRange, nil
+}
+
+func (s *service) UpdateBinRange(binRange BinRange) (*BinRange, error) {
+	if err := s.checkBinRange(binRange); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE bin_ranges SET bank_id = $1, low = $2, high = $3, brand = $4, card_type = $5 WHERE id = $6`
+	result, err := s.db.Exec(query, binRange.BankId, binRange.Low, binRange.High, binRange.Brand, binRange.CardType, binRange.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update BIN range: %w", err)
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return nil, ErrBinRangeNotFound
+	}
+	return &binRange, nil
+}
+
+func (s *service) DeleteBinRange(id uint) error {
+	result, err := s.db.Exec(`DELETE FROM bin_ranges WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrBinRangeNotFound
+	}
+	return nil
+}
+
+// migrateBankBINs gives every bank whose single BIN is not yet covered by a
+// range a one-BIN range, so banks configured before BIN ranges keep routing
+func migrateBankBINs(db *gorm.DB) error {
+	var banks []Bank
+	err := db.Where("bank_identification_number <> '' AND NOT EXISTS (SELECT 1 FROM bin_ranges r WHERE r.bank_id = banks.bank_id)").
+		Find(&banks).Error
+	if err != nil {
+		return err
+	}
+
+	for _, bank := range banks {
+		binRange := BinRange{BankId: bank.BankId, Low: bank.BankIdentificationNumber, High: bank.BankIdentificationNumber, Brand: "UNKNOWN"}
+		if err := ValidateBinRange(binRange); err != nil {
+			log.Printf("bank %d: BIN %q not migrated: %v", bank.BankId, bank.BankIdentificationNumber, err)
+			continue
+		}
+		if err := db.Create(&binRange).Error; err != nil {
+			return fmt.Errorf("bank %d: %w", bank.BankId, err)
+		}
+	}
+	return nil
+}
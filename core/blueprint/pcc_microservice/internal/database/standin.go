@@ -0,0 +1,366 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ISO 8583 response codes of stand-in declines
+const (
+	DeclineIssuerUnavailable = "91" // The issuer could not be reached and its stand-in rule declines the payment
+	DeclineExpiredCard       = "54" // The card expired
+)
+
+var (
+	ErrNoStandInRule      = errors.New("issuer has no stand-in rule")
+	ErrAdviceNotFound     = errors.New("advice not found")
+	ErrInvalidStandInRule = errors.New("invalid stand-in rule")
+	errCardKeysMissing    = errors.New("PCC_CARD_DATA_KEY and PCC_CARD_INDEX_KEY must be set for stand-in")
+)
+
+// StandInDecision is PCC's answer on behalf of an unavailable issuer
+type StandInDecision struct {
+	Approved bool
+	Code     string // ISO 8583 response code of a decline
+	Reason   string // Why the payment was declined
+}
+
+// AdviceMessage is a stand-in approval as sent to the issuer
+type AdviceMessage struct {
+	PccTransactionId  uuid.UUID `json:"pccTransactionId"`
+	AcquirerId        uint      `json:"acquirerId"`
+	AcquirerOrderId   uuid.UUID `json:"acquirerOrderId"`
+	AcquirerTimestamp time.Time `json:"acquirerTimestamp"`
+	CardNumber        string    `json:"cardNumber"`
+	ExpiryDate        time.Time `json:"expiryDate"`
+	Amount            float32   `json:"amount"`
+	Currency          string    `json:"currency"`
+	ApprovedAt        time.Time `json:"approvedAt"`
+}
+
+// cardDataGCM returns the cipher PANs of pending advices are encrypted with
+func cardDataGCM() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("PCC_CARD_DATA_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, errCardKeysMissing
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptPAN(pan string) (string, error) {
+	gcm, err := cardDataGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, []byte(pan), nil)), nil
+}
+
+func decryptPAN(encrypted string) (string, error) {
+	gcm, err := cardDataGCM()
+	if err != nil {
+		return "", err
+	}
+	data, err := hex.DecodeString(encrypted)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted PAN")
+	}
+	pan, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(pan), nil
+}
+
+// cardHash is the keyed hash stand-in approvals of one card are summed by
+func cardHash(pan string) (string, error) {
+	key := []byte(os.Getenv("PCC_CARD_INDEX_KEY"))
+	if len(key) < 32 {
+		return "", errCardKeysMissing
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(pan))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (s *service) GetStandInRules() ([]StandInRule, error) {
+	rows, err := s.db.Query(`SELECT bank_id, enabled, amount_ceiling, daily_card_cap, card_types FROM stand_in_rules ORDER BY bank_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []StandInRule{}
+	for rows.Next() {
+		var rule StandInRule
+		if err := rows.Scan(&rule.BankId, &rule.Enabled, &rule.AmountCeiling, &rule.DailyCardCap, &rule.CardTypes); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func getStandInRule(tx *sql.Tx, bankId uint) (*StandInRule, error) {
+	var rule StandInRule
+	err := tx.QueryRow(`SELECT bank_id, enabled, amount_ceiling, daily_card_cap, card_types FROM stand_in_rules WHERE bank_id = $1`, bankId).
+		Scan(&rule.BankId, &rule.Enabled, &rule.AmountCeiling, &rule.DailyCardCap, &rule.CardTypes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoStandInRule
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveStandInRule sets the stand-in rule of a member bank
+func (s *service) SaveStandInRule(rule StandInRule) (*StandInRule, error) {
+	if _, err := s.GetBank(rule.BankId); err != nil {
+		return nil, err
+	}
+
+	cardTypes := []string{}
+	for _, cardType := range strings.Split(rule.CardTypes, ",") {
+		cardType = strings.ToUpper(strings.TrimSpace(cardType))
+		switch cardType {
+		case "":
+			continue
+		case "DEBIT", "CREDIT", "PREPAID":
+			cardTypes = append(cardTypes, cardType)
+		default:
+			return nil, fmt.Errorf("%w: unknown card type %q", ErrInvalidStandInRule, cardType)
+		}
+	}
+	rule.CardTypes = strings.Join(cardTypes, ",")
+
+	query := `INSERT INTO stand_in_rules (bank_id, enabled, amount_ceiling, daily_card_cap, card_types) VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (bank_id) DO UPDATE
+	          SET enabled = EXCLUDED.enabled, amount_ceiling = EXCLUDED.amount_ceiling,
+	              daily_card_cap = EXCLUDED.daily_card_cap, card_types = EXCLUDED.card_types`
+	if _, err := s.db.Exec(query, rule.BankId, rule.Enabled, rule.AmountCeiling, rule.DailyCardCap, rule.CardTypes); err != nil {
+		return nil, fmt.Errorf("failed to save stand-in rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (s *service) DeleteStandInRule(bankId uint) error {
+	result, err := s.db.Exec(`DELETE FROM stand_in_rules WHERE bank_id = $1`, bankId)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrNoStandInRule
+	}
+	return nil
+}
+
+// cardExpired reports whether a card expiring in the month of expiry can no
+// longer be used at now; cards are valid through the end of that month
+func cardExpired(expiry time.Time, now time.Time) bool {
+	return !now.Before(time.Date(expiry.Year(), expiry.Month()+1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// declineReason checks a payment against a stand-in rule given what the card
+// was already approved for in stand-in today, returning why it is declined or
+// "" if it may be approved
+func (rule StandInRule) declineReason(amount float64, cardType string, approvedToday float64) string {
+	if rule.CardTypes != "" {
+		allowed := false
+		for _, allowedType := range strings.Split(rule.CardTypes, ",") {
+			allowed = allowed || allowedType == cardType
+		}
+		if !allowed {
+			return "stand_in_card_type"
+		}
+	}
+	if rule.AmountCeiling > 0 && toCents(amount) > toCents(rule.AmountCeiling) {
+		return "stand_in_amount_ceiling"
+	}
+	if rule.DailyCardCap > 0 && toCents(approvedToday)+toCents(amount) > toCents(rule.DailyCardCap) {
+		return "stand_in_daily_cap"
+	}
+	return ""
+}
+
+// StandIn decides a recorded authorization the issuer did not answer by the
+// issuer's stand-in rule. An approval is flagged as stand-in and queued as an
+// advice to the issuer; the decision completes the authorization either way.
+func (s *service) StandIn(req AuthorizationRequest, issuerId uint, cardType string) (*StandInDecision, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rule, err := getStandInRule(tx, issuerId)
+	if err == nil && !rule.Enabled {
+		err = ErrNoStandInRule
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := cardHash(req.CardNumber)
+	if err != nil {
+		return nil, err
+	}
+	encryptedPAN, err := encryptPAN(req.CardNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serializes stand-in for a card so concurrent payments cannot both fit
+	// under its daily cap
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, hash); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	year, month, day := now.Date()
+	var approvedToday float64
+	query := `SELECT COALESCE(SUM(amount), 0) FROM transactions
+	          WHERE card_hash = $1 AND stand_in AND status = $2 AND currency = $3 AND timestamp >= $4`
+	if err := tx.QueryRow(query, hash, Successful, req.Currency, time.Date(year, month, day, 0, 0, 0, 0, now.Location())).Scan(&approvedToday); err != nil {
+		return nil, fmt.Errorf("failed to sum stand-in approvals of the card: %w", err)
+	}
+
+	// PCC cannot check the card against the issuer's records, but an expired
+	// card is declined whatever the stand-in rule allows
+	decision := &StandInDecision{Code: DeclineIssuerUnavailable}
+	if cardExpired(req.ExpiryDate, now) {
+		decision.Code, decision.Reason = DeclineExpiredCard, "expired_card"
+	} else {
+		decision.Reason = rule.declineReason(float64(req.Amount), cardType, approvedToday)
+	}
+	decision.Approved = decision.Reason == ""
+
+	status := Successful
+	if decision.Approved {
+		decision.Code = ""
+	} else {
+		status = Failed
+	}
+	_, err = tx.Exec(`UPDATE transactions SET status = $1, decline_code = $2, stand_in = true, card_hash = $3 WHERE transaction_id = $4`,
+		status, decision.Code, hash, req.PccTransactionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update transaction: %w", err)
+	}
+
+	if decision.Approved {
+		_, err = tx.Exec(`INSERT INTO advices (transaction_id, issuer_id, acquirer_id, acquirer_order_id, acquirer_timestamp,
+		                  encrypted_pan, partial_card_number, expiry_date, amount, currency, created_at, attempts)
+		                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 0)`,
+			req.PccTransactionId, issuerId, req.AcquirerId, req.AcquirerOrderId, req.AcquirerTimestamp,
+			encryptedPAN, req.CardNumber[len(req.CardNumber)-4:], req.ExpiryDate, req.Amount, req.Currency, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to queue advice: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+const adviceColumns = `transaction_id, issuer_id, acquirer_id, acquirer_order_id, acquirer_timestamp, partial_card_number,
+                       amount, currency, created_at, attempts, COALESCE(last_error, ''), delivered_at`
+
+func scanAdvice(row interface{ Scan(dest ...any) error }, advice *Advice) error {
+	return row.Scan(&advice.TransactionId, &advice.IssuerId, &advice.AcquirerId, &advice.AcquirerOrderId, &advice.AcquirerTimestamp,
+		&advice.PartialCardNumber, &advice.Amount, &advice.Currency, &advice.CreatedAt, &advice.Attempts, &advice.LastError, &advice.DeliveredAt)
+}
+
+// GetAdvices returns stand-in advices, only those not yet delivered if pending is set
+func (s *service) GetAdvices(pending bool) ([]Advice, error) {
+	query := `SELECT ` + adviceColumns + ` FROM advices`
+	if pending {
+		query += ` WHERE delivered_at IS NULL`
+	}
+	rows, err := s.db.Query(query + ` ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	advices := []Advice{}
+	for rows.Next() {
+		var advice Advice
+		if err := scanAdvice(rows, &advice); err != nil {
+			return nil, err
+		}
+		advices = append(advices, advice)
+	}
+	return advices, rows.Err()
+}
+
+// GetAdviceMessages returns the undelivered advices to an issuer, oldest first
+func (s *service) GetAdviceMessages(issuerId uint, limit int) ([]AdviceMessage, error) {
+	query := `SELECT transaction_id, acquirer_id, acquirer_order_id, acquirer_timestamp, encrypted_pan, expiry_date, amount, currency, created_at
+	          FROM advices WHERE issuer_id = $1 AND delivered_at IS NULL
+	          ORDER BY created_at LIMIT $2`
+	rows, err := s.db.Query(query, issuerId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []AdviceMessage{}
+	for rows.Next() {
+		var message AdviceMessage
+		var encryptedPAN string
+		err := rows.Scan(&message.PccTransactionId, &message.AcquirerId, &message.AcquirerOrderId, &message.AcquirerTimestamp,
+			&encryptedPAN, &message.ExpiryDate, &message.Amount, &message.Currency, &message.ApprovedAt)
+		if err != nil {
+			return nil, err
+		}
+		if message.CardNumber, err = decryptPAN(encryptedPAN); err != nil {
+			return nil, fmt.Errorf("advice %s: %w", message.PccTransactionId, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// CompleteAdvice records a delivery attempt of an advice. A delivered advice
+// no longer needs the PAN, so it is erased.
+func (s *service) CompleteAdvice(transactionId uuid.UUID, deliveryErr error) error {
+	var result sql.Result
+	var err error
+	if deliveryErr == nil {
+		result, err = s.db.Exec(`UPDATE advices SET attempts = attempts + 1, last_error = '', delivered_at = $1, encrypted_pan = ''
+		                         WHERE transaction_id = $2`, time.Now(), transactionId)
+	} else {
+		result, err = s.db.Exec(`UPDATE advices SET attempts = attempts + 1, last_error = $1 WHERE transaction_id = $2`,
+			deliveryErr.Error(), transactionId)
+	}
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrAdviceNotFound
+	}
+	return nil
+}
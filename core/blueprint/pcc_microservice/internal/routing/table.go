@@ -0,0 +1,96 @@
+// Package routing keeps the BIN routing table of the card network in memory
+// so authorizations can be routed without a database round trip.
+package routing
+
+import (
+	"sort"
+	"sync"
+
+	"pcc_microservice/internal/database"
+)
+
+// binLengths are the BIN lengths ranges are defined for, longest first so an
+// 8 digit range wins over the 6 digit range it lies in
+var binLengths = []int{8, 6}
+
+// Route is where a card's authorizations go
+type Route struct {
+	Issuer database.Bank     `json:"issuer"`
+	Range  database.BinRange `json:"range"`
+}
+
+// Source is where the table is loaded from
+type Source interface {
+	GetBanks() ([]database.Bank, error)
+	GetBinRanges() ([]database.BinRange, error)
+}
+
+type Table struct {
+	mu sync.RWMutex
+	// routes by BIN length, sorted by the low end of the range
+	routes map[int][]Route
+}
+
+func NewTable() *Table {
+	return &Table{routes: map[int][]Route{}}
+}
+
+// Load replaces the table with the member banks and BIN ranges of source
+func (t *Table) Load(source Source) error {
+	banks, err := source.GetBanks()
+	if err != nil {
+		return err
+	}
+	ranges, err := source.GetBinRanges()
+	if err != nil {
+		return err
+	}
+	t.Set(banks, ranges)
+	return nil
+}
+
+// Set replaces the table. Ranges of banks that are not members are left out.
+func (t *Table) Set(banks []database.Bank, ranges []database.BinRange) {
+	members := make(map[uint]database.Bank, len(banks))
+	for _, bank := range banks {
+		members[bank.BankId] = bank
+	}
+
+	routes := map[int][]Route{}
+	for _, binRange := range ranges {
+		bank, ok := members[binRange.BankId]
+		if !ok {
+			continue
+		}
+		length := len(binRange.Low)
+		routes[length] = append(routes[length], Route{Issuer: bank, Range: binRange})
+	}
+	for _, byLength := range routes {
+		sort.Slice(byLength, func(i, j int) bool { return byLength[i].Range.Low < byLength[j].Range.Low })
+	}
+
+	t.mu.Lock()
+	t.routes = routes
+	t.mu.Unlock()
+}
+
+// Lookup returns the route of the longest BIN range the PAN, or a PAN prefix
+// of at least 6 digits, falls in
+func (t *Table) Lookup(pan string) (Route, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, length := range binLengths {
+		if len(pan) < length {
+			continue
+		}
+		bin := pan[:length]
+		byLength := t.routes[length]
+		// last range starting at or below the BIN; ranges of one length do not overlap
+		i := sort.Search(len(byLength), func(i int) bool { return byLength[i].Range.Low > bin }) - 1
+		if i >= 0 && byLength[i].Range.High >= bin {
+			return byLength[i], true
+		}
+	}
+	return Route{}, false
+}
@@ -0,0 +1,43 @@
+package routing
+
+import (
+	"testing"
+
+	"pcc_microservice/internal/database"
+)
+
+func TestLookup(t *testing.T) {
+	table := NewTable()
+	table.Set(
+		[]database.Bank{{BankId: 1, Name: "Erste"}, {BankId: 2, Name: "UniCredit"}},
+		[]database.BinRange{
+			{ID: 1, BankId: 1, Low: "411111", High: "411199", Brand: "VISA"},
+			{ID: 2, BankId: 2, Low: "41111150", High: "41111159", Brand: "VISA"},
+			{ID: 3, BankId: 2, Low: "510000", High: "559999", Brand: "MASTERCARD"},
+			{ID: 4, BankId: 3, Low: "620000", High: "629999", Brand: "UNIONPAY"},
+		},
+	)
+
+	tests := []struct {
+		pan     string
+		rangeId uint
+		found   bool
+	}{
+		{"4111110000000000", 1, true},
+		{"4111115512345678", 2, true},
+		{"411199", 1, true},
+		{"41111155", 2, true},
+		{"5212345678901234", 3, true},
+		{"5600000000000000", 0, false},
+		{"4112000000000000", 0, false},
+		// bank 3 is not a member
+		{"6212345678901234", 0, false},
+		{"41111", 0, false},
+	}
+	for _, tt := range tests {
+		route, found := table.Lookup(tt.pan)
+		if found != tt.found || route.Range.ID != tt.rangeId {
+			t.Errorf("Lookup(%q) = range %d, %v; want range %d, %v", tt.pan, route.Range.ID, found, tt.rangeId, tt.found)
+		}
+	}
+}
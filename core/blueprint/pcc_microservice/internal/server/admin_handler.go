@@ -0,0 +1,217 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pcc_microservice/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseId(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s", param)})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (s *Server) GetMembersHandler(c *gin.Context) {
+	banks, err := s.db.GetBanks()
+	if err != nil {
+		fmt.Printf("failed to fetch member banks: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member banks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, banks)
+}
+
+// minSharedSecretLength is the shortest secret PCC shares with a member bank
+const minSharedSecretLength = 32
+
+// SaveMemberHandler adds a member bank, or on PUT updates the bank in the
+// path. A new member needs the secret PCC and the bank authenticate each
+// other with; an update keeps the current one unless another is given.
+func (s *Server) SaveMemberHandler(c *gin.Context) {
+	var req struct {
+		database.Bank
+		SharedSecret string `json:"sharedSecret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	bank := req.Bank
+	bank.SharedSecret = req.SharedSecret
+
+	if c.Request.Method == http.MethodPut {
+		bankId, ok := parseId(c, "bankId")
+		if !ok {
+			return
+		}
+		if _, err := s.db.GetBank(bankId); errors.Is(err, database.ErrBankNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		bank.BankId = bankId
+	} else if _, err := s.db.GetBank(bank.BankId); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": database.ErrBankExists.Error()})
+		return
+	}
+	if bank.BankId == 0 || bank.Name == "" || bank.AuthorizationURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bankId, name and authorizationUrl are required"})
+		return
+	}
+	if (c.Request.Method == http.MethodPost || bank.SharedSecret != "") && len(bank.SharedSecret) < minSharedSecretLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("sharedSecret of at least %d characters is required", minSharedSecretLength)})
+		return
+	}
+
+	saved, err := s.db.SaveBank(bank)
+	if err != nil {
+		fmt.Printf("failed to save member bank %d: %v\n", bank.BankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save member bank"})
+		return
+	}
+	s.reloadRoutes()
+
+	status := http.StatusOK
+	if c.Request.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	c.JSON(status, saved)
+}
+
+// DeleteMemberHandler removes a member bank and the BIN ranges of its cards
+func (s *Server) DeleteMemberHandler(c *gin.Context) {
+	bankId, ok := parseId(c, "bankId")
+	if !ok {
+		return
+	}
+
+	err := s.db.DeleteBank(bankId)
+	if errors.Is(err, database.ErrBankNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to delete member bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete member bank"})
+		return
+	}
+	s.reloadRoutes()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member bank deleted"})
+}
+
+func (s *Server) GetBinRangesHandler(c *gin.Context) {
+	ranges, err := s.db.GetBinRanges()
+	if err != nil {
+		fmt.Printf("failed to fetch BIN ranges: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BIN ranges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ranges)
+}
+
+func (s *Server) CreateBinRangeHandler(c *gin.Context) {
+	var binRange database.BinRange
+	if err := c.ShouldBindJSON(&binRange); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	created, err := s.db.CreateBinRange(binRange)
+	if !s.binRangeSaved(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func (s *Server) UpdateBinRangeHandler(c *gin.Context) {
+	id, ok := parseId(c, "id")
+	if !ok {
+		return
+	}
+
+	var binRange database.BinRange
+	if err := c.ShouldBindJSON(&binRange); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	binRange.ID = id
+
+	updated, err := s.db.UpdateBinRange(binRange)
+	if !s.binRangeSaved(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// binRangeSaved writes the error response of a failed BIN range write, or
+// reloads the routing table after a successful one
+func (s *Server) binRangeSaved(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		s.reloadRoutes()
+		return true
+	case errors.Is(err, database.ErrBinRangeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrBankNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrBinRangeOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidBinRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("failed to save BIN range: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save BIN range"})
+	}
+	return false
+}
+
+func (s *Server) DeleteBinRangeHandler(c *gin.Context) {
+	id, ok := parseId(c, "id")
+	if !ok {
+		return
+	}
+
+	err := s.db.DeleteBinRange(id)
+	if errors.Is(err, database.ErrBinRangeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to delete BIN range %d: %v\n", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete BIN range"})
+		return
+	}
+	s.reloadRoutes()
+
+	c.JSON(http.StatusOK, gin.H{"message": "BIN range deleted"})
+}
+
+// RouteLookupHandler returns the issuing bank and BIN range a PAN, or a PAN
+// prefix of at least 6 digits, is routed to
+func (s *Server) RouteLookupHandler(c *gin.Context) {
+	pan := c.Param("pan")
+	if _, err := strconv.ParseUint(pan, 10, 64); err != nil || len(pan) < 6 || len(pan) > 19 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PAN must be 6 to 19 digits"})
+		return
+	}
+
+	route, found := s.routes.Lookup(pan)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No issuer for this PAN"})
+		return
+	}
+
+	c.JSON(http.StatusOK, route)
+}
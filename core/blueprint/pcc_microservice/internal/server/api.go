@@ -1,9 +1,14 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"net/http"
 	"pcc_microservice/internal/database"
+	"pcc_microservice/internal/routing"
+	"time"
 )
 
 func (s *Server) NewTransactionHandler(c *gin.Context) {
@@ -19,3 +24,96 @@ func (s *Server) NewTransactionHandler(c *gin.Context) {
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "Transaction created", "transaction": req})
 }
+
+// AuthorizationHandler routes an acquirer's authorization to the bank that
+// issued the card and returns the issuer's answer. The acquirer is the
+// authenticated member, whatever the request claims.
+func (s *Server) AuthorizationHandler(c *gin.Context) {
+	var req database.AuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.AcquirerId = memberIdFrom(c)
+
+	response := database.AuthorizationResponse{
+		AcquirerOrderId:  req.AcquirerOrderId,
+		PccTransactionId: uuid.New(),
+	}
+
+	route, found := s.routes.Lookup(req.CardNumber)
+	if !found {
+		response.Status = database.Failed
+		response.DeclineCode = database.DeclineNoSuchIssuer
+		response.DeclineReason = "no_such_issuer"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	issuer := &route.Issuer
+	if issuer.BankId == req.AcquirerId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Card is issued by the acquirer"})
+		return
+	}
+
+	req.PccTransactionId = response.PccTransactionId
+	response.IssuerId = issuer.BankId
+
+	transaction := database.Transaction{
+		TransactionId:     req.PccTransactionId,
+		Status:            database.InProgress,
+		AcquirerTimestamp: req.AcquirerTimestamp,
+		Timestamp:         time.Now(),
+		AcquirerId:        req.AcquirerId,
+		IssuerId:          issuer.BankId,
+		AcquirerOrderId:   req.AcquirerOrderId,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		PartialCardNumber: req.CardNumber[len(req.CardNumber)-4:],
+	}
+	if err := s.db.RecordAuthorization(transaction); err != nil {
+		fmt.Printf("authorization %s: failed to record transaction: %v\n", req.AcquirerOrderId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to route authorization"})
+		return
+	}
+
+	issuerResponse, err := forwardToIssuer(issuer, req)
+	if err != nil {
+		fmt.Printf("authorization %s: issuer %d unavailable: %v\n", req.AcquirerOrderId, issuer.BankId, err)
+		s.standIn(c, req, route, response)
+		return
+	}
+
+	response.Status = issuerResponse.Status
+	response.DeclineCode = issuerResponse.DeclineCode
+	response.DeclineReason = issuerResponse.DeclineReason
+	if err := s.db.CompleteAuthorization(req.PccTransactionId, response.Status, response.DeclineCode); err != nil {
+		fmt.Printf("authorization %s: failed to update transaction: %v\n", req.AcquirerOrderId, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// standIn answers an authorization whose issuer is unavailable by the
+// issuer's stand-in rule, or fails it if the issuer has none
+func (s *Server) standIn(c *gin.Context, req database.AuthorizationRequest, route routing.Route, response database.AuthorizationResponse) {
+	decision, err := s.db.StandIn(req, route.Issuer.BankId, route.Range.CardType)
+	if err != nil {
+		if !errors.Is(err, database.ErrNoStandInRule) {
+			fmt.Printf("authorization %s: stand-in failed: %v\n", req.AcquirerOrderId, err)
+		}
+		if err := s.db.CompleteAuthorization(req.PccTransactionId, database.Error, ""); err != nil {
+			fmt.Printf("authorization %s: failed to update transaction: %v\n", req.AcquirerOrderId, err)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Issuer unavailable"})
+		return
+	}
+
+	response.StandIn = true
+	response.Status = database.Successful
+	if !decision.Approved {
+		response.Status = database.Failed
+		response.DeclineCode = decision.Code
+		response.DeclineReason = decision.Reason
+	}
+	c.JSON(http.StatusOK, response)
+}
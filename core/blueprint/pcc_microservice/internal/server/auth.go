@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminToken is the bearer token of PCC's operators, who manage members,
+// BIN routing and stand-in
+var adminToken = os.Getenv("PCC_ADMIN_TOKEN")
+
+func bearerToken(c *gin.Context) (string, bool) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token, found && token != ""
+}
+
+// AdminAuthMiddleware admits requests bearing the admin token; with no token
+// configured every request is refused
+func (s *Server) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := bearerToken(c)
+		if !found || adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// MemberAuthMiddleware admits requests bearing the shared secret of a member
+// bank and sets the member's bank ID on the context
+func (s *Server) MemberAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := bearerToken(c)
+		if !found {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing member credentials"})
+			return
+		}
+
+		banks, err := s.db.GetBanks()
+		if err != nil {
+			fmt.Printf("failed to fetch member banks: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate member"})
+			return
+		}
+
+		var memberId uint
+		for _, bank := range banks {
+			if bank.SharedSecret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(bank.SharedSecret)) == 1 {
+				memberId = bank.BankId
+			}
+		}
+		if memberId == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing member credentials"})
+			return
+		}
+
+		c.Set("memberId", memberId)
+		c.Next()
+	}
+}
+
+// memberIdFrom returns the bank ID of the authenticated member
+func memberIdFrom(c *gin.Context) uint {
+	return c.GetUint("memberId")
+}
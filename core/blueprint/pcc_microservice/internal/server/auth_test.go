@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"pcc_microservice/internal/database"
+	"pcc_microservice/internal/routing"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	adminToken = "secret"
+	defer func() { adminToken = "" }()
+
+	s := &Server{}
+	r := s.RegisterRoutes()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/admin/members"},
+		{http.MethodPost, "/admin/members"},
+		{http.MethodPut, "/admin/members/1"},
+		{http.MethodDelete, "/admin/members/1"},
+		{http.MethodGet, "/admin/bin-ranges"},
+		{http.MethodPost, "/admin/bin-ranges"},
+		{http.MethodPut, "/admin/bin-ranges/1"},
+		{http.MethodDelete, "/admin/bin-ranges/1"},
+		{http.MethodGet, "/admin/stand-in-rules"},
+		{http.MethodPut, "/admin/stand-in-rules/1"},
+		{http.MethodDelete, "/admin/stand-in-rules/1"},
+		{http.MethodGet, "/admin/advices"},
+	}
+	for _, route := range routes {
+		for _, authorization := range []string{"", "Bearer other"} {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %q: got status %d want %d", route.method, route.path, authorization, rr.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+// membersDB serves two member banks; bank 1 issues the cards of BIN 411111
+type membersDB struct {
+	database.Service
+}
+
+func (membersDB) GetBanks() ([]database.Bank, error) {
+	return []database.Bank{
+		{BankId: 1, Name: "Issuer", SharedSecret: "one"},
+		{BankId: 2, Name: "Acquirer", SharedSecret: "two"},
+	}, nil
+}
+
+func (membersDB) GetBinRanges() ([]database.BinRange, error) {
+	return []database.BinRange{{BankId: 1, Low: "411111", High: "411111", Brand: "VISA"}}, nil
+}
+
+func (membersDB) GetSettlementFile(cycleId uuid.UUID, bankId uint) (*database.SettlementFile, error) {
+	return &database.SettlementFile{CycleId: cycleId, BankId: bankId}, nil
+}
+
+func TestMemberRoutes(t *testing.T) {
+	s := &Server{db: membersDB{}, routes: routing.NewTable()}
+	if err := s.routes.Load(s.db); err != nil {
+		t.Fatal(err)
+	}
+	r := s.RegisterRoutes()
+
+	// Bank 1 claiming to be bank 2 is still the acquirer of its own card
+	authorization := `{"acquirerId": 2, "acquirerOrderId": "` + uuid.NewString() + `", "acquirerTimestamp": "2026-01-01T00:00:00Z",
+		"cardNumber": "4111111111111111", "expiryDate": "2030-01-01T00:00:00Z", "cardVerificationCode": "123", "amount": 10, "currency": "RSD"}`
+	cycle := "/clearing/cycles/" + uuid.NewString()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		secret string
+		want   int
+	}{
+		{"authorization without credentials", http.MethodPost, "/authorizations", authorization, "", http.StatusUnauthorized},
+		{"authorization of unknown member", http.MethodPost, "/authorizations", authorization, "three", http.StatusUnauthorized},
+		{"authorization as another acquirer", http.MethodPost, "/authorizations", authorization, "one", http.StatusBadRequest},
+		{"own settlement file", http.MethodGet, cycle + "/settlement-files/2", "", "two", http.StatusOK},
+		{"settlement file of another bank", http.MethodGet, cycle + "/settlement-files/1", "", "two", http.StatusForbidden},
+		{"settlement file without credentials", http.MethodGet, cycle + "/settlement-files/2", "", "", http.StatusUnauthorized},
+		{"clearing by a member", http.MethodPost, "/clearing/cycles", `{"businessDate": "2026-01-01"}`, "two", http.StatusUnauthorized},
+		{"positions by a member", http.MethodGet, cycle + "/positions", "", "two", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.secret != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.secret)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d: %s", tt.name, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
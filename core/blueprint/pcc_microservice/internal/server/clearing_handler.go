@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pcc_microservice/internal/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func parseCycleId(c *gin.Context) (uuid.UUID, bool) {
+	cycleId, err := uuid.Parse(c.Param("cycleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cycle ID"})
+		return uuid.Nil, false
+	}
+	return cycleId, true
+}
+
+func (s *Server) GetClearingCyclesHandler(c *gin.Context) {
+	cycles, err := s.db.GetClearingCycles()
+	if err != nil {
+		fmt.Printf("failed to fetch clearing cycles: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clearing cycles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cycles)
+}
+
+// RunClearingHandler clears a past business day, for when the scheduled
+// end-of-day clearing did not run
+func (s *Server) RunClearingHandler(c *gin.Context) {
+	var req struct {
+		BusinessDate string `json:"businessDate" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	businessDate, err := time.ParseInLocation(time.DateOnly, req.BusinessDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "businessDate must be formatted as YYYY-MM-DD"})
+		return
+	}
+	if !businessDate.AddDate(0, 0, 1).Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Business day is not over yet"})
+		return
+	}
+
+	cycle, err := s.db.RunClearing(businessDate)
+	if errors.Is(err, database.ErrCycleExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("clearing of %s failed: %v\n", req.BusinessDate, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clearing failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cycle)
+}
+
+func (s *Server) GetSettlementPositionsHandler(c *gin.Context) {
+	cycleId, ok := parseCycleId(c)
+	if !ok {
+		return
+	}
+
+	positions, err := s.db.GetSettlementPositions(cycleId)
+	if errors.Is(err, database.ErrCycleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to fetch positions of cycle %s: %v\n", cycleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settlement positions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, positions)
+}
+
+// GetSettlementFileHandler returns the settlement file a member bank imports
+// to settle a cycle; each member can only fetch its own
+func (s *Server) GetSettlementFileHandler(c *gin.Context) {
+	cycleId, ok := parseCycleId(c)
+	if !ok {
+		return
+	}
+	bankId, ok := parseId(c, "bankId")
+	if !ok {
+		return
+	}
+	if bankId != memberIdFrom(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Settlement file of another bank"})
+		return
+	}
+
+	file, err := s.db.GetSettlementFile(cycleId, bankId)
+	if errors.Is(err, database.ErrCycleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to build settlement file of bank %d for cycle %s: %v\n", bankId, cycleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build settlement file"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlement-%s-%d.json"`, file.BusinessDate, bankId))
+	c.JSON(http.StatusOK, file)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pcc_microservice/internal/database"
+	"time"
+)
+
+// issuerTimeout bounds how long an acquirer waits for the issuer's answer
+const issuerTimeout = 10 * time.Second
+
+var issuerClient = &http.Client{Timeout: issuerTimeout}
+
+// postToIssuer posts a message to an endpoint of the issuer, authenticated
+// with the secret PCC shares with the bank
+func postToIssuer(issuer database.Bank, url string, body []byte) (*http.Response, error) {
+	if issuer.SharedSecret == "" {
+		return nil, fmt.Errorf("bank %d has no shared secret", issuer.BankId)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+issuer.SharedSecret)
+	return issuerClient.Do(req)
+}
+
+// forwardToIssuer sends an authorization to the issuing bank and returns its answer
+func forwardToIssuer(issuer *database.Bank, req database.AuthorizationRequest) (*database.AuthorizationResponse, error) {
+	if issuer.AuthorizationURL == "" {
+		return nil, fmt.Errorf("bank %d has no authorization URL", issuer.BankId)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := postToIssuer(*issuer, issuer.AuthorizationURL, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("issuer answered %s", resp.Status)
+	}
+
+	var response database.AuthorizationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid issuer response: %w", err)
+	}
+	return &response, nil
+}
+
+// adviceBatchSize is how many advices are sent to an issuer per delivery run
+const adviceBatchSize = 100
+
+// sendAdvice tells the issuer about a payment approved in stand-in
+func sendAdvice(issuer database.Bank, advice database.AdviceMessage) error {
+	body, err := json.Marshal(advice)
+	if err != nil {
+		return err
+	}
+
+	resp, err := postToIssuer(issuer, issuer.AdviceURL, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("issuer answered %s", resp.Status)
+	}
+	return nil
+}
+
+// deliverAdvices sends the pending stand-in advices of every issuer. An
+// issuer that fails an advice is skipped until the next run, so its
+// advices are posted in the order they were approved.
+func (s *Server) deliverAdvices() {
+	banks, err := s.db.GetBanks()
+	if err != nil {
+		fmt.Printf("advice delivery: failed to fetch member banks: %v\n", err)
+		return
+	}
+
+	for _, bank := range banks {
+		if bank.AdviceURL == "" {
+			continue
+		}
+		advices, err := s.db.GetAdviceMessages(bank.BankId, adviceBatchSize)
+		if err != nil {
+			fmt.Printf("advice delivery: failed to fetch advices of bank %d: %v\n", bank.BankId, err)
+			continue
+		}
+
+		for _, advice := range advices {
+			deliveryErr := sendAdvice(bank, advice)
+			if err := s.db.CompleteAdvice(advice.PccTransactionId, deliveryErr); err != nil {
+				fmt.Printf("advice %s: failed to record delivery: %v\n", advice.PccTransactionId, err)
+			}
+			if deliveryErr != nil {
+				fmt.Printf("advice %s: bank %d still unavailable: %v\n", advice.PccTransactionId, bank.BankId, deliveryErr)
+				break
+			}
+		}
+	}
+}
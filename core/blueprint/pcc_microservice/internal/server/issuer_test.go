@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"pcc_microservice/internal/database"
+	"testing"
+)
+
+func TestIssuerCallsCarrySharedSecret(t *testing.T) {
+	var authorizations []string
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"status": 0}`))
+	}))
+	defer issuer.Close()
+
+	bank := database.Bank{BankId: 2, AuthorizationURL: issuer.URL, AdviceURL: issuer.URL, SharedSecret: "secret"}
+	if _, err := forwardToIssuer(&bank, database.AuthorizationRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendAdvice(bank, database.AdviceMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, authorization := range authorizations {
+		if authorization != "Bearer secret" {
+			t.Errorf("issuer called with Authorization %q", authorization)
+		}
+	}
+
+	bank.SharedSecret = ""
+	if _, err := forwardToIssuer(&bank, database.AuthorizationRequest{}); err == nil {
+		t.Error("expected error for a bank without shared secret")
+	}
+	if len(authorizations) != 2 {
+		t.Errorf("issuer called %d times, want 2", len(authorizations))
+	}
+}
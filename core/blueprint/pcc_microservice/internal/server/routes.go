@@ -22,6 +22,27 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.GET("/health", s.healthHandler)
 
 	r.POST("/test-postgre", s.NewTransactionHandler)
+	r.POST("/authorizations", s.MemberAuthMiddleware(), s.AuthorizationHandler)
+	r.GET("/routes/:pan", s.RouteLookupHandler)
+
+	admin := r.Group("/admin", s.AdminAuthMiddleware())
+	admin.GET("/members", s.GetMembersHandler)
+	admin.POST("/members", s.SaveMemberHandler)
+	admin.PUT("/members/:bankId", s.SaveMemberHandler)
+	admin.DELETE("/members/:bankId", s.DeleteMemberHandler)
+	admin.GET("/bin-ranges", s.GetBinRangesHandler)
+	admin.POST("/bin-ranges", s.CreateBinRangeHandler)
+	admin.PUT("/bin-ranges/:id", s.UpdateBinRangeHandler)
+	admin.DELETE("/bin-ranges/:id", s.DeleteBinRangeHandler)
+	admin.GET("/stand-in-rules", s.GetStandInRulesHandler)
+	admin.PUT("/stand-in-rules/:bankId", s.SaveStandInRuleHandler)
+	admin.DELETE("/stand-in-rules/:bankId", s.DeleteStandInRuleHandler)
+	admin.GET("/advices", s.GetAdvicesHandler)
+
+	r.GET("/clearing/cycles", s.AdminAuthMiddleware(), s.GetClearingCyclesHandler)
+	r.POST("/clearing/cycles", s.AdminAuthMiddleware(), s.RunClearingHandler)
+	r.GET("/clearing/cycles/:cycleId/positions", s.AdminAuthMiddleware(), s.GetSettlementPositionsHandler)
+	r.GET("/clearing/cycles/:cycleId/settlement-files/:bankId", s.MemberAuthMiddleware(), s.GetSettlementFileHandler)
 
 	return r
 }
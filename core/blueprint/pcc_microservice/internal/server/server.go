@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,12 +11,26 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"pcc_microservice/internal/database"
+	"pcc_microservice/internal/routing"
 )
 
+// routesReloadInterval is how often the BIN routing table is reloaded, to
+// pick up changes made by other PCC instances
+const routesReloadInterval = time.Minute
+
+// clearingCheckInterval is how often PCC checks whether the previous business
+// day still has to be cleared
+const clearingCheckInterval = 10 * time.Minute
+
+// adviceInterval is how often stand-in advices are sent to issuers that
+// were unavailable
+const adviceInterval = 30 * time.Second
+
 type Server struct {
 	port int
 
-	db database.Service
+	db     database.Service
+	routes *routing.Table
 }
 
 func NewServer() *http.Server {
@@ -23,8 +38,26 @@ func NewServer() *http.Server {
 	NewServer := &Server{
 		port: port,
 
-		db: database.New(),
+		db:     database.New(),
+		routes: routing.NewTable(),
 	}
+	NewServer.reloadRoutes()
+	go func() {
+		for range time.Tick(routesReloadInterval) {
+			NewServer.reloadRoutes()
+		}
+	}()
+	go func() {
+		NewServer.clearPreviousDay()
+		for range time.Tick(clearingCheckInterval) {
+			NewServer.clearPreviousDay()
+		}
+	}()
+	go func() {
+		for range time.Tick(adviceInterval) {
+			NewServer.deliverAdvices()
+		}
+	}()
 
 	// Declare Server config
 	server := &http.Server{
@@ -37,3 +70,24 @@ func NewServer() *http.Server {
 
 	return server
 }
+
+// reloadRoutes loads the BIN routing table from the database, keeping the
+// current table if that fails
+func (s *Server) reloadRoutes() {
+	if err := s.routes.Load(s.db); err != nil {
+		fmt.Printf("failed to load BIN routing table: %v\n", err)
+	}
+}
+
+// clearPreviousDay runs the clearing of yesterday unless it already ran
+func (s *Server) clearPreviousDay() {
+	cycle, err := s.db.RunClearing(time.Now().AddDate(0, 0, -1))
+	if errors.Is(err, database.ErrCycleExists) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("end-of-day clearing failed: %v\n", err)
+		return
+	}
+	fmt.Printf("cleared %s: %d transactions in cycle %s\n", cycle.BusinessDate, cycle.TransactionCount, cycle.CycleId)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pcc_microservice/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) GetStandInRulesHandler(c *gin.Context) {
+	rules, err := s.db.GetStandInRules()
+	if err != nil {
+		fmt.Printf("failed to fetch stand-in rules: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stand-in rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// SaveStandInRuleHandler sets what PCC may approve for a member bank while
+// the bank does not answer
+func (s *Server) SaveStandInRuleHandler(c *gin.Context) {
+	bankId, ok := parseId(c, "bankId")
+	if !ok {
+		return
+	}
+
+	var rule database.StandInRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	rule.BankId = bankId
+
+	saved, err := s.db.SaveStandInRule(rule)
+	if errors.Is(err, database.ErrBankNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrInvalidStandInRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to save stand-in rule of bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save stand-in rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+func (s *Server) DeleteStandInRuleHandler(c *gin.Context) {
+	bankId, ok := parseId(c, "bankId")
+	if !ok {
+		return
+	}
+
+	err := s.db.DeleteStandInRule(bankId)
+	if errors.Is(err, database.ErrNoStandInRule) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		fmt.Printf("failed to delete stand-in rule of bank %d: %v\n", bankId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stand-in rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stand-in rule deleted"})
+}
+
+// GetAdvicesHandler lists stand-in approvals and whether their issuer was
+// advised; ?pending=true lists only those still to be delivered
+func (s *Server) GetAdvicesHandler(c *gin.Context) {
+	advices, err := s.db.GetAdvices(c.Query("pending") == "true")
+	if err != nil {
+		fmt.Printf("failed to fetch advices: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch advices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, advices)
+}
@@ -35,6 +35,8 @@ type Service interface {
 	GetTransactionByMerchantOrderId(merchantOrderId uuid.UUID) (PaymentRequest, error)
 	GetTransactionByQRRef(qrRef uint64) (PaymentRequest, error)
 	ChangeTransactionStatus(transactionId uuid.UUID, status TransactionStatus) (uint, error)
+	SetReceivedAmount(transactionId uuid.UUID, receivedAmount float64, currency string) error
+	SetRefundStatus(transactionId uuid.UUID, status string, completedAmount float64) error
 	DeletePreviousSubscription(merchantId uint) error
 	SaveSubscription(merchantId uint, method uint) error
 	GetSubscriptionsForMerchant(merchantId uint) ([]int, error)
@@ -165,6 +167,26 @@ func (s *service) ChangeTransactionStatus(transactionId uuid.UUID, status Transa
 	return merchantID, nil
 }
 
+func (s *service) SetReceivedAmount(transactionId uuid.UUID, receivedAmount float64, currency string) error {
+	query := `UPDATE transactions SET received_amount = $1, received_currency = $2 WHERE transaction_id = $3`
+	_, err := s.db.Exec(query, receivedAmount, currency, transactionId)
+	if err != nil {
+		return fmt.Errorf("failed to update received amount: %w", err)
+	}
+	return nil
+}
+
+// SetRefundStatus records the latest refund status and adds the amount of a
+// completed refund to the refunded total
+func (s *service) SetRefundStatus(transactionId uuid.UUID, status string, completedAmount float64) error {
+	query := `UPDATE transactions SET refund_status = $1, refunded_amount = refunded_amount + $2 WHERE transaction_id = $3`
+	_, err := s.db.Exec(query, status, completedAmount, transactionId)
+	if err != nil {
+		return fmt.Errorf("failed to update refund status: %w", err)
+	}
+	return nil
+}
+
 func (s *service) DeletePreviousSubscription(merchantId uint) error {
 	query := `DELETE FROM subscriptions WHERE merchant_id = $1;`
 	_, err := s.db.Exec(query, merchantId)
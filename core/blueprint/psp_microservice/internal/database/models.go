@@ -17,6 +17,10 @@ type Transaction struct {
 	Currency          string            `json:"currency" binding:"required"`
 	PaymentMethod		string  `json:"paymentMethod" binding:"required"`
 	QRRef             uint64            `json:"qrRef" gorm:"uniqueIndex"`
+	ReceivedAmount    float64           `json:"receivedAmount"`   // Crypto only: amount that actually arrived on chain
+	ReceivedCurrency  string            `json:"receivedCurrency"` // Crypto only: currency of ReceivedAmount
+	RefundStatus      string            `json:"refundStatus"`     // Crypto only: status of the latest refund
+	RefundedAmount    float64           `json:"refundedAmount"`   // Crypto only: completed refunds, in ReceivedCurrency
 }
 
 type WebShopPaymentRequest struct {
@@ -36,6 +40,8 @@ type WebShopPaymentRequest struct {
 type PaymentRequest struct {
 	ExpDate         time.Time `json:"expDate"`
 	CardNumber      string    `json:"cardNumber"`
+	// CVV2 is forwarded to the issuer in memory only, never stored or logged
+	CardVerificationCode string `json:"cardVerificationCode,omitempty"`
 	Currency        string    `json:"currency" binding:"required"`
 	Amount          float32   `json:"amount" binding:"required"`
 	MerchantId      uint      `json:"merchantId" binding:"required"`
@@ -56,14 +62,14 @@ type CardDetailsRequest struct {
 	CardNumber           string    `json:"cardNumber" binding:"required"`
 	MerchantOrderId      uuid.UUID `json:"merchantOrderId" binding:"required"`
 	ExpDate              time.Time `json:"expDate" binding:"required"`
-	CardVerificationCode uint      `json:"cardVerificationCode" binding:"required"`
+	CardVerificationCode string    `json:"cardVerificationCode" binding:"required,numeric,min=3,max=4"`
 }
 
 type QRCodeRequest struct {
 	CardNumber           string    `json:"cardNumber" binding:"required"`
 	QRRef                uint64    `json:"qrRef" binding:"required"`
 	ExpDate              time.Time `json:"expDate" binding:"required"`
-	CardVerificationCode uint      `json:"cardVerificationCode" binding:"required"`
+	CardVerificationCode string    `json:"cardVerificationCode" binding:"required,numeric,min=3,max=4"`
 }
 
 
@@ -73,6 +79,35 @@ type TransactionResponse struct {
 	MerchantOrderId   uuid.UUID         `json:"merchantOrderId" binding:"required"`
 	TransactionId     uuid.UUID         `json:"transactionId" binding:"required"`
 	Status            TransactionStatus `json:"status" binding:"required"`
+	// Sent by the issuing bank when a card payment is declined, e.g. N7 for a wrong CVV2
+	DeclineCode   string `json:"declineCode,omitempty"`
+	DeclineReason string `json:"declineReason,omitempty"`
+	// Sent by the crypto service only
+	ReceivedAmount  *float64 `json:"receivedAmount,omitempty"`
+	RefundDueAmount float64  `json:"refundDueAmount,omitempty"`
+	Currency        string   `json:"currency,omitempty"`
+}
+
+// CryptoRefundRequest is sent by the web shop to refund a crypto payment,
+// e.g. when a rental is cancelled. Currency is the order's fiat currency or
+// the cryptocurrency paid; RateSource is "quote" or "current" for fiat.
+type CryptoRefundRequest struct {
+	MerchantId       uint      `json:"merchantId" binding:"required"`
+	MerchantPassword string    `json:"merchantPassword" binding:"required"`
+	MerchantOrderId  uuid.UUID `json:"merchantOrderId" binding:"required"`
+	Amount           float64   `json:"amount" binding:"required"`
+	Currency         string    `json:"currency" binding:"required"`
+	RateSource       string    `json:"rateSource"`
+}
+
+// RefundCallback is sent by the crypto service when a refund changes status
+type RefundCallback struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	RefundId      uuid.UUID `json:"refundId"`
+	Status        string    `json:"status"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	TxHash        string    `json:"txHash"`
 }
 
 type Merchant struct {
@@ -89,6 +89,24 @@ func (s *Server) PaymentCallbackHandler(c *gin.Context) {
 		fmt.Println("Unmarshal Error:", err)
 	}
 	fmt.Println("Request Body:", string(body))
+	if req.DeclineCode != "" {
+		fmt.Printf("Transaction %s declined by the issuer with code %s (%s)\n", req.TransactionId, req.DeclineCode, req.DeclineReason)
+	}
+
+	if req.ReceivedAmount != nil {
+		if err := s.db.SetReceivedAmount(req.TransactionId, *req.ReceivedAmount, req.Currency); err != nil {
+			fmt.Println(err)
+		}
+		if req.RefundDueAmount > 0 {
+			fmt.Printf("Transaction %s: %.8f %s is due for refund\n", req.TransactionId, req.RefundDueAmount, req.Currency)
+		}
+	}
+
+	// Partially paid crypto payments report progress without a final status
+	if req.Status == database.InProgress {
+		c.JSON(http.StatusOK, gin.H{"message": "Payment progress recorded"})
+		return
+	}
 
 	merchant_id, err := s.db.ChangeTransactionStatus(req.TransactionId, req.Status)
 	url, err := s.db.GetMerchantRedirectURL(merchant_id, req.Status)
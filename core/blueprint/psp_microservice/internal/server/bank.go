@@ -58,6 +58,7 @@ func (s *Server) CardDetailsHandler(c *gin.Context) {
 	fmt.Println(paymentRequest.Amount)
 	paymentRequest.CardNumber = req.CardNumber
 	paymentRequest.ExpDate = req.ExpDate
+	paymentRequest.CardVerificationCode = req.CardVerificationCode
 	s.ForwardPaymentToBankGateway(paymentRequest)
 	c.JSON(http.StatusOK, gin.H{"message": "Payment request forwarded"})
 }
@@ -73,9 +74,10 @@ func (s *Server) QRCodeScanningHandler(c *gin.Context) {
     // 2. Extract CardNumber and ExpDate from the same form-data body
     cardNumber := c.PostForm("CardNumber")
     expDateStr := c.PostForm("ExpDate")
+    cvv := c.PostForm("CardVerificationCode")
 
-    if cardNumber == "" || expDateStr == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "CardNumber and ExpDate are required"})
+    if cardNumber == "" || expDateStr == "" || cvv == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "CardNumber, ExpDate and CardVerificationCode are required"})
         return
     }
 	expiryTime, err := time.Parse("01/06", expDateStr)
@@ -105,9 +107,10 @@ func (s *Server) QRCodeScanningHandler(c *gin.Context) {
 
 	}
 	fmt.Println("payment request")
-	fmt.Println(paymentRequest)
+	fmt.Println(paymentRequest.TransactionId)
 	paymentRequest.CardNumber = cardNumber
 	paymentRequest.ExpDate = expiryTime
+	paymentRequest.CardVerificationCode = cvv
 	fmt.Println("Rtrty")
 	s.ForwardPaymentToBankGateway(paymentRequest)
 	c.JSON(http.StatusOK, gin.H{"message": "Payment request forwarded"})
@@ -19,7 +19,6 @@ func (s *Server) ForwardPaymentToBankGateway(paymentRequest database.PaymentRequ
 			// Handle error, perhaps log and return an error response to the client
 			return
 		}
-		fmt.Println(paymentRequest.CardNumber)
 		resp, err := http.Post(bankGatewayServiceURL, "application/json", bytes.NewBuffer(reqBody))
 		if err != nil {
 			// Handle error, perhaps log and retry or notify an admin
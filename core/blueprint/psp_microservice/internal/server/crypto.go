@@ -64,7 +64,7 @@ func (s *Server) CryptoPaymentDetailsHandler(c *gin.Context) {
 		Timestamp:       transaction.Timestamp,
 	}
 
-	s.ForwardPaymentToCryptoService(paymentRequest, c)
+	s.ForwardPaymentToCryptoService(paymentRequest, transaction.Amount, transaction.Currency, c)
 }
 
 // convertToCrypto converts fiat amount to cryptocurrency amount
@@ -100,7 +100,7 @@ func convertToCrypto(fiatAmount float64, fiatCurrency string, cryptoCurrency str
 }
 
 // ForwardPaymentToCryptoService forwards the payment to the crypto microservice
-func (s *Server) ForwardPaymentToCryptoService(paymentRequest database.PaymentRequest, c *gin.Context) {
+func (s *Server) ForwardPaymentToCryptoService(paymentRequest database.PaymentRequest, fiatAmount float32, fiatCurrency string, c *gin.Context) {
 	fmt.Println("Forwarding payment to crypto service")
 
 	cryptoServiceURL := "http://crypto_service:8080/payment"
@@ -113,6 +113,8 @@ func (s *Server) ForwardPaymentToCryptoService(paymentRequest database.PaymentRe
 		"currency":        paymentRequest.Currency,
 		"timestamp":       paymentRequest.Timestamp,
 		"merchantId":      paymentRequest.MerchantId,
+		"fiatAmount":      fiatAmount,
+		"fiatCurrency":    fiatCurrency,
 	}
 
 	reqBody, err := json.Marshal(cryptoReq)
@@ -179,3 +181,91 @@ func (s *Server) CryptoPaymentStatusHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, statusResp)
 }
+
+// CryptoRefundHandler asks the crypto service to refund a crypto payment.
+// The response includes the link where the customer confirms the refund
+// address.
+func (s *Server) CryptoRefundHandler(c *gin.Context) {
+	var req database.CryptoRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	merchant, err := s.db.CheckMerchant(req.MerchantId, req.MerchantPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if merchant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid merchant"})
+		return
+	}
+
+	transaction, err := s.db.GetTransactionByMerchantOrderId(req.MerchantOrderId)
+	if err != nil || transaction.MerchantId != req.MerchantId {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	refundReq := map[string]interface{}{
+		"transactionId": transaction.TransactionId,
+		"amount":        req.Amount,
+		"currency":      req.Currency,
+		"rateSource":    req.RateSource,
+	}
+
+	reqBody, err := json.Marshal(refundReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+		return
+	}
+
+	resp, err := http.Post("http://crypto_service:8080/refunds", "application/json", bytes.NewBuffer(reqBody))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Crypto service unavailable"})
+		return
+	}
+	defer resp.Body.Close()
+
+	var refundResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&refundResp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
+		return
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		c.JSON(resp.StatusCode, refundResp)
+		return
+	}
+
+	if err := s.db.SetRefundStatus(transaction.TransactionId, "awaiting_confirmation", 0); err != nil {
+		fmt.Println(err)
+	}
+
+	c.JSON(http.StatusCreated, refundResp)
+}
+
+// RefundCallbackHandler records refund status updates from the crypto service
+func (s *Server) RefundCallbackHandler(c *gin.Context) {
+	var req database.RefundCallback
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	completedAmount := 0.0
+	if req.Status == "completed" {
+		completedAmount = req.Amount
+	}
+
+	if err := s.db.SetRefundStatus(req.TransactionId, req.Status, completedAmount); err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund status"})
+		return
+	}
+
+	fmt.Printf("Refund %s for transaction %s: %s (%.8f %s)\n",
+		req.RefundId, req.TransactionId, req.Status, req.Amount, req.Currency)
+	c.JSON(http.StatusOK, gin.H{"message": "Refund status recorded"})
+}
@@ -28,6 +28,8 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.PUT("/payment-callback", s.PaymentCallbackHandler)
 	r.GET("/crypto-payment-details", s.CryptoPaymentDetailsHandler)
 	r.GET("/crypto-status", s.CryptoPaymentStatusHandler)
+	r.POST("/crypto-refund", s.CryptoRefundHandler)
+	r.PUT("/refund-callback", s.RefundCallbackHandler)
 
 	r.POST("/subscription/url", s.SendSubscriptionUrlsHandler)
 	r.POST("/subscription", s.SaveSubscriptionForMarchantHandler)
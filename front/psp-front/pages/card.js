@@ -78,7 +78,7 @@ export default function CardPage() {
             cardNumber: form.number.replace(/\s+/g, ''),
             merchantOrderId: mId,
             expDate: dateString,
-            cardVerificationCode: Number(form.cvv),
+            cardVerificationCode: form.cvv,
         }
         console.log(form)
         console.log(data)